@@ -9,15 +9,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"log"
 	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 
 	"github.com/rotmanjanez/check24-gendev-7/config"
 	"github.com/rotmanjanez/check24-gendev-7/internal/api"
+	"github.com/rotmanjanez/check24-gendev-7/internal/workerpool"
 	"github.com/rotmanjanez/check24-gendev-7/pkg/cache"
 	"github.com/rotmanjanez/check24-gendev-7/pkg/interfaces"
 	"github.com/rotmanjanez/check24-gendev-7/pkg/logger"
@@ -31,6 +38,9 @@ import (
 	_ "github.com/rotmanjanez/check24-gendev-7/providers/webwunder"
 )
 
+// shutdownTimeout limits how long requests and queries may take to stop on shutdown
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	configPath := flag.String("config", "config.json", "Path to the configuration file")
 	envPath := flag.String("env", ".env", "Path to the environment file")
@@ -114,15 +124,19 @@ func main() {
 		}
 	}
 
-	providers, err := provider.CreateProviders(cacheFactory, cfg)
+	// rate limits fall back to this instance if the shared cache is unavailable
+	providers, err := provider.CreateProviders(cacheFactory, cfg, cache.NewInstanceCache("rate-limit-fallback"))
 	if err != nil {
 		log.Fatalf("Error creating backends: %v", err)
 	}
 
+	// bounds the number of queries running concurrently
+	pool := workerpool.New(int(cfg.MaxConcurrentRequests), int(cfg.RequestBufferSize))
+
 	HealthAPIService := api.NewHealthAPIService()
 	HealthAPIController := api.NewHealthAPIController(HealthAPIService)
 
-	SystemAPIService := api.NewSystemAPIService(cfg)
+	SystemAPIService := api.NewSystemAPIService(cfg, pool)
 	SystemAPIController := api.NewSystemAPIController(SystemAPIService)
 
 	cache, err := cacheFactory.Create("check24-gendev-7")
@@ -133,14 +147,44 @@ func main() {
 	if err != nil {
 		log.Fatalf("Error creating queue: %v", err)
 	}
-	InternetProductsAPIService := api.NewInternetProductsAPIService(cfg, cache, queue, providers)
+	queueCache, ok := queue.(interfaces.QueueCache)
+	if !ok {
+		log.Fatalf("Queue cache %T does not support result logs", queue)
+	}
+	InternetProductsAPIService := api.NewInternetProductsAPIService(cfg, cache, queueCache, providers, pool)
 	InternetProductsAPIController := api.NewInternetProductsAPIController(InternetProductsAPIService)
+	InternetProductsSocketController := api.NewInternetProductsSocketController(InternetProductsAPIService)
 
-	router := api.NewRouter(HealthAPIController, SystemAPIController, InternetProductsAPIController)
+	router := api.NewRouter(HealthAPIController, SystemAPIController, InternetProductsAPIController, InternetProductsSocketController)
 
 	slog.Debug("Using config file", "path", *configPath)
 	slog.Debug("Using config backends", "backends", cfg.Backends)
 	log.Printf("Starting server on %s", cfg.GetAddress())
 
-	log.Fatal(http.ListenAndServe(cfg.GetAddress(), router))
+	server := &http.Server{Addr: cfg.GetAddress(), Handler: router}
+	// open result streams and sockets would block the shutdown, clients resume them on another instance
+	server.RegisterOnShutdown(InternetProductsAPIService.CloseStreams)
+	go func() {
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	// on shutdown, running queries are handed over to the remaining instances
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	log.Printf("Shutting down server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error shutting down server", "error", err)
+	}
+	if err := InternetProductsAPIService.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error handing over running queries", "error", err)
+		return
+	}
+	// all queries stopped, so the workers finish right away
+	pool.Close()
 }
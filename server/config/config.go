@@ -28,10 +28,22 @@ type Config struct {
 	// default: 8080
 	Port uint `json:"port"`
 
+	// MaxConcurrentRequests is the number of product queries that run at the same time.
+	// default: 32
 	MaxConcurrentRequests uint `json:"maxConcurrentRequests"`
 
+	// RequestBufferSize is the number of product queries waiting for a free slot.
+	// Further queries are rejected with 503 Service Unavailable.
+	// default: 128
 	RequestBufferSize uint `json:"requestBufferSize"`
 
+	// QueryInactivityTimeout cancels a running query if its results were not polled for this long, in milliseconds.
+	// default: 30000
+	QueryInactivityTimeout time.Duration `json:"queryInactivityTimeout"`
+
+	// WorkQueue distributes the provider requests of all queries across all instances
+	WorkQueue WorkQueueConfig `json:"workQueue"`
+
 	// BuildDate is the date when the application was built.
 	// It is set at build time using the -X flag.
 	// default: empty
@@ -49,6 +61,30 @@ type Config struct {
 	Backends map[string]BackendConfig `json:"backends"`
 }
 
+// WorkQueueConfig configures the distributed mode, in which provider requests are sent as jobs through
+// a queue shared by all instances. It requires a queue cache supporting jobs, i.e. Redis or the in-process cache.
+// All durations are given in milliseconds in the config file.
+type WorkQueueConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// Workers is the number of jobs executed at the same time by this instance.
+	// default: 8
+	Workers int `json:"workers"`
+
+	// VisibilityTimeout is the time after which a job taken by a worker is delivered again,
+	// unless the worker finished it. It must exceed the time needed for all attempts of a request.
+	// default: 30000
+	VisibilityTimeout time.Duration `json:"visibilityTimeout"`
+
+	// MaxDeliveries is the number of times a job is delivered before it is given up.
+	// default: 3
+	MaxDeliveries int `json:"maxDeliveries"`
+
+	// PollInterval is how often idle workers and running queries check the queue for new jobs and results.
+	// default: 50
+	PollInterval time.Duration `json:"pollInterval"`
+}
+
 type BackendConfig struct {
 	Enabled       bool                   `json:"enabled"`
 	Retries       int                    `json:"retries"`
@@ -56,6 +92,216 @@ type BackendConfig struct {
 	MaxConcurrent int                    `json:"maxConcurrent"`
 	Backoff       time.Duration          `json:"backoff"`
 	Options       map[string]interface{} `json:"options"`
+
+	// SoftDeadline is the time after which the query no longer waits for the provider, given in milliseconds.
+	// Products arriving later are still delivered, but flagged as late.
+	// default: 0 (the query waits until the provider finished)
+	SoftDeadline time.Duration `json:"softDeadline"`
+
+	RetryPolicy RetryPolicyConfig `json:"retryPolicy"`
+
+	CircuitBreaker CircuitBreakerConfig `json:"circuitBreaker"`
+
+	ResultCache ResultCacheConfig `json:"resultCache"`
+
+	ConcurrencyLimit ConcurrencyLimitConfig `json:"concurrencyLimit"`
+
+	RateLimit RateLimitConfig `json:"rateLimit"`
+
+	FanOut FanOutConfig `json:"fanOut"`
+
+	Hedge HedgeConfig `json:"hedge"`
+
+	// Auth lists the signers applied to every request, in order
+	Auth []AuthConfig `json:"auth"`
+}
+
+// RetryPolicyConfig configures the exponential backoff between retries of a provider call.
+// The first delay is the backend's Backoff. All durations are given in milliseconds in the config file.
+type RetryPolicyConfig struct {
+	// MaxBackoff caps the delay between two attempts, including delays requested via Retry-After.
+	// default: 30s
+	MaxBackoff time.Duration `json:"maxBackoff"`
+
+	// Multiplier is the factor by which the delay grows with every attempt.
+	// default: 2
+	Multiplier float64 `json:"multiplier"`
+
+	// RetryOn lists the HTTP status codes that are retried, either exact ("429") or by class ("5xx").
+	// Transport errors are always retried.
+	// default: ["408", "429", "5xx"]
+	RetryOn []string `json:"retryOn"`
+}
+
+// CircuitBreakerConfig configures the per-provider circuit breaker.
+// All durations are given in milliseconds in the config file.
+type CircuitBreakerConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// FailureRateThreshold is the ratio (0-1) of failed calls within the window that opens the breaker.
+	// default: 0.5
+	FailureRateThreshold float64 `json:"failureRateThreshold"`
+
+	// SlowCallThreshold is the latency above which a call is considered slow.
+	// default: 0 (slow calls are not tracked)
+	SlowCallThreshold time.Duration `json:"slowCallThreshold"`
+
+	// SlowCallRateThreshold is the ratio (0-1) of slow calls within the window that opens the breaker.
+	// default: 0.8
+	SlowCallRateThreshold float64 `json:"slowCallRateThreshold"`
+
+	// MinimumRequests is the number of calls within the window before the rates are evaluated.
+	// default: 10
+	MinimumRequests int `json:"minimumRequests"`
+
+	// Window is the length of the sliding window in which calls are counted.
+	// default: 30s
+	Window time.Duration `json:"window"`
+
+	// OpenDuration is the time the breaker stays open before allowing probe calls.
+	// default: 30s
+	OpenDuration time.Duration `json:"openDuration"`
+
+	// HalfOpenRequests is the number of probe calls that need to succeed to close the breaker again.
+	// default: 1
+	HalfOpenRequests int `json:"halfOpenRequests"`
+}
+
+// ResultCacheConfig configures the cache of products returned by a provider per address.
+// All durations are given in milliseconds in the config file.
+type ResultCacheConfig struct {
+	// TTL is the time cached products are served without asking the provider.
+	// default: 0 (results are not cached)
+	TTL time.Duration `json:"ttl"`
+
+	// StaleTTL is the time after TTL in which cached products are still served,
+	// while they are refreshed in the background.
+	// default: 0 (stale results are not served)
+	StaleTTL time.Duration `json:"staleTtl"`
+}
+
+// FanOutConfig limits the work a provider may cause for a single query.
+// Providers exceeding a limit are stopped, the products emitted so far are kept.
+type FanOutConfig struct {
+	// MaxDepth is the maximum number of follow-up levels below the requests returned by PrepareRequest.
+	// default: 0 (unlimited)
+	MaxDepth int `json:"maxDepth"`
+
+	// MaxRequests is the maximum number of requests sent per query, not counting retries.
+	// default: 0 (unlimited)
+	MaxRequests int `json:"maxRequests"`
+
+	// MaxProducts is the maximum number of products emitted per query.
+	// default: 0 (unlimited)
+	MaxProducts int `json:"maxProducts"`
+}
+
+// HedgeConfig configures hedged requests for providers with a high latency variance.
+// If a call takes longer than the configured latency percentile, the same request is sent again
+// and the first successful response is used. Hedged requests count against the rate limit and
+// concurrency limit of the provider and are skipped if either does not allow another call right away.
+type HedgeConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// Percentile of recent latencies after which a request is hedged, between 0 and 1.
+	// default: 0.9
+	Percentile float64 `json:"percentile"`
+
+	// Budget is the maximum ratio of hedged requests to all requests.
+	// default: 0.1
+	Budget float64 `json:"budget"`
+
+	// MinSamples is the number of observed latencies required before requests are hedged.
+	// default: 20
+	MinSamples int `json:"minSamples"`
+}
+
+const (
+	// StaticConcurrencyLimit allows MaxConcurrent concurrent calls
+	StaticConcurrencyLimit = "static"
+	// AIMDConcurrencyLimit adapts the concurrency with additive increase and multiplicative decrease
+	AIMDConcurrencyLimit = "aimd"
+)
+
+// ConcurrencyLimitConfig selects how concurrent calls to a provider are limited.
+// All durations are given in milliseconds in the config file.
+type ConcurrencyLimitConfig struct {
+	// Strategy is either "static" or "aimd".
+	// default: static
+	Strategy string `json:"strategy"`
+
+	// MinLimit is the lower bound of the adaptive limit.
+	// default: 1
+	MinLimit int `json:"minLimit"`
+
+	// MaxLimit is the upper bound of the adaptive limit.
+	// default: the backend's MaxConcurrent
+	MaxLimit int `json:"maxLimit"`
+
+	// InitialLimit is the adaptive limit before any call finished.
+	// default: MinLimit
+	InitialLimit int `json:"initialLimit"`
+
+	// DecreaseFactor (0-1) is multiplied with the limit on 429s, timeouts and latency spikes.
+	// default: 0.5
+	DecreaseFactor float64 `json:"decreaseFactor"`
+
+	// LatencyThreshold is the latency above which a call counts as a latency spike.
+	// default: 0 (spikes are detected relative to the average latency)
+	LatencyThreshold time.Duration `json:"latencyThreshold"`
+
+	// LatencySpikeFactor is the multiple of the average latency that counts as a latency spike
+	// if no LatencyThreshold is set.
+	// default: 2
+	LatencySpikeFactor float64 `json:"latencySpikeFactor"`
+}
+
+// RateLimitConfig limits the calls to a provider across all instances
+type RateLimitConfig struct {
+	// RequestsPerSecond is the rate at which calls are allowed on average.
+	// default: 0 (the rate is not limited)
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+
+	// Burst is the number of calls allowed at once after a quiet period.
+	// default: RequestsPerSecond rounded up, at least 1
+	Burst int `json:"burst"`
+
+	// DailyQuota is the number of calls allowed per day (UTC), including retries.
+	// default: 0 (no quota)
+	DailyQuota int `json:"dailyQuota"`
+}
+
+const (
+	// APIKeyHeaderAuth sends an API key in a header
+	APIKeyHeaderAuth = "apiKeyHeader"
+	// APIKeyQueryAuth sends an API key as a query parameter
+	APIKeyQueryAuth = "apiKeyQuery"
+	// BasicAuth sends a username and password with HTTP basic authentication
+	BasicAuth = "basic"
+	// HMACAuth signs the timestamp and body of a request with HMAC-SHA256
+	HMACAuth = "hmac"
+)
+
+// AuthConfig configures a signer that authenticates the requests to a provider.
+// Secrets are not part of the config file, they are read from the named environment variables.
+type AuthConfig struct {
+	// Type is one of apiKeyHeader, apiKeyQuery, basic and hmac
+	Type string `json:"type"`
+
+	// Name is the header or query parameter carrying the API key, or the header carrying the HMAC signature.
+	// default: X-Api-Key for headers, apiKey for query parameters, X-Signature for HMAC signatures
+	Name string `json:"name"`
+
+	// KeyEnv is the environment variable holding the API key or the HMAC secret
+	KeyEnv string `json:"keyEnv"`
+
+	// UsernameEnv and PasswordEnv are the environment variables holding the basic auth credentials
+	UsernameEnv string `json:"usernameEnv"`
+	PasswordEnv string `json:"passwordEnv"`
+
+	// TimestampHeader is the header carrying the unix timestamp of HMAC signatures.
+	// default: X-Timestamp
+	TimestampHeader string `json:"timestampHeader"`
 }
 
 func LoadConfig(filename string) (*Config, error) {
@@ -69,9 +315,21 @@ func LoadConfig(filename string) (*Config, error) {
 		return nil, err
 	}
 
+	config.QueryInactivityTimeout = config.QueryInactivityTimeout * time.Millisecond
+	config.WorkQueue.VisibilityTimeout = config.WorkQueue.VisibilityTimeout * time.Millisecond
+	config.WorkQueue.PollInterval = config.WorkQueue.PollInterval * time.Millisecond
+
 	for key, backend := range config.Backends {
 		backend.Timeout = backend.Timeout * time.Millisecond
 		backend.Backoff = backend.Backoff * time.Millisecond
+		backend.SoftDeadline = backend.SoftDeadline * time.Millisecond
+		backend.RetryPolicy.MaxBackoff = backend.RetryPolicy.MaxBackoff * time.Millisecond
+		backend.CircuitBreaker.SlowCallThreshold = backend.CircuitBreaker.SlowCallThreshold * time.Millisecond
+		backend.CircuitBreaker.Window = backend.CircuitBreaker.Window * time.Millisecond
+		backend.CircuitBreaker.OpenDuration = backend.CircuitBreaker.OpenDuration * time.Millisecond
+		backend.ResultCache.TTL = backend.ResultCache.TTL * time.Millisecond
+		backend.ResultCache.StaleTTL = backend.ResultCache.StaleTTL * time.Millisecond
+		backend.ConcurrencyLimit.LatencyThreshold = backend.ConcurrencyLimit.LatencyThreshold * time.Millisecond
 		config.Backends[key] = backend
 	}
 
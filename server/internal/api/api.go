@@ -30,6 +30,9 @@ type HealthAPIRouter interface {
 type InternetProductsAPIRouter interface {
 	InitiateInternetProductsQuery(http.ResponseWriter, *http.Request)
 	ContinueInternetProductsQuery(http.ResponseWriter, *http.Request)
+	StreamInternetProducts(http.ResponseWriter, *http.Request)
+	GetInternetProductsQueryStatus(http.ResponseWriter, *http.Request)
+	CancelInternetProductsQuery(http.ResponseWriter, *http.Request)
 	GetSharedInternetProducts(http.ResponseWriter, *http.Request)
 	ShareInternetProducts(http.ResponseWriter, *http.Request)
 }
@@ -39,6 +42,7 @@ type InternetProductsAPIRouter interface {
 // pass the data to a SystemAPIServicer to perform the required actions, then write the service results to the http response.
 type SystemAPIRouter interface {
 	GetVersion(http.ResponseWriter, *http.Request)
+	GetLoad(http.ResponseWriter, *http.Request)
 }
 
 // HealthAPIServicer defines the api actions for the HealthAPI service
@@ -55,9 +59,12 @@ type HealthAPIServicer interface {
 // and updated with the logic required for the API.
 type InternetProductsAPIServicer interface {
 	InitiateInternetProductsQuery(context.Context, models.Address, []string) (ImplResponse, error)
-	ContinueInternetProductsQuery(context.Context, string) (ImplResponse, error)
-	GetSharedInternetProducts(context.Context, string) (ImplResponse, error)
-	ShareInternetProducts(context.Context, string) (ImplResponse, error)
+	ContinueInternetProductsQuery(context.Context, string, int32, models.InternetProductsFilter) (ImplResponse, error)
+	StreamInternetProducts(context.Context, string, string) (ImplResponse, error)
+	GetInternetProductsQueryStatus(context.Context, string) (ImplResponse, error)
+	CancelInternetProductsQuery(context.Context, string) (ImplResponse, error)
+	GetSharedInternetProducts(context.Context, string, models.InternetProductsFilter) (ImplResponse, error)
+	ShareInternetProducts(context.Context, string, *models.InternetProductsFilter) (ImplResponse, error)
 }
 
 // SystemAPIServicer defines the api actions for the SystemAPI service
@@ -66,4 +73,5 @@ type InternetProductsAPIServicer interface {
 // and updated with the logic required for the API.
 type SystemAPIServicer interface {
 	GetVersion(context.Context) (ImplResponse, error)
+	GetLoad(context.Context) (ImplResponse, error)
 }
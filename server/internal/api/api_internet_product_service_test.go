@@ -7,10 +7,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/rotmanjanez/check24-gendev-7/internal/workerpool"
 	"github.com/rotmanjanez/check24-gendev-7/pkg/cache"
 	"github.com/rotmanjanez/check24-gendev-7/pkg/interfaces"
 	"github.com/rotmanjanez/check24-gendev-7/pkg/models"
@@ -77,11 +80,13 @@ type mockProviderAdapter struct {
 	returnProductsOnParse   bool
 	mockServer              *httptest.Server
 	product                 *models.InternetProduct
+	prepareCalls            atomic.Int32
 }
 
 func (m *mockProviderAdapter) PrepareRequest(ctx context.Context, req interfaces.Request) (interfaces.ParsedResponse, error) {
 	slog.Error("Preparing request for mock provider", "address", req.Address)
 	m.lastRequest = req
+	m.prepareCalls.Add(1)
 
 	response := interfaces.ParsedResponse{}
 
@@ -123,6 +128,11 @@ var _ interfaces.ProviderAdapter = &mockProviderAdapter{}
 
 // setupTestService creates a service with cache and mock provider for testing
 func setupTestService(mockProvider *mockProviderAdapter) (*mux.Router, *InternetProductsAPIController) {
+	return setupTestServiceWithPool(mockProvider, workerpool.New(4, 4))
+}
+
+// setupTestServiceWithPool creates a service running queries on pool
+func setupTestServiceWithPool(mockProvider *mockProviderAdapter, pool *workerpool.Pool) (*mux.Router, *InternetProductsAPIController) {
 	cacheInst := cache.NewInstanceCache("test-cache")
 	queueInst := cache.NewInstanceCache("test-queue")
 
@@ -131,6 +141,7 @@ func setupTestService(mockProvider *mockProviderAdapter) (*mux.Router, *Internet
 		cacheInst,
 		queueInst,
 		[]*provider.ProviderConfig{provider.NewProviderConfig(mockProvider, 0, 10*time.Minute /* when debugging is required*/, 1, 0)},
+		pool,
 	)
 
 	controller := NewInternetProductsAPIController(service)
@@ -306,3 +317,850 @@ func TestInitiateInternetProductsQuery_ProductsFromParseResponse(t *testing.T) {
 		t.Errorf("expected address street %s, got %s", validAddressDE.Street, mockProvider.lastRequest.Address.Street)
 	}
 }
+
+func TestInitiateInternetProductsQuery_SelectedProvider(t *testing.T) {
+	mockProvider := &mockProviderAdapter{}
+	_, controller := setupTestService(mockProvider)
+
+	req := createRequestFromAddress(validAddressDE)
+	req.URL.RawQuery = "providers=Mock"
+	w := httptest.NewRecorder()
+
+	controller.InitiateInternetProductsQuery(w, req)
+	resp := w.Result()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", resp.StatusCode)
+	}
+
+	time.Sleep(10 * time.Millisecond) // Give goroutine time to run
+	if mockProvider.lastRequest.Address.Street != validAddressDE.Street {
+		t.Errorf("expected selected provider to receive the request")
+	}
+}
+
+func TestInitiateInternetProductsQuery_UnknownProvider(t *testing.T) {
+	mockProvider := &mockProviderAdapter{}
+	_, controller := setupTestService(mockProvider)
+
+	req := createRequestFromAddress(validAddressDE)
+	req.URL.RawQuery = "providers=mock,ByteMe"
+	w := httptest.NewRecorder()
+
+	controller.InitiateInternetProductsQuery(w, req)
+	resp := w.Result()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 Bad Request, got %d", resp.StatusCode)
+	}
+
+	time.Sleep(10 * time.Millisecond) // Give goroutine time to run
+	if mockProvider.lastRequest.Address.Street != "" {
+		t.Errorf("expected no query to be started for unknown providers")
+	}
+}
+
+func TestGetInternetProductsQueryStatus(t *testing.T) {
+	mockProvider := &mockProviderAdapter{returnProductsOnPrepare: true}
+	router, controller := setupTestService(mockProvider)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internet-products/status?cursor=invalid", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request for invalid cursor, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internet-products/status?cursor=00000000-0000-0000-0000-000000000000", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 Not Found for unknown cursor, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	controller.InitiateInternetProductsQuery(w, createRequestFromAddress(validAddressDE))
+	var cursor models.InternetProductsCursor
+	if err := json.NewDecoder(w.Result().Body).Decode(&cursor); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+
+	time.Sleep(10 * time.Millisecond) // Give goroutine time to run
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internet-products/status?cursor="+cursor.NextCursor, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+
+	var status models.InternetProductsQueryStatus
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if !status.Complete || len(status.Providers) != 1 {
+		t.Fatalf("expected completed status for one provider, got %+v", status)
+	}
+	if got := status.Providers[0]; got.Provider != "mock" || got.State != models.COMPLETED || got.ProductCount != 1 {
+		t.Errorf("unexpected provider status %+v", got)
+	}
+}
+
+func TestInitiateInternetProductsQuery_CoalescesConcurrentQueries(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	mockProvider := &mockProviderAdapter{mockServer: mockServer, returnProductsOnParse: true}
+	router, controller := setupTestService(mockProvider)
+
+	initiate := func(address models.Address) string {
+		w := httptest.NewRecorder()
+		controller.InitiateInternetProductsQuery(w, createRequestFromAddress(address))
+		var result models.InternetProductsCursor
+		if err := json.NewDecoder(w.Result().Body).Decode(&result); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		return result.NextCursor
+	}
+
+	sameAddress := validAddressDE
+	sameAddress.Street = "  teststrasse "
+	first := initiate(validAddressDE)
+	second := initiate(sameAddress)
+	if first == second {
+		t.Fatalf("expected every query to get its own cursor")
+	}
+
+	time.Sleep(100 * time.Millisecond) // Give goroutine time to run
+	if calls := mockProvider.prepareCalls.Load(); calls != 1 {
+		t.Errorf("expected providers to be queried once, got %d", calls)
+	}
+
+	for _, cursor := range []string{first, second} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internet-products/continue?cursor="+cursor, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200 OK, got %d", w.Code)
+		}
+		var result models.InternetProductsResponse
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		if len(result.Products) != 1 {
+			t.Errorf("expected the shared product for cursor %s, got %v", cursor, result.Products)
+		}
+	}
+
+	// finished queries are not coalesced anymore
+	initiate(validAddressDE)
+	time.Sleep(100 * time.Millisecond) // Give goroutine time to run
+	if calls := mockProvider.prepareCalls.Load(); calls != 2 {
+		t.Errorf("expected a new query after the first finished, got %d calls", calls)
+	}
+}
+
+func TestInitiateInternetProductsQuery_QueueFull(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	mockProvider := &mockProviderAdapter{mockServer: mockServer}
+	_, controller := setupTestServiceWithPool(mockProvider, workerpool.New(1, 1))
+
+	// distinct addresses so that queries are not coalesced
+	codes := make([]int, 3)
+	for idx, street := range []string{"Erste Strasse", "Zweite Strasse", "Dritte Strasse"} {
+		address := validAddressDE
+		address.Street = street
+		w := httptest.NewRecorder()
+		controller.InitiateInternetProductsQuery(w, createRequestFromAddress(address))
+		codes[idx] = w.Code
+		if idx == 0 {
+			time.Sleep(10 * time.Millisecond) // Give the worker time to pick up the first query
+		}
+		if w.Code == http.StatusServiceUnavailable && w.Header().Get("Retry-After") == "" {
+			t.Errorf("expected Retry-After header on 503")
+		}
+	}
+
+	want := []int{http.StatusOK, http.StatusOK, http.StatusServiceUnavailable}
+	for idx := range want {
+		if codes[idx] != want[idx] {
+			t.Errorf("expected status codes %v, got %v", want, codes)
+			break
+		}
+	}
+}
+
+func TestInitiateInternetProductsQuery_RejectedLeader(t *testing.T) {
+	queue := cache.NewInstanceCache("test-queue")
+	service := newCancellableTestService(&mockProviderAdapter{}, queue)
+	router := NewRouter(NewInternetProductsAPIController(service))
+	ctx := context.Background()
+
+	// a follower attached while the leader was submitted to the full pool
+	leader, follower := uuid.New().String(), uuid.New().String()
+	key := coalesceKeyPrefix + coalesceKey(validAddressDE, service.rc.Names())
+	if attached, err := service.coalesce(ctx, key, leader); err != nil || attached != "" {
+		t.Fatalf("expected to hold the coalesce lock, got %q %v", attached, err)
+	}
+	if attached, err := service.coalesce(ctx, key, follower); err != nil || attached != leader {
+		t.Fatalf("expected to attach to the leader, got %q %v", attached, err)
+	}
+	service.reject(ctx, key, leader, models.InternetProductsQueryStatus{Providers: []models.ProviderStatus{{Provider: "mock", State: models.PENDING}}})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internet-products/continue?cursor="+follower, nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 503 with Retry-After for the follower, got %d", w.Code)
+	}
+	if exists, _ := queue.Get(ctx, key, new(models.InternetProductsCursor)); exists {
+		t.Errorf("expected the coalesce lock to be released")
+	}
+}
+
+func TestContinueInternetProductsQuery_SoftDeadline(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	mockProvider := &mockProviderAdapter{mockServer: mockServer, returnProductsOnParse: true}
+	cfg := provider.NewProviderConfig(mockProvider, 0, time.Second, 1, 0)
+	cfg.SoftDeadline = 20 * time.Millisecond
+	service := NewInternetProductsAPIService(nil, cache.NewInstanceCache("test-cache"), cache.NewInstanceCache("test-queue"), []*provider.ProviderConfig{cfg}, workerpool.New(1, 1))
+	router := NewRouter(NewInternetProductsAPIController(service))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, createRequestFromAddress(validAddressDE))
+	var cursor models.InternetProductsCursor
+	if err := json.NewDecoder(w.Body).Decode(&cursor); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+
+	continueQuery := func(cursor string) (int, models.InternetProductsResponse) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internet-products/continue?cursor="+cursor, nil))
+		var result models.InternetProductsResponse
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+		}
+		return w.Code, result
+	}
+
+	// the query is partial, but there are no products to return yet
+	time.Sleep(50 * time.Millisecond)
+	if code, _ := continueQuery(cursor.NextCursor); code != http.StatusAccepted {
+		t.Fatalf("expected 202 Accepted after the soft deadline, got %d", code)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	code, result := continueQuery(cursor.NextCursor)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", code)
+	}
+	if len(result.Products) != 1 || !result.Products[0].Late || !result.Partial || !result.Complete || result.NextCursor != "" {
+		t.Errorf("expected late product at the end of the query, got %+v", result)
+	}
+}
+
+func TestContinueInternetProductsQuery_Offset(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	mockProvider := &mockProviderAdapter{mockServer: mockServer, returnProductsOnPrepare: true, returnProductsOnParse: true}
+	router, controller := setupTestService(mockProvider)
+
+	w := httptest.NewRecorder()
+	controller.InitiateInternetProductsQuery(w, createRequestFromAddress(validAddressDE))
+	var cursor models.InternetProductsCursor
+	if err := json.NewDecoder(w.Result().Body).Decode(&cursor); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+
+	continueQuery := func(cursor string) models.InternetProductsResponse {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internet-products/continue?cursor="+cursor, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200 OK, got %d", w.Code)
+		}
+		var result models.InternetProductsResponse
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		return result
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	first := continueQuery(cursor.NextCursor)
+	if len(first.Products) != 1 || first.Complete || first.NextCursor != cursor.NextCursor+":1" {
+		t.Fatalf("expected the first product and a cursor past it, got %+v", first)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	second := continueQuery(first.NextCursor)
+	if len(second.Products) != 1 || !second.Complete || second.NextCursor != "" {
+		t.Errorf("expected only the second product at the end of the query, got %+v", second)
+	}
+	// the log is kept, so reading it again from the start returns all products
+	if all := continueQuery(cursor.NextCursor); len(all.Products) != 2 || !all.Complete {
+		t.Errorf("expected both products when reading from the start, got %+v", all)
+	}
+
+	for _, invalid := range []string{cursor.NextCursor + ":-1", cursor.NextCursor + ":x", "not-a-uuid:1"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internet-products/continue?cursor="+invalid, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 Bad Request for cursor %s, got %d", invalid, w.Code)
+		}
+	}
+}
+
+func TestContinueInternetProductsQuery_Wait(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	mockProvider := &mockProviderAdapter{mockServer: mockServer, returnProductsOnParse: true}
+	router, controller := setupTestService(mockProvider)
+
+	w := httptest.NewRecorder()
+	controller.InitiateInternetProductsQuery(w, createRequestFromAddress(validAddressDE))
+	var cursor models.InternetProductsCursor
+	if err := json.NewDecoder(w.Result().Body).Decode(&cursor); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+
+	// the product arrives long before the wait expires and the regular checks of the log
+	start := time.Now()
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internet-products/continue?wait=10&cursor="+cursor.NextCursor, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected to be notified of the new product, waited %v", elapsed)
+	}
+	var result models.InternetProductsResponse
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(result.Products) != 1 {
+		t.Errorf("expected the new product, got %+v", result)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internet-products/continue?wait=-1&cursor="+cursor.NextCursor, nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request for a negative wait, got %d", w.Code)
+	}
+}
+
+func TestContinueInternetProductsQuery_Filter(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	mockProvider := &mockProviderAdapter{mockServer: mockServer, returnProductsOnPrepare: true, returnProductsOnParse: true}
+	router, controller := setupTestService(mockProvider)
+
+	w := httptest.NewRecorder()
+	controller.InitiateInternetProductsQuery(w, createRequestFromAddress(validAddressDE))
+	var cursor models.InternetProductsCursor
+	if err := json.NewDecoder(w.Result().Body).Decode(&cursor); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+
+	continueQuery := func(params string) (int, models.InternetProductsResponse) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internet-products/continue?wait=2&cursor="+cursor.NextCursor+params, nil))
+		var result models.InternetProductsResponse
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+		}
+		return w.Code, result
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	// products filtered out are skipped until the query completes
+	if code, result := continueQuery("&minSpeed=101"); code != http.StatusOK || len(result.Products) != 0 || !result.Complete {
+		t.Errorf("expected no products matching the filter, got %d %+v", code, result)
+	}
+	if code, result := continueQuery("&minSpeed=100&provider=mock-provider&sort=price-asc"); code != http.StatusOK || len(result.Products) != 2 {
+		t.Errorf("expected both products to match the filter, got %d %+v", code, result)
+	}
+	if code, _ := continueQuery("&minSpeed=-1"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request for a negative minSpeed, got %d", code)
+	}
+}
+
+func TestShareInternetProducts_Filter(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	mockProvider := &mockProviderAdapter{mockServer: mockServer, returnProductsOnPrepare: true, returnProductsOnParse: true}
+	router, controller := setupTestService(mockProvider)
+
+	w := httptest.NewRecorder()
+	controller.InitiateInternetProductsQuery(w, createRequestFromAddress(validAddressDE))
+	var cursor models.InternetProductsCursor
+	if err := json.NewDecoder(w.Result().Body).Decode(&cursor); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+
+	share := func(body string) (int, string) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/internet-products/share/"+cursor.NextCursor, strings.NewReader(body)))
+		var link models.InternetProductsShare
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&link); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+		}
+		return w.Code, link.ShareId
+	}
+	getShared := func(id string, params string) models.SharedInternetProductsResponse {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internet-products/share/"+id+params, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200 OK, got %d", w.Code)
+		}
+		var result models.SharedInternetProductsResponse
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		return result
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	code, filtered := share(`{"minSpeed": 101, "sort": "price-asc"}`)
+	if code != http.StatusOK || filtered == "" {
+		t.Fatalf("expected 200 OK with a share id, got %d, %q", code, filtered)
+	}
+	result := getShared(filtered, "")
+	if result.Filter == nil || *result.Filter.MinSpeed != 101 || result.Filter.Sort != models.PRICE_ASC || len(result.Products) != 0 {
+		t.Errorf("expected the shared filter without matching products, got %+v", result)
+	}
+	// the recipient can still change the view
+	if result := getShared(filtered, "?minSpeed=100"); result.Filter == nil || *result.Filter.MinSpeed != 100 || len(result.Products) != 2 {
+		t.Errorf("expected the filter of the request to replace the shared filter, got %+v", result)
+	}
+
+	// every share has its own view, earlier links are not changed by sharing again
+	_, unfiltered := share("")
+	_, byProvider := share(`{"provider": ["mock-provider"]}`)
+	if unfiltered == filtered || byProvider == filtered || byProvider == unfiltered {
+		t.Fatalf("expected a new id for every share, got %q, %q and %q", filtered, unfiltered, byProvider)
+	}
+	if result := getShared(unfiltered, ""); result.Filter != nil || len(result.Products) != 2 {
+		t.Errorf("expected all products without filter, got %+v", result)
+	}
+	if result := getShared(byProvider, ""); result.Filter == nil || len(result.Filter.Provider) != 1 || len(result.Products) != 2 {
+		t.Errorf("expected the filter of the latest share, got %+v", result)
+	}
+	if result := getShared(filtered, ""); result.Filter == nil || result.Filter.MinSpeed == nil || *result.Filter.MinSpeed != 101 {
+		t.Errorf("expected the first share to keep its filter, got %+v", result)
+	}
+	// the cursor still shares all products
+	if result := getShared(cursor.NextCursor, ""); result.Filter != nil || len(result.Products) != 2 {
+		t.Errorf("expected all products for the cursor, got %+v", result)
+	}
+
+	for _, invalid := range []string{`{"minSpeed": -1}`, `{"sort": "cheapest"}`, `{"unknown": true}`, `[`} {
+		if code, _ := share(invalid); code != http.StatusBadRequest {
+			t.Errorf("expected 400 Bad Request for %s, got %d", invalid, code)
+		}
+	}
+}
+
+// readEvents parses the server-sent events of a result stream
+func readEvents(t *testing.T, body string) []StreamEvent {
+	t.Helper()
+	var events []StreamEvent
+	for _, block := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		var event StreamEvent
+		var data string
+		for _, line := range strings.Split(block, "\n") {
+			field, value, _ := strings.Cut(line, ": ")
+			switch field {
+			case "id":
+				event.ID = value
+			case "event":
+				event.Event = value
+			case "data":
+				data = value
+			}
+		}
+		event.Data = data
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestStreamInternetProducts(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	mockProvider := &mockProviderAdapter{mockServer: mockServer, returnProductsOnPrepare: true, returnProductsOnParse: true}
+	router, controller := setupTestService(mockProvider)
+
+	w := httptest.NewRecorder()
+	controller.InitiateInternetProductsQuery(w, createRequestFromAddress(validAddressDE))
+	var cursor models.InternetProductsCursor
+	if err := json.NewDecoder(w.Result().Body).Decode(&cursor); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+
+	stream := func(lastEventID string) []StreamEvent {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/internet-products/stream?cursor="+cursor.NextCursor, nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		// the stream ends once the query completed
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200 OK, got %d", w.Code)
+		}
+		if contentType := w.Header().Get("Content-Type"); contentType != "text/event-stream" {
+			t.Errorf("expected an event stream, got %s", contentType)
+		}
+		return readEvents(t, w.Body.String())
+	}
+
+	events := stream("")
+	var types []string
+	for _, event := range events {
+		types = append(types, event.Event)
+	}
+	if strings.Join(types, ",") != "product,product,provider-status,complete" {
+		t.Fatalf("expected both products, the provider status and the completion, got %v", types)
+	}
+	if events[0].ID != "1" || events[1].ID != "2" || events[3].ID != "2" {
+		t.Errorf("expected the offsets as event ids, got %+v", events)
+	}
+	var status models.ProviderStatus
+	if err := json.Unmarshal([]byte(events[2].Data.(string)), &status); err != nil || status.State != models.COMPLETED {
+		t.Errorf("expected the provider to be completed, got %v (%v)", events[2].Data, err)
+	}
+
+	// a reconnecting client only receives the products it missed
+	resumed := stream("1")
+	if len(resumed) != 3 || resumed[0].Event != "product" || resumed[0].ID != "2" || resumed[2].Event != "complete" {
+		t.Errorf("expected only the second product when resuming, got %+v", resumed)
+	}
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/internet-products/stream?cursor="+cursor.NextCursor, nil)
+	req.Header.Set("Last-Event-ID", "x")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 Bad Request for an invalid Last-Event-ID, got %d", w.Code)
+	}
+}
+
+// newCancellableTestService creates a service that notices cancelled and abandoned queries quickly
+func newCancellableTestService(mockProvider *mockProviderAdapter, queue interfaces.QueueCache) *InternetProductsAPIService {
+	service := NewInternetProductsAPIService(
+		nil,
+		cache.NewInstanceCache("test-cache"),
+		queue,
+		[]*provider.ProviderConfig{provider.NewProviderConfig(mockProvider, 0, 10*time.Second, 1, 0)},
+		workerpool.New(1, 1),
+	)
+	service.pollInterval = 10 * time.Millisecond
+	return service
+}
+
+// newBlockingServer creates a provider server that only answers once the request is aborted,
+// closing aborted when it is
+func newBlockingServer(aborted chan struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			close(aborted)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+}
+
+func TestCancelInternetProductsQuery_OtherInstance(t *testing.T) {
+	aborted := make(chan struct{})
+	mockServer := newBlockingServer(aborted)
+	defer mockServer.Close()
+
+	// both instances share the queue, like two replicas sharing Redis
+	queue := cache.NewInstanceCache("test-queue")
+	mockProvider := &mockProviderAdapter{mockServer: mockServer, returnProductsOnParse: true}
+	running := NewRouter(NewInternetProductsAPIController(newCancellableTestService(mockProvider, queue)))
+	other := NewRouter(NewInternetProductsAPIController(newCancellableTestService(mockProvider, queue)))
+
+	w := httptest.NewRecorder()
+	running.ServeHTTP(w, createRequestFromAddress(validAddressDE))
+	var cursor models.InternetProductsCursor
+	if err := json.NewDecoder(w.Body).Decode(&cursor); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	other.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/internet-products/"+cursor.NextCursor, nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 No Content, got %d", w.Code)
+	}
+
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the provider request to be aborted")
+	}
+	time.Sleep(50 * time.Millisecond) // Give the query time to complete its result log
+
+	w = httptest.NewRecorder()
+	other.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internet-products/continue?cursor="+cursor.NextCursor, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+	var result models.InternetProductsResponse
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if !result.Cancelled || result.NextCursor != "" {
+		t.Errorf("expected the result log to end cancelled, got %+v", result)
+	}
+
+	w = httptest.NewRecorder()
+	other.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internet-products/status?cursor="+cursor.NextCursor, nil))
+	var status models.InternetProductsQueryStatus
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if !status.Cancelled || !status.Complete || status.Providers[0].State != models.CANCELLED {
+		t.Errorf("expected cancelled query status, got %+v", status)
+	}
+}
+
+func TestCancelInternetProductsQuery_Coalesced(t *testing.T) {
+	aborted := make(chan struct{})
+	mockServer := newBlockingServer(aborted)
+	defer mockServer.Close()
+
+	mockProvider := &mockProviderAdapter{mockServer: mockServer, returnProductsOnParse: true}
+	router := NewRouter(NewInternetProductsAPIController(newCancellableTestService(mockProvider, cache.NewInstanceCache("test-queue"))))
+
+	initiate := func() string {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, createRequestFromAddress(validAddressDE))
+		var cursor models.InternetProductsCursor
+		if err := json.NewDecoder(w.Body).Decode(&cursor); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		return cursor.NextCursor
+	}
+	cancel := func(cursor string) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/internet-products/"+cursor, nil))
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected 204 No Content, got %d", w.Code)
+		}
+	}
+	leader, follower := initiate(), initiate()
+
+	// the follower still waits for the results
+	cancel(leader)
+	select {
+	case <-aborted:
+		t.Fatalf("expected the query to keep running for the follower")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel(follower)
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the query to be cancelled once all cursors cancelled")
+	}
+	time.Sleep(50 * time.Millisecond) // Give the query time to store its results
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internet-products/share/"+leader, nil))
+	var shared models.SharedInternetProductsResponse
+	if err := json.NewDecoder(w.Body).Decode(&shared); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if !shared.Cancelled {
+		t.Errorf("expected the shared results to be marked cancelled, got %+v", shared)
+	}
+}
+
+func TestCancelInternetProductsQuery_CompletedFollower(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	mockProvider := &mockProviderAdapter{mockServer: mockServer, returnProductsOnParse: true}
+	router, controller := setupTestService(mockProvider)
+
+	initiate := func() string {
+		w := httptest.NewRecorder()
+		controller.InitiateInternetProductsQuery(w, createRequestFromAddress(validAddressDE))
+		var result models.InternetProductsCursor
+		if err := json.NewDecoder(w.Result().Body).Decode(&result); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		return result.NextCursor
+	}
+	initiate()
+	follower := initiate()
+	time.Sleep(100 * time.Millisecond) // Give the query time to complete
+
+	// cancelling a completed query changes nothing
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/internet-products/"+follower, nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 No Content, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internet-products/continue?cursor="+follower, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+	var result models.InternetProductsResponse
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(result.Products) != 1 {
+		t.Errorf("expected the product of the completed query, got %v", result.Products)
+	}
+}
+
+func TestCancelInternetProductsQuery_UnknownCursor(t *testing.T) {
+	router, _ := setupTestService(&mockProviderAdapter{})
+
+	for cursor, want := range map[string]int{
+		"not-a-uuid":                           http.StatusBadRequest,
+		"6f1c1f4e-2f4b-4a55-9a8e-0c5f3d2b1a90": http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/internet-products/"+cursor, nil))
+		if w.Code != want {
+			t.Errorf("expected %d for cursor %s, got %d", want, cursor, w.Code)
+		}
+	}
+}
+
+func TestContinueInternetProductsQuery_InactivityTimeout(t *testing.T) {
+	aborted := make(chan struct{})
+	mockServer := newBlockingServer(aborted)
+	defer mockServer.Close()
+
+	service := newCancellableTestService(&mockProviderAdapter{mockServer: mockServer}, cache.NewInstanceCache("test-queue"))
+	service.inactivityTimeout = 100 * time.Millisecond
+	router := NewRouter(NewInternetProductsAPIController(service))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, createRequestFromAddress(validAddressDE))
+	var cursor models.InternetProductsCursor
+	if err := json.NewDecoder(w.Body).Decode(&cursor); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+
+	// polling keeps the query alive
+	for range 4 {
+		time.Sleep(50 * time.Millisecond)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internet-products/continue?cursor="+cursor.NextCursor, nil))
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("expected query to be running while polled, got %d", w.Code)
+		}
+	}
+
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the abandoned query to be cancelled")
+	}
+}
+
+func TestInitiateInternetProductsQuery_HandedOverOnShutdown(t *testing.T) {
+	var calls atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first instance shuts down while its request is in flight
+		if calls.Add(1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	// both instances share the queue, like two replicas sharing Redis
+	queue := cache.NewInstanceCache("test-queue")
+	mockProvider := &mockProviderAdapter{mockServer: mockServer, returnProductsOnParse: true}
+	stopping := newCancellableTestService(mockProvider, queue)
+	remaining := newCancellableTestService(mockProvider, queue)
+	router := NewRouter(NewInternetProductsAPIController(remaining))
+
+	w := httptest.NewRecorder()
+	NewRouter(NewInternetProductsAPIController(stopping)).ServeHTTP(w, createRequestFromAddress(validAddressDE))
+	var cursor models.InternetProductsCursor
+	if err := json.NewDecoder(w.Body).Decode(&cursor); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+
+	time.Sleep(50 * time.Millisecond) // Give the query time to send its request
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := stopping.Shutdown(ctx); err != nil {
+		t.Fatalf("expected query to be handed over, got %v", err)
+	}
+
+	queries, err := remaining.checkpoints.Claim(ctx, adoptBatchSize)
+	if err != nil || len(queries) != 1 || queries[0].Cursor != cursor.NextCursor {
+		t.Fatalf("expected the handed over query to be claimed, got %v, %v", queries, err)
+	}
+	remaining.resume(queries[0])
+	time.Sleep(100 * time.Millisecond) // Give the resumed query time to finish
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internet-products/continue?cursor="+cursor.NextCursor, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 OK, got %d", w.Code)
+	}
+	var result models.InternetProductsResponse
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(result.Products) != 1 || result.NextCursor != "" || result.Cancelled {
+		t.Errorf("expected the resumed query to complete the result log, got %+v", result)
+	}
+	if calls := mockProvider.prepareCalls.Load(); calls != 1 {
+		t.Errorf("expected the provider to be prepared once, got %d", calls)
+	}
+	if calls := calls.Load(); calls != 2 {
+		t.Errorf("expected the abandoned request to be sent again, got %d calls", calls)
+	}
+
+	if queries, _ := remaining.checkpoints.Claim(ctx, adoptBatchSize); len(queries) != 0 {
+		t.Errorf("expected no checkpoint after the query finished, got %v", queries)
+	}
+}
@@ -12,7 +12,11 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/gorilla/mux"
@@ -63,6 +67,21 @@ func (c *InternetProductsAPIController) Routes() Routes {
 			"/internet-products/continue",
 			c.ContinueInternetProductsQuery,
 		},
+		"StreamInternetProducts": Route{
+			strings.ToUpper("Get"),
+			"/internet-products/stream",
+			c.StreamInternetProducts,
+		},
+		"GetInternetProductsQueryStatus": Route{
+			strings.ToUpper("Get"),
+			"/internet-products/status",
+			c.GetInternetProductsQueryStatus,
+		},
+		"CancelInternetProductsQuery": Route{
+			strings.ToUpper("Delete"),
+			"/internet-products/{cursor}",
+			c.CancelInternetProductsQuery,
+		},
 		"GetSharedInternetProducts": Route{
 			strings.ToUpper("Get"),
 			"/internet-products/share/{cursor}",
@@ -128,7 +147,179 @@ func (c *InternetProductsAPIController) ContinueInternetProductsQuery(w http.Res
 		c.errorHandler(w, r, &models.RequiredError{Field: "cursor"}, nil)
 		return
 	}
-	result, err := c.service.ContinueInternetProductsQuery(r.Context(), cursorParam)
+	var waitParam int32
+	if query.Has("wait") {
+		param, err := parseNumericParameter[int32](
+			query.Get("wait"),
+			WithParse[int32](parseInt32),
+			WithMinimum[int32](0),
+		)
+		if err != nil {
+			c.errorHandler(w, r, &ParsingError{Param: "wait", Err: err}, nil)
+			return
+		}
+
+		waitParam = param
+	}
+	filterParam, err := parseInternetProductsFilter(query)
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.ContinueInternetProductsQuery(r.Context(), cursorParam, waitParam, filterParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	_ = EncodeJSONResponse(result.Body, &result.Code, w, result.Headers)
+}
+
+// parseInternetProductsFilter parses the filter and sort order query parameters shared by the product listings
+func parseInternetProductsFilter(query url.Values) (models.InternetProductsFilter, error) {
+	var filter models.InternetProductsFilter
+	// parameters are checked in a fixed order, so the first invalid one is always reported
+	for _, param := range []struct {
+		name  string
+		field **int32
+	}{
+		{"minSpeed", &filter.MinSpeed},
+		{"maxMonthlyCost", &filter.MaxMonthlyCost},
+		{"maxContractDuration", &filter.MaxContractDuration},
+	} {
+		name, field := param.name, param.field
+		if !query.Has(name) {
+			continue
+		}
+		param, err := parseNumericParameter[int32](
+			query.Get(name),
+			WithRequire[int32](parseInt32),
+			WithMinimum[int32](0),
+		)
+		if err != nil {
+			return filter, &ParsingError{Param: name, Err: err}
+		}
+		*field = &param
+	}
+	for _, param := range []struct {
+		name  string
+		field **bool
+	}{
+		{"tvIncluded", &filter.TvIncluded},
+		{"installationIncluded", &filter.InstallationIncluded},
+	} {
+		name, field := param.name, param.field
+		if !query.Has(name) {
+			continue
+		}
+		param, err := parseBoolParameter(query.Get(name), WithRequire[bool](parseBool))
+		if err != nil {
+			return filter, &ParsingError{Param: name, Err: err}
+		}
+		*field = &param
+	}
+	if query.Has("connectionTypes") {
+		for _, value := range strings.Split(query.Get("connectionTypes"), ",") {
+			connectionType, err := models.NewConnectionTypeFromValue(value)
+			if err != nil {
+				return filter, &ParsingError{Param: "connectionTypes", Err: err}
+			}
+			filter.ConnectionTypes = append(filter.ConnectionTypes, connectionType)
+		}
+	}
+	if query.Has("provider") {
+		filter.Provider = strings.Split(query.Get("provider"), ",")
+	}
+	if query.Has("sort") {
+		sort, err := models.NewInternetProductsSortOrderFromValue(query.Get("sort"))
+		if err != nil {
+			return filter, &ParsingError{Param: "sort", Err: err}
+		}
+		filter.Sort = sort
+	}
+	return filter, nil
+}
+
+// StreamInternetProducts -
+func (c *InternetProductsAPIController) StreamInternetProducts(w http.ResponseWriter, r *http.Request) {
+	query, err := parseQuery(r.URL.RawQuery)
+	if err != nil {
+		c.errorHandler(w, r, &ParsingError{Err: err}, nil)
+		return
+	}
+	var cursorParam string
+	if query.Has("cursor") {
+		param := query.Get("cursor")
+
+		cursorParam = param
+	} else {
+		c.errorHandler(w, r, &models.RequiredError{Field: "cursor"}, nil)
+		return
+	}
+	lastEventIDParam := r.Header.Get("Last-Event-ID")
+	result, err := c.service.StreamInternetProducts(r.Context(), cursorParam, lastEventIDParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	events, ok := result.Body.(<-chan StreamEvent)
+	if !ok {
+		_ = EncodeJSONResponse(result.Body, &result.Code, w, result.Headers)
+		return
+	}
+	// If no error, stream the events with the result code
+	_ = EncodeEventStream(events, &result.Code, w)
+}
+
+// EncodeEventStream writes events to the http response as server-sent events, flushing after every event.
+// It returns once events is closed.
+func EncodeEventStream(events <-chan StreamEvent, status *int, w http.ResponseWriter) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return errors.New("response writer does not support streaming")
+	}
+	wHeader := w.Header()
+	wHeader.Set("Content-Type", "text/event-stream")
+	wHeader.Set("Cache-Control", "no-cache")
+	if status != nil {
+		w.WriteHeader(*status)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	flusher.Flush()
+
+	for event := range events {
+		data, err := json.Marshal(event.Data)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Event, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+	}
+	return nil
+}
+
+// GetInternetProductsQueryStatus -
+func (c *InternetProductsAPIController) GetInternetProductsQueryStatus(w http.ResponseWriter, r *http.Request) {
+	query, err := parseQuery(r.URL.RawQuery)
+	if err != nil {
+		c.errorHandler(w, r, &ParsingError{Err: err}, nil)
+		return
+	}
+	var cursorParam string
+	if query.Has("cursor") {
+		param := query.Get("cursor")
+
+		cursorParam = param
+	} else {
+		c.errorHandler(w, r, &models.RequiredError{Field: "cursor"}, nil)
+		return
+	}
+	result, err := c.service.GetInternetProductsQueryStatus(r.Context(), cursorParam)
 	// If an error occurred, encode the error with the status code
 	if err != nil {
 		c.errorHandler(w, r, err, &result)
@@ -146,7 +337,35 @@ func (c *InternetProductsAPIController) GetSharedInternetProducts(w http.Respons
 		c.errorHandler(w, r, &models.RequiredError{Field: "cursor"}, nil)
 		return
 	}
-	result, err := c.service.GetSharedInternetProducts(r.Context(), cursorParam)
+	query, err := parseQuery(r.URL.RawQuery)
+	if err != nil {
+		c.errorHandler(w, r, &ParsingError{Err: err}, nil)
+		return
+	}
+	filterParam, err := parseInternetProductsFilter(query)
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.GetSharedInternetProducts(r.Context(), cursorParam, filterParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	_ = EncodeJSONResponse(result.Body, &result.Code, w, result.Headers)
+}
+
+// CancelInternetProductsQuery -
+func (c *InternetProductsAPIController) CancelInternetProductsQuery(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	cursorParam := params["cursor"]
+	if cursorParam == "" {
+		c.errorHandler(w, r, &models.RequiredError{Field: "cursor"}, nil)
+		return
+	}
+	result, err := c.service.CancelInternetProductsQuery(r.Context(), cursorParam)
 	// If an error occurred, encode the error with the status code
 	if err != nil {
 		c.errorHandler(w, r, err, &result)
@@ -164,7 +383,21 @@ func (c *InternetProductsAPIController) ShareInternetProducts(w http.ResponseWri
 		c.errorHandler(w, r, &models.RequiredError{Field: "cursor"}, nil)
 		return
 	}
-	result, err := c.service.ShareInternetProducts(r.Context(), cursorParam)
+	var filterParam *models.InternetProductsFilter
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	// the filter is optional, links without filter share all products
+	if err := d.Decode(&filterParam); err != nil && !errors.Is(err, io.EOF) {
+		c.errorHandler(w, r, &ParsingError{Err: err}, nil)
+		return
+	}
+	if filterParam != nil {
+		if err := models.AssertInternetProductsFilterConstraints(*filterParam); err != nil {
+			c.errorHandler(w, r, &ParsingError{Err: err}, nil)
+			return
+		}
+	}
+	result, err := c.service.ShareInternetProducts(r.Context(), cursorParam, filterParam)
 	// If an error occurred, encode the error with the status code
 	if err != nil {
 		c.errorHandler(w, r, err, &result)
@@ -10,15 +10,23 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/rotmanjanez/check24-gendev-7/config"
 	"github.com/rotmanjanez/check24-gendev-7/internal/requestmanager"
+	"github.com/rotmanjanez/check24-gendev-7/internal/workerpool"
+	"github.com/rotmanjanez/check24-gendev-7/pkg/checkpoint"
 	i "github.com/rotmanjanez/check24-gendev-7/pkg/interfaces"
 	m "github.com/rotmanjanez/check24-gendev-7/pkg/models"
 	p "github.com/rotmanjanez/check24-gendev-7/pkg/provider"
@@ -30,126 +38,762 @@ import (
 type InternetProductsAPIService struct {
 	config *config.Config
 	cache  i.Cache
-	queue  i.Cache
+	queue  i.QueueCache
 	rc     *requestmanager.RequestCoordinator
+	pool   *workerpool.Pool
+
+	inactivityTimeout time.Duration
+	pollInterval      time.Duration
+
+	// checkpoints is nil if the queue does not support checkpoints
+	checkpoints *checkpoint.Store
+	shutdown    context.Context
+	stop        context.CancelCauseFunc
+	running     sync.WaitGroup // queries running on this instance
+
+	// streams is done once open result streams should be closed, see CloseStreams
+	streams      context.Context
+	closeStreams context.CancelFunc
+}
+
+// StreamEvent is a server-sent event of the result stream of a query
+type StreamEvent struct {
+	// ID is the offset in the result log after the event, clients resume the stream from it with Last-Event-ID
+	ID    string
+	Event string
+	Data  any
+}
+
+// logState is the state of the result log of a query as stored in the queue.
+// It is written after the products it covers were appended, so a complete log holds all products of the query.
+type logState struct {
+	// Partial is set once all providers finished or missed their soft deadline, following products are late
+	Partial bool `json:"partial,omitempty"`
+
+	// Complete is set once the query finished, no further products are appended
+	Complete bool `json:"complete,omitempty"`
+
+	// Cancelled is set if the query was cancelled or abandoned before all providers finished
+	Cancelled bool `json:"cancelled,omitempty"`
+
+	// Rejected is set if the query was never started because all workers were busy
+	Rejected bool `json:"rejected,omitempty"`
+}
+
+func (l logState) MarshalBinary() ([]byte, error) {
+	return json.Marshal(l)
+}
+
+func (l *logState) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, l)
+}
+
+// shareLink is a shared view of the products of a query as stored in the cache
+type shareLink struct {
+	// Cursor is the cursor the query was initiated with, the key of its shared products
+	Cursor string `json:"cursor"`
+
+	// Filter is applied to the products unless the recipient sets its own, all products are shared without
+	Filter *m.InternetProductsFilter `json:"filter,omitempty"`
+}
+
+func (l shareLink) MarshalBinary() ([]byte, error) {
+	return json.Marshal(l)
+}
+
+func (l *shareLink) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, l)
+}
+
+// resultCursor points into the result log of a query
+type resultCursor struct {
+	// Query is the cursor the query was initiated with
+	Query string
+	// Offset is the number of products already read
+	Offset int64
+}
+
+// parseCursor parses cursors of the form "<query>" or "<query>:<offset>"
+func parseCursor(cursor string) (resultCursor, error) {
+	query, offset, found := strings.Cut(cursor, ":")
+	if _, err := uuid.Parse(query); err != nil {
+		return resultCursor{}, errors.New("invalid cursor")
+	}
+	if !found {
+		return resultCursor{Query: query}, nil
+	}
+	n, err := strconv.ParseInt(offset, 10, 64)
+	if err != nil || n < 0 {
+		return resultCursor{}, errors.New("invalid cursor")
+	}
+	return resultCursor{Query: query, Offset: n}, nil
+}
+
+func (c resultCursor) String() string {
+	if c.Offset == 0 {
+		return c.Query
+	}
+	return c.Query + ":" + strconv.FormatInt(c.Offset, 10)
 }
 
 const persistIndicator string = "indicator-persist"
-const workInProgressIndicator string = "indicator-work-in-progress"
+const statusKeyPrefix string = "status:"
+const logKeyPrefix string = "log:"
+const logStateKeyPrefix string = "log-state:"
+const coalesceKeyPrefix string = "coalesce:"
+const aliasKeyPrefix string = "alias:"
+const cancelKeyPrefix string = "cancel:"
+const activityKeyPrefix string = "activity:"
+const followersKeyPrefix string = "followers:"
+const detachedKeyPrefix string = "detached:"
+const shareKeyPrefix string = "share:"
+
+// resultTTL is how long the result log of a query is kept after the last product was appended
+const resultTTL = 1 * time.Hour
+
+// maxContinueWait caps how long a continue request waits for new products
+const maxContinueWait = 25 * time.Second
+
+// queryTimeout limits how long the providers are queried for a single query
+const queryTimeout = 60 * time.Second
+
+// defaultInactivityTimeout cancels queries whose results were not polled for this long
+const defaultInactivityTimeout = 30 * time.Second
+
+// cancelPollInterval is how often a running query checks whether it was cancelled or abandoned
+const cancelPollInterval = time.Second
+
+// checkpointInterval is how often running queries are checkpointed while no products arrive
+const checkpointInterval = 2 * time.Second
+
+// checkpointLeaseTTL is how long a query is owned by an instance after its last checkpoint,
+// afterwards it is continued by another instance
+const checkpointLeaseTTL = 10 * time.Second
+
+// adoptInterval is how often instances look for queries of instances that shut down or crashed
+const adoptInterval = 5 * time.Second
+
+// adoptBatchSize is the maximum number of queries an instance takes over at once
+const adoptBatchSize = 4
+
+// errShuttingDown is the cause of queries stopped by Shutdown
+var errShuttingDown = errors.New("instance shutting down")
+
+// retryAfterOverloaded is the suggested wait in seconds for clients rejected due to load
+const retryAfterOverloaded = "5"
 
 // NewInternetProductsAPIService creates a default api service
-func NewInternetProductsAPIService(cfg *config.Config, cache i.Cache, queue i.Cache, providers []*p.ProviderConfig) *InternetProductsAPIService {
-	return &InternetProductsAPIService{
-		config: cfg,
-		cache:  cache,
-		queue:  queue,
-		rc:     requestmanager.NewRequestCoordinator(providers),
+// Queries are run on pool. If queue supports leases, running queries are checkpointed to it
+// and queries of other instances that shut down or crashed are continued.
+// If the work queue is enabled, provider requests are sent as jobs through queue.
+func NewInternetProductsAPIService(cfg *config.Config, cache i.Cache, queue i.QueueCache, providers []*p.ProviderConfig, pool *workerpool.Pool) *InternetProductsAPIService {
+	inactivityTimeout := defaultInactivityTimeout
+	if cfg != nil && cfg.QueryInactivityTimeout > 0 {
+		inactivityTimeout = cfg.QueryInactivityTimeout
 	}
+	shutdown, stop := context.WithCancelCause(context.Background())
+	streams, closeStreams := context.WithCancel(context.Background())
+	s := &InternetProductsAPIService{
+		config:            cfg,
+		cache:             cache,
+		queue:             queue,
+		rc:                requestmanager.NewRequestCoordinator(providers),
+		pool:              pool,
+		inactivityTimeout: inactivityTimeout,
+		pollInterval:      cancelPollInterval,
+		checkpoints:       checkpoint.NewStore(queue, uuid.New().String(), checkpointLeaseTTL),
+		shutdown:          shutdown,
+		stop:              stop,
+		streams:           streams,
+		closeStreams:      closeStreams,
+	}
+	if s.checkpoints != nil {
+		go s.adoptOrphans()
+	}
+	if cfg != nil && cfg.WorkQueue.Enabled {
+		s.distribute(cfg.WorkQueue)
+	}
+	return s
 }
 
-func (s *InternetProductsAPIService) ContinueInternetProductsQuery(ctx context.Context, cursor string) (ImplResponse, error) {
-	// check if the cursor is a valid UUID
-	if _, err := uuid.Parse(cursor); err != nil {
-		return Response(http.StatusBadRequest, nil), errors.New("invalid cursor")
+// distribute sends the provider requests of all queries through a work queue on the queue cache
+// and starts the workers of this instance
+func (s *InternetProductsAPIService) distribute(cfg config.WorkQueueConfig) {
+	jobs, ok := s.queue.(i.JobQueue)
+	if !ok {
+		slog.Warn("Queue cache does not support jobs, requests are not distributed")
+		return
+	}
+	s.rc = s.rc.WithWorkQueue(requestmanager.NewWorkQueue(jobs, cfg))
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		s.rc.Work(s.shutdown)
+	}()
+}
+
+// ContinueInternetProductsQuery returns the products appended to the result log of a query since cursor
+// that match filter, each batch is sorted on its own. If there are none yet, it waits up to wait seconds
+// for new matching products or the query to complete.
+func (s *InternetProductsAPIService) ContinueInternetProductsQuery(ctx context.Context, cursor string, wait int32, filter m.InternetProductsFilter) (ImplResponse, error) {
+	c, err := parseCursor(cursor)
+	if err != nil {
+		return Response(http.StatusBadRequest, nil), err
 	}
 
-	allProducts := m.SharedInternetProductsResponse{}
-	foundAny := false
+	query, err := s.resolveCursor(ctx, c.Query)
+	if err != nil {
+		return Response(http.StatusInternalServerError, nil), err
+	}
 
-	for len(cursor) > 0 {
-		products := new(m.InternetProductsResponse)
-		exists, err := s.queue.Get(ctx, cursor, products)
+	waitCtx, cancel := context.WithTimeout(ctx, min(time.Duration(wait)*time.Second, maxContinueWait))
+	defer cancel()
+	updates := s.subscribe(waitCtx, query, wait > 0)
+	// notifications may be lost, so the log is checked regularly as well
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	touched := false
+	var state *logState
+	var products []m.InternetProduct
+	offset := c.Offset
+	for {
+		// the state is read before the products, so that a complete log is read in full
+		state = new(logState)
+		exists, err := s.queue.Get(ctx, logStateKeyPrefix+query, state)
 		if err != nil {
-			slog.Error("Error getting products from cache", "error", err)
+			slog.Error("Error getting result log state from cache", "error", err)
 			return Response(http.StatusInternalServerError, nil), err
 		}
 		if !exists {
+			return Response(http.StatusNotFound, nil), errors.New("products not found")
+		}
+		if state.Rejected {
+			return rejectedResponse()
+		}
+		if !touched {
+			// polling keeps the query alive
+			s.touch(ctx, query)
+			touched = true
+		}
+
+		read, err := s.readLog(ctx, query, offset)
+		if err != nil {
+			slog.Error("Error getting products from cache", "error", err)
+			return Response(http.StatusInternalServerError, nil), err
+		}
+		// products not matching the filter are skipped by the cursor as well
+		offset += int64(len(read))
+		products = filterProducts(read, filter)
+		if len(products) > 0 || state.Complete {
 			break
 		}
 
-		if products.NextCursor == workInProgressIndicator {
-			if !foundAny {
-				return Response(http.StatusAccepted, nil, map[string]string{"Retry-After": "3"}), nil // 3 seconds suggested
+		select {
+		case <-updates:
+		case <-ticker.C:
+		case <-waitCtx.Done():
+			if wait > 0 {
+				// the client waited already, it may poll again right away
+				return Response(http.StatusAccepted, nil, map[string]string{"Retry-After": "0"}), nil
 			}
-			break
+			return Response(http.StatusAccepted, nil, map[string]string{"Retry-After": "3"}), nil // 3 seconds suggested
 		}
+	}
+
+	next := ""
+	if !state.Complete {
+		next = resultCursor{Query: c.Query, Offset: offset}.String()
+	}
+	return Response(http.StatusOK, &m.InternetProductsResponse{
+		Products:   products,
+		NextCursor: next,
+		Partial:    state.Partial,
+		Complete:   state.Complete,
+		Cancelled:  state.Cancelled,
+	}), nil
+}
 
-		foundAny = true
-		allProducts.Products = append(allProducts.Products, products.Products...)
-		cursor = products.NextCursor
+// StreamInternetProducts streams the products appended to the result log of a query since cursor
+// and the terminal states of its providers as server-sent events. If lastEventID is set, the stream
+// resumes after the product with that offset. The body of the response is a <-chan StreamEvent
+// that is closed once the query completed or ctx is done.
+func (s *InternetProductsAPIService) StreamInternetProducts(ctx context.Context, cursor string, lastEventID string) (ImplResponse, error) {
+	c, err := parseCursor(cursor)
+	if err != nil {
+		return Response(http.StatusBadRequest, nil), err
+	}
+	if lastEventID != "" {
+		offset, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || offset < 0 {
+			return Response(http.StatusBadRequest, nil), errors.New("invalid Last-Event-ID")
+		}
+		c.Offset = offset
 	}
 
-	if !foundAny {
+	query, err := s.resolveCursor(ctx, c.Query)
+	if err != nil {
+		return Response(http.StatusInternalServerError, nil), err
+	}
+	state := new(logState)
+	exists, err := s.queue.Get(ctx, logStateKeyPrefix+query, state)
+	if err != nil {
+		slog.Error("Error getting result log state from cache", "error", err)
+		return Response(http.StatusInternalServerError, nil), err
+	}
+	if !exists {
 		return Response(http.StatusNotFound, nil), errors.New("products not found")
 	}
+	if state.Rejected {
+		return rejectedResponse()
+	}
 
-	return Response(http.StatusOK, &m.InternetProductsResponse{
-		Products:   allProducts.Products,
-		NextCursor: cursor,
-	}), nil
+	events := make(chan StreamEvent)
+	go s.stream(ctx, query, c.Offset, events)
+	return Response(http.StatusOK, (<-chan StreamEvent)(events)), nil
 }
 
-func (s *InternetProductsAPIService) processRequest(ctx context.Context, address m.Address, cursor string) {
-	prods, errs := s.rc.Run(ctx, i.Request{
-		Address: address,
-	}, 10, 10)
+// stream sends the products of the result log of query from offset on to events, followed by
+// a complete event once the query completed. Providers are reported once they reached a terminal state,
+// a resumed stream reports them again. events is closed if ctx is done, the streams are closed
+// or the result log cannot be read, clients reconnect with the ID of the last event they received.
+func (s *InternetProductsAPIService) stream(ctx context.Context, query string, offset int64, events chan<- StreamEvent) {
+	defer close(events)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(s.streams, cancel)
+	defer stop()
 
-	go func() {
-		for err := range errs {
-			slog.Error("Error fetching products", "error", err)
+	updates := s.subscribe(ctx, query, true)
+	// notifications may be lost, so the log is checked regularly as well
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	send := func(event string, data any) bool {
+		select {
+		case events <- StreamEvent{ID: strconv.FormatInt(offset, 10), Event: event, Data: data}:
+			return true
+		case <-ctx.Done():
+			return false
 		}
-	}()
+	}
 
-	var products []m.InternetProduct
-	current := cursor
-	next := uuid.New().String()
+	reported := make(map[string]bool)
+	var touched time.Time
+	for {
+		// the state is read before the products, so that a complete log is read in full
+		state := new(logState)
+		exists, err := s.queue.Get(ctx, logStateKeyPrefix+query, state)
+		if err != nil || !exists {
+			slog.Error("Error getting result log state from cache", "query", query, "exists", exists, "error", err)
+			return
+		}
+		if time.Since(touched) >= s.inactivityTimeout/2 {
+			// an open stream keeps the query alive
+			s.touch(ctx, query)
+			touched = time.Now()
+		}
+
+		products, err := s.readLog(ctx, query, offset)
+		if err != nil {
+			slog.Error("Error getting products from cache", "query", query, "error", err)
+			return
+		}
+		for _, product := range products {
+			offset++
+			if !send("product", product) {
+				return
+			}
+		}
+
+		status := new(m.InternetProductsQueryStatus)
+		if _, err := s.queue.Get(ctx, statusKeyPrefix+query, status); err != nil {
+			slog.Error("Error getting query status from cache", "query", query, "error", err)
+		}
+		for _, provider := range status.Providers {
+			if !provider.State.IsTerminal() || reported[provider.Provider] {
+				continue
+			}
+			reported[provider.Provider] = true
+			if !send("provider-status", provider) {
+				return
+			}
+		}
+
+		if state.Complete {
+			send("complete", &m.InternetProductsResponse{
+				Partial:   state.Partial,
+				Complete:  true,
+				Cancelled: state.Cancelled,
+			})
+			return
+		}
+
+		select {
+		case <-updates:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// CloseStreams closes all open result streams, so that the server can shut down.
+// Clients resume their streams on another instance.
+func (s *InternetProductsAPIService) CloseStreams() {
+	s.closeStreams()
+}
+
+// subscribe returns a channel notified whenever products are appended to the result log of query
+// or its state changed, until ctx is done. The channel is nil if enabled is not set or the queue
+// does not support notifications.
+func (s *InternetProductsAPIService) subscribe(ctx context.Context, query string, enabled bool) <-chan struct{} {
+	notifier, ok := s.queue.(i.Notifier)
+	if !enabled || !ok {
+		return nil
+	}
+	updates, err := notifier.Subscribe(ctx, logKeyPrefix+query)
+	if err != nil {
+		slog.Error("Error subscribing to result log", "query", query, "error", err)
+		return nil
+	}
+	return updates
+}
+
+// notify wakes up clients waiting for the result log of the query started with cursor
+func (s *InternetProductsAPIService) notify(ctx context.Context, cursor string) {
+	if notifier, ok := s.queue.(i.Notifier); ok {
+		if err := notifier.Publish(ctx, logKeyPrefix+cursor); err != nil {
+			slog.Error("Error notifying result log", "cursor", cursor, "error", err)
+		}
+	}
+}
+
+// readLog returns the products of the result log of query from offset on
+func (s *InternetProductsAPIService) readLog(ctx context.Context, query string, offset int64) ([]m.InternetProduct, error) {
+	entries, err := s.queue.Range(ctx, logKeyPrefix+query, offset, 0)
+	if err != nil {
+		return nil, err
+	}
+	products := make([]m.InternetProduct, len(entries))
+	for idx, entry := range entries {
+		if err := products[idx].UnmarshalBinary(entry); err != nil {
+			return nil, fmt.Errorf("error decoding product of query %s: %w", query, err)
+		}
+	}
+	return products, nil
+}
+
+// storeLogState writes the state of the result log of the query started with cursor to the queue
+func (s *InternetProductsAPIService) storeLogState(ctx context.Context, cursor string, state logState) {
+	if err := s.queue.Set(ctx, logStateKeyPrefix+cursor, state, resultTTL); err != nil {
+		slog.Error("Error setting result log state in cache", "error", err)
+		return
+	}
+	s.notify(ctx, cursor)
+}
+
+// GetInternetProductsQueryStatus returns the per provider status of the query started with cursor
+func (s *InternetProductsAPIService) GetInternetProductsQueryStatus(ctx context.Context, cursor string) (ImplResponse, error) {
+	// check if the cursor is a valid UUID
+	if _, err := uuid.Parse(cursor); err != nil {
+		return Response(http.StatusBadRequest, nil), errors.New("invalid cursor")
+	}
+
+	cursor, err := s.resolveCursor(ctx, cursor)
+	if err != nil {
+		return Response(http.StatusInternalServerError, nil), err
+	}
+
+	status := new(m.InternetProductsQueryStatus)
+	exists, err := s.queue.Get(ctx, statusKeyPrefix+cursor, status)
+	if err != nil {
+		slog.Error("Error getting query status from cache", "error", err)
+		return Response(http.StatusInternalServerError, nil), err
+	}
+	if !exists {
+		return Response(http.StatusNotFound, nil), errors.New("query not found")
+	}
+
+	return Response(http.StatusOK, status), nil
+}
+
+// CancelInternetProductsQuery detaches cursor from its query and cancels the query once no cursor is attached anymore.
+// The query may run on any instance, it notices the cancellation through the queue.
+// A coalesced query keeps running as long as its leader or one of the cursors attached to it did not cancel.
+func (s *InternetProductsAPIService) CancelInternetProductsQuery(ctx context.Context, cursor string) (ImplResponse, error) {
+	// check if the cursor is a valid UUID
+	if _, err := uuid.Parse(cursor); err != nil {
+		return Response(http.StatusBadRequest, nil), errors.New("invalid cursor")
+	}
+
+	query, err := s.resolveCursor(ctx, cursor)
+	if err != nil {
+		return Response(http.StatusInternalServerError, nil), err
+	}
 
-	// set the initial cursor in the queue to indicate work in progress
-	err := s.queue.Set(ctx, current, &m.InternetProductsResponse{NextCursor: workInProgressIndicator}, time.Duration(15*time.Minute))
+	status := new(m.InternetProductsQueryStatus)
+	exists, err := s.queue.Get(ctx, statusKeyPrefix+query, status)
 	if err != nil {
-		slog.Error("Error setting next cursor in cache", "error", err)
+		slog.Error("Error getting query status from cache", "error", err)
+		return Response(http.StatusInternalServerError, nil), err
+	}
+	if !exists {
+		return Response(http.StatusNotFound, nil), errors.New("query not found")
 	}
 
-	for prod := range prods {
-		slog.Debug("Fetched product", "product", prod.Name, "cursor", current, "next", next)
-		products = append(products, prod)
+	// completed queries are not cancelled, their cursors stay valid
+	if status.Complete {
+		return Response(http.StatusNoContent, nil), nil
+	}
 
-		slog.Debug("Adding product to queue", "cursor", current, "next", next)
-		err := s.queue.Set(ctx, current, &m.InternetProductsResponse{
-			Products:   []m.InternetProduct{prod},
-			NextCursor: next,
-		}, time.Duration(1*time.Hour))
+	attached, err := s.detach(ctx, query, cursor)
+	if err != nil {
+		slog.Error("Error detaching cursor from query", "error", err)
+		return Response(http.StatusInternalServerError, nil), err
+	}
+	if query != cursor {
+		if err := s.queue.Delete(ctx, aliasKeyPrefix+cursor); err != nil {
+			slog.Error("Error deleting cursor alias from cache", "error", err)
+			return Response(http.StatusInternalServerError, nil), err
+		}
+	}
+	if attached > 0 {
+		slog.Info("Cursor detached, query keeps running for the others", "cursor", cursor, "query", query, "attached", attached)
+		return Response(http.StatusNoContent, nil), nil
+	}
+
+	err = s.queue.Set(ctx, cancelKeyPrefix+query, &m.InternetProductsCursor{NextCursor: query}, queryTimeout)
+	if err != nil {
+		slog.Error("Error setting query cancellation in cache", "error", err)
+		return Response(http.StatusInternalServerError, nil), err
+	}
+	slog.Info("Query cancelled", "cursor", query)
+	return Response(http.StatusNoContent, nil), nil
+}
+
+// detach records that cursor left query and returns the number of cursors still attached to it.
+// The leader and every cursor coalesced into the query count as attached until they detach.
+func (s *InternetProductsAPIService) detach(ctx context.Context, query string, cursor string) (int, error) {
+	if _, err := s.queue.Append(ctx, detachedKeyPrefix+query, resultTTL, &m.InternetProductsCursor{NextCursor: cursor}); err != nil {
+		return 0, err
+	}
+
+	read := func(key string) (map[string]bool, error) {
+		entries, err := s.queue.Range(ctx, key, 0, 0)
 		if err != nil {
-			slog.Error("Error setting product in cache", "error", err)
-			continue
+			return nil, err
 		}
+		cursors := make(map[string]bool, len(entries))
+		for _, entry := range entries {
+			var c m.InternetProductsCursor
+			if err := c.UnmarshalBinary(entry); err != nil {
+				return nil, err
+			}
+			cursors[c.NextCursor] = true
+		}
+		return cursors, nil
+	}
+	attached, err := read(followersKeyPrefix + query)
+	if err != nil {
+		return 0, err
+	}
+	attached[query] = true
+	detached, err := read(detachedKeyPrefix + query)
+	if err != nil {
+		return 0, err
+	}
+	for c := range detached {
+		delete(attached, c)
+	}
+	return len(attached), nil
+}
+
+// touch marks the query started with cursor as active for another inactivity timeout
+func (s *InternetProductsAPIService) touch(ctx context.Context, cursor string) {
+	err := s.queue.Set(ctx, activityKeyPrefix+cursor, &m.InternetProductsCursor{NextCursor: cursor}, s.inactivityTimeout)
+	if err != nil {
+		slog.Error("Error setting query activity in cache", "error", err)
+	}
+}
 
-		// set next cursor in the queue to indicate work in progress for /internet-products/continue/{cursor}
-		err = s.queue.Set(ctx, next, &m.InternetProductsResponse{NextCursor: workInProgressIndicator}, time.Duration(15*time.Minute))
+// watchQuery calls cancel once the query started with cursor was cancelled or its results
+// were not polled within the inactivity timeout. Both are checked through the queue, so
+// queries are cancelled no matter which instance received the request. It returns when ctx is done.
+func (s *InternetProductsAPIService) watchQuery(ctx context.Context, cursor string, cancel context.CancelFunc) {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cancelled, err := s.queue.Get(ctx, cancelKeyPrefix+cursor, new(m.InternetProductsCursor))
 		if err != nil {
-			slog.Error("Error setting next cursor in cache", "error", err)
+			slog.Error("Error getting query cancellation from cache", "error", err)
+			continue
+		}
+		active, err := s.queue.Get(ctx, activityKeyPrefix+cursor, new(m.InternetProductsCursor))
+		if err != nil {
+			slog.Error("Error getting query activity from cache", "error", err)
+			continue
 		}
 
-		current = next
-		next = uuid.New().String()
+		switch {
+		case cancelled:
+			slog.Info("Stopping cancelled query", "cursor", cursor)
+		case !active:
+			slog.Info("Stopping abandoned query", "cursor", cursor, "inactivityTimeout", s.inactivityTimeout)
+		default:
+			continue
+		}
+		cancel()
+		return
 	}
+}
 
-	// add a final entry to the queue with the last cursor
-	slog.Debug("Adding final product to queue", "cursor", current, "next", "")
-	err = s.queue.Set(ctx, current, &m.InternetProductsResponse{
-		Products:   []m.InternetProduct{},
-		NextCursor: "",
-	}, time.Duration(1*time.Hour))
+// storeStatus writes the status of the query started with cursor to the queue
+func (s *InternetProductsAPIService) storeStatus(ctx context.Context, cursor string, status m.InternetProductsQueryStatus) {
+	err := s.queue.Set(ctx, statusKeyPrefix+cursor, &status, time.Duration(1*time.Hour))
 	if err != nil {
-		slog.Error("Error setting final product in cache", "error", err)
+		slog.Error("Error setting query status in cache", "error", err)
+		return
+	}
+	s.notify(ctx, cursor)
+}
+
+// resultLog are the products of a query already appended to its result log
+type resultLog struct {
+	products []m.InternetProduct
+	partial  bool
+	seen     map[string]bool // products of a resumed query that are already in the log
+}
+
+func productKey(product m.InternetProduct) string {
+	return product.Provider + "/" + product.Id
+}
+
+func (s *InternetProductsAPIService) processRequest(queryCtx context.Context, rc *requestmanager.RequestCoordinator, address m.Address, cursor string) bool {
+	// unbuffered, so that a checkpoint never misses products that were emitted but not written yet
+	prods, errs, progress := rc.Run(queryCtx, i.Request{
+		Address: address,
+	}, 0, 10)
+
+	deadline, _ := queryCtx.Deadline()
+	query := &checkpoint.Query{Cursor: cursor, Address: address, Deadline: deadline}
+	return s.collectResults(queryCtx, query, &resultLog{}, prods, errs, progress)
+}
+
+// collectResults appends the products of a query to its result log and checkpoints the query as it makes progress.
+// If the instance shuts down, the query is handed over to another instance instead of being finished.
+// It returns whether the query finished.
+func (s *InternetProductsAPIService) collectResults(
+	queryCtx context.Context,
+	query *checkpoint.Query,
+	results *resultLog,
+	prods <-chan m.InternetProduct,
+	errs <-chan *i.ProviderError,
+	progress *requestmanager.Progress,
+) bool {
+	cursor := query.Cursor
+	// the results must be written even if the query timed out or was cancelled
+	ctx := context.WithoutCancel(queryCtx)
+
+	go func() {
+		for err := range errs {
+			slog.Error("Error fetching products", "error", err)
+		}
+	}()
+
+	s.storeStatus(ctx, cursor, progress.Snapshot())
+	go func() {
+		for range progress.Changed() {
+			if !s.handingOver(queryCtx) {
+				s.storeStatus(ctx, cursor, progress.Snapshot())
+			}
+		}
+		// the instance taking over the query writes its status from now on
+		if !s.handingOver(queryCtx) {
+			s.storeStatus(ctx, cursor, progress.Snapshot())
+		}
+	}()
+
+	state := logState{Partial: results.partial}
+	s.storeLogState(ctx, cursor, state)
+
+	checkpoints := time.NewTicker(checkpointInterval)
+	defer checkpoints.Stop()
+	s.checkpoint(ctx, query, progress)
+
+	// once all providers finished or missed their soft deadline, all following products are late
+	partial := progress.Partial()
+	for prods != nil {
+		select {
+		case prod, ok := <-prods:
+			if !ok {
+				prods = nil
+				continue
+			}
+			if results.seen[productKey(prod)] {
+				slog.Debug("Skipping product already fetched before the query was resumed", "product", prod.Name, "cursor", cursor)
+				continue
+			}
+			slog.Debug("Fetched product", "product", prod.Name, "cursor", cursor)
+			if _, err := s.queue.Append(ctx, logKeyPrefix+cursor, resultTTL, prod); err != nil {
+				slog.Error("Error appending product to result log", "error", err)
+				continue
+			}
+			s.notify(ctx, cursor)
+			results.products = append(results.products, prod)
+		case <-partial:
+			partial = nil
+			if !missedDeadline(progress.Snapshot()) {
+				continue
+			}
+			slog.Info("Query partially complete, waiting for late providers", "cursor", cursor)
+			state.Partial = true
+			s.storeLogState(ctx, cursor, state)
+		case <-checkpoints.C:
+		}
+		s.checkpoint(ctx, query, progress)
+	}
+
+	if s.handingOver(queryCtx) {
+		// requests abandoned by the shutdown are still pending in the final checkpoint
+		s.checkpoint(ctx, query, progress)
+		if err := s.checkpoints.Release(ctx, cursor); err != nil {
+			slog.Error("Error handing over query", "cursor", cursor, "error", err)
+		}
+		slog.Info("Handed over query", "cursor", cursor, "products", len(results.products))
+		return false
 	}
 
+	// the final status is stored before the log is complete, so that streams report all providers
+	final := progress.Snapshot()
+	s.storeStatus(ctx, cursor, final)
+	state.Complete = true
+	state.Cancelled = final.Cancelled
+	s.storeLogState(ctx, cursor, state)
+	if s.checkpoints != nil {
+		if err := s.checkpoints.Delete(ctx, cursor); err != nil {
+			slog.Error("Error deleting checkpoint", "cursor", cursor, "error", err)
+		}
+	}
+
+	products := results.products
+	address := query.Address
 	slog.Info("Fetched products", "count", len(products))
 
+	// shared results of cancelled queries are marked, so recipients know they are incomplete
 	ok, err := s.cache.SetIfNotExists(ctx, cursor, &m.SharedInternetProductsResponse{
-		Products: products,
-		Address:  address,
-		Version:  m.INTERNET_PRODUCTS_RESPONSE_VERSION,
+		Products:  products,
+		Address:   address,
+		Version:   m.INTERNET_PRODUCTS_RESPONSE_VERSION,
+		Cancelled: final.Cancelled,
 	}, time.Duration(5*time.Minute))
 
 	if err != nil {
@@ -162,7 +806,7 @@ func (s *InternetProductsAPIService) processRequest(ctx context.Context, address
 		exists, err := s.cache.Get(ctx, cursor, existingProducts)
 		if err != nil {
 			slog.Error("Error getting products from cache", "error", err)
-			return
+			return true
 		}
 		if exists && existingProducts.Version != persistIndicator {
 			// unlikely chance of uuid collision, but possible
@@ -170,28 +814,212 @@ func (s *InternetProductsAPIService) processRequest(ctx context.Context, address
 		}
 		// if it doesn't exist, it means that the persisted key expired in the meantime
 		err = s.cache.Set(ctx, cursor, &m.SharedInternetProductsResponse{
-			Products: products,
-			Address:  address,
-			Version:  m.INTERNET_PRODUCTS_RESPONSE_VERSION,
+			Products:  products,
+			Address:   address,
+			Version:   m.INTERNET_PRODUCTS_RESPONSE_VERSION,
+			Cancelled: final.Cancelled,
 		}, i.KeepTTL)
 
 		if err != nil {
 			slog.Error("Error setting products in cache", "error", err)
+		}
+	}
+	return true
+}
+
+// handingOver reports whether the query of queryCtx is stopped to be continued by another instance
+func (s *InternetProductsAPIService) handingOver(queryCtx context.Context) bool {
+	return s.checkpoints != nil && context.Cause(queryCtx) == errShuttingDown
+}
+
+// checkpoint saves the state of a running query, so that another instance can continue it
+func (s *InternetProductsAPIService) checkpoint(ctx context.Context, query *checkpoint.Query, progress *requestmanager.Progress) {
+	if s.checkpoints == nil {
+		return
+	}
+	providers, err := progress.Checkpoint()
+	if err != nil {
+		slog.Debug("Query cannot be checkpointed", "cursor", query.Cursor, "error", err)
+		return
+	}
+	query.Providers = providers
+	ok, err := s.checkpoints.Save(ctx, query)
+	if err != nil {
+		slog.Error("Error saving checkpoint", "cursor", query.Cursor, "error", err)
+	} else if !ok {
+		slog.Warn("Query was taken over by another instance", "cursor", query.Cursor)
+	}
+}
+
+// adoptOrphans continues queries of instances that shut down or crashed until the instance shuts down
+func (s *InternetProductsAPIService) adoptOrphans() {
+	ticker := time.NewTicker(adoptInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.shutdown.Done():
 			return
+		case <-ticker.C:
+		}
+
+		queries, err := s.checkpoints.Claim(s.shutdown, adoptBatchSize)
+		if err != nil {
+			slog.Error("Error claiming orphaned queries", "error", err)
+		}
+		for _, query := range queries {
+			s.resume(query)
+		}
+	}
+}
+
+// resume continues a query claimed from another instance at the end of its result log
+func (s *InternetProductsAPIService) resume(query *checkpoint.Query) {
+	ctx := context.Background()
+	names := make([]string, len(query.Providers))
+	for idx, provider := range query.Providers {
+		names[idx] = provider.Status.Provider
+	}
+
+	rc, err := s.rc.Select(names)
+	if err != nil {
+		slog.Error("Dropping orphaned query with unknown provider", "cursor", query.Cursor, "error", err)
+		s.deleteCheckpoint(ctx, query.Cursor)
+		return
+	}
+	results, finished, err := s.loadLog(ctx, query.Cursor)
+	if err != nil {
+		slog.Error("Error loading result log of orphaned query", "cursor", query.Cursor, "error", err)
+		if err := s.checkpoints.Release(ctx, query.Cursor); err != nil {
+			slog.Error("Error releasing orphaned query", "cursor", query.Cursor, "error", err)
+		}
+		return
+	}
+	if finished {
+		// the instance stopped after completing the log but before deleting the checkpoint
+		s.deleteCheckpoint(ctx, query.Cursor)
+		return
+	}
+
+	s.running.Add(1)
+	err = s.pool.Submit(func() {
+		defer s.running.Done()
+		queryCtx, cancel := context.WithDeadline(s.shutdown, query.Deadline)
+		defer cancel()
+		go s.watchQuery(queryCtx, query.Cursor, cancel)
+
+		slog.Info("Resuming orphaned query", "cursor", query.Cursor, "products", len(results.products))
+		prods, errs, progress := rc.Resume(queryCtx, i.Request{Address: query.Address}, query.Providers, 0, 10)
+		if s.collectResults(queryCtx, query, results, prods, errs, progress) {
+			s.releaseCoalesceLock(ctx, coalesceKeyPrefix+coalesceKey(query.Address, rc.Names()), query.Cursor)
+		}
+	})
+	if err != nil {
+		s.running.Done()
+		slog.Warn("Leaving orphaned query to other instances, all workers are busy", "cursor", query.Cursor)
+		if err := s.checkpoints.Release(ctx, query.Cursor); err != nil {
+			slog.Error("Error releasing orphaned query", "cursor", query.Cursor, "error", err)
 		}
 	}
 }
 
+func (s *InternetProductsAPIService) deleteCheckpoint(ctx context.Context, cursor string) {
+	if err := s.checkpoints.Delete(ctx, cursor); err != nil {
+		slog.Error("Error deleting checkpoint", "cursor", cursor, "error", err)
+	}
+}
+
+// loadLog reads the result log of the query started with cursor.
+// finished is set if the query already completed.
+func (s *InternetProductsAPIService) loadLog(ctx context.Context, cursor string) (results *resultLog, finished bool, err error) {
+	state := new(logState)
+	if _, err := s.queue.Get(ctx, logStateKeyPrefix+cursor, state); err != nil {
+		return nil, false, err
+	}
+	products, err := s.readLog(ctx, cursor, 0)
+	if err != nil {
+		return nil, false, err
+	}
+
+	results = &resultLog{products: products, partial: state.Partial, seen: make(map[string]bool)}
+	for _, product := range products {
+		results.seen[productKey(product)] = true
+	}
+	return results, state.Complete, nil
+}
+
+// Shutdown stops all running queries and hands them over to other instances, which continue their result logs.
+// If the queue does not support checkpoints, the queries are cancelled.
+// It returns once all queries stopped or ctx is done.
+func (s *InternetProductsAPIService) Shutdown(ctx context.Context) error {
+	s.stop(errShuttingDown)
+
+	stopped := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (s *InternetProductsAPIService) InitiateInternetProductsQuery(ctx context.Context, address m.Address, providers []string) (ImplResponse, error) {
+	rc, err := s.rc.Select(providers)
+	if err != nil {
+		return Response(http.StatusBadRequest, nil), err
+	}
+
 	cursor := uuid.New().String()
+	key := coalesceKeyPrefix + coalesceKey(address, rc.Names())
 
-	go func() {
+	leader, err := s.coalesce(ctx, key, cursor)
+	if err != nil {
+		// coalescing is an optimization, run the query on our own
+		slog.Error("Error coalescing query", "error", err)
+	}
+	if leader != "" {
+		slog.Info("Attached query to running query", "cursor", cursor, "leader", leader)
+		s.touch(ctx, leader)
+		return Response(200, m.InternetProductsCursor{
+			Version:    m.INTERNET_PRODUCTS_RESPONSE_VERSION,
+			NextCursor: cursor,
+		}), nil
+	}
+
+	// make the status available before any provider started
+	status := m.InternetProductsQueryStatus{}
+	for _, name := range rc.Names() {
+		status.Providers = append(status.Providers, m.ProviderStatus{Provider: name, State: m.PENDING})
+	}
+	s.storeStatus(ctx, cursor, status)
+	s.storeLogState(ctx, cursor, logState{})
+	s.touch(ctx, cursor)
+
+	s.running.Add(1)
+	err = s.pool.Submit(func() {
+		defer s.running.Done()
 		bg := context.Background()
-		bgWithTimeout, cancel := context.WithTimeout(bg, 60*time.Second)
+		// queries are stopped if the instance shuts down, see Shutdown
+		queryCtx, cancel := context.WithTimeout(s.shutdown, queryTimeout)
 		defer cancel()
+		go s.watchQuery(queryCtx, cursor, cancel)
 
-		s.processRequest(bgWithTimeout, address, cursor)
-	}()
+		// handed over queries keep the lock, so that later queries attach to the instance continuing it
+		if s.processRequest(queryCtx, rc, address, cursor) {
+			s.releaseCoalesceLock(bg, key, cursor)
+		}
+	})
+	if err != nil {
+		s.running.Done()
+		slog.Warn("Rejecting query, all workers are busy", "stats", s.pool.Stats())
+		s.reject(ctx, key, cursor, status)
+		return Response(http.StatusServiceUnavailable, nil, map[string]string{"Retry-After": retryAfterOverloaded}), err
+	}
 
 	return Response(200, m.InternetProductsCursor{
 		Version:    m.INTERNET_PRODUCTS_RESPONSE_VERSION,
@@ -199,12 +1027,30 @@ func (s *InternetProductsAPIService) InitiateInternetProductsQuery(ctx context.C
 	}), nil
 }
 
-func (s *InternetProductsAPIService) ShareInternetProducts(ctx context.Context, cursor string) (ImplResponse, error) {
+// reject completes the query started with cursor that could not be submitted to the pool and releases its lock.
+// Queries attached to it before the lock was released are rejected as well.
+func (s *InternetProductsAPIService) reject(ctx context.Context, key string, cursor string, status m.InternetProductsQueryStatus) {
+	for idx := range status.Providers {
+		status.Providers[idx].State = m.CANCELLED
+	}
+	status.Complete = true
+	status.Cancelled = true
+	s.storeStatus(ctx, cursor, status)
+	s.storeLogState(ctx, cursor, logState{Complete: true, Cancelled: true, Rejected: true})
+	s.releaseCoalesceLock(ctx, key, cursor)
+}
+
+func (s *InternetProductsAPIService) ShareInternetProducts(ctx context.Context, cursor string, filter *m.InternetProductsFilter) (ImplResponse, error) {
 	// check if the cursor is a valid UUID
 	if _, err := uuid.Parse(cursor); err != nil {
 		return Response(http.StatusBadRequest, nil), errors.New("invalid cursor")
 	}
 
+	cursor, err := s.resolveCursor(ctx, cursor)
+	if err != nil {
+		return Response(http.StatusInternalServerError, nil), err
+	}
+
 	value := m.SharedInternetProductsResponse{
 		Version: persistIndicator,
 	}
@@ -223,16 +1069,43 @@ func (s *InternetProductsAPIService) ShareInternetProducts(ctx context.Context,
 		}
 	}
 
-	return Response(http.StatusOK, nil), nil
+	// every share keeps its own filter, so sharing again leaves earlier links unchanged
+	id := uuid.New().String()
+	if err := s.cache.Set(ctx, shareKeyPrefix+id, shareLink{Cursor: cursor, Filter: filter}, 0); err != nil {
+		slog.Error("Error setting share in cache", "error", err)
+		return Response(http.StatusInternalServerError, nil), err
+	}
+
+	return Response(http.StatusOK, m.InternetProductsShare{
+		Version: m.INTERNET_PRODUCTS_RESPONSE_VERSION,
+		ShareId: id,
+	}), nil
 }
 
-// GetSharedInternetProducts -
-func (s *InternetProductsAPIService) GetSharedInternetProducts(ctx context.Context, cursor string) (ImplResponse, error) {
+// GetSharedInternetProducts returns the shared products of the share id, or of the query started with cursor,
+// together with the filter they match. This is the filter of the share unless filter is set, which replaces it.
+func (s *InternetProductsAPIService) GetSharedInternetProducts(ctx context.Context, cursor string, filter m.InternetProductsFilter) (ImplResponse, error) {
 	if _, err := uuid.Parse(cursor); err != nil {
 		return Response(http.StatusBadRequest, nil), errors.New("invalid cursor")
 	}
+
+	link := new(shareLink)
+	shared, err := s.cache.Get(ctx, shareKeyPrefix+cursor, link)
+	if err != nil {
+		slog.Error("Error getting share from cache", "error", err)
+		return Response(http.StatusInternalServerError, nil), err
+	}
+	if !shared {
+		// links of the cursor share all products
+		cursor, err = s.resolveCursor(ctx, cursor)
+		if err != nil {
+			return Response(http.StatusInternalServerError, nil), err
+		}
+		link = &shareLink{Cursor: cursor}
+	}
+
 	products := new(m.SharedInternetProductsResponse)
-	exists, err := s.cache.Get(ctx, cursor, products)
+	exists, err := s.cache.Get(ctx, link.Cursor, products)
 	if err != nil {
 		slog.Error("Error getting products from cache", "error", err)
 		return Response(http.StatusInternalServerError, nil), err
@@ -241,6 +1114,104 @@ func (s *InternetProductsAPIService) GetSharedInternetProducts(ctx context.Conte
 	if !exists || products.Version == persistIndicator {
 		return Response(http.StatusNotFound, nil), errors.New("products not found")
 	}
+	products.Filter = link.Filter
+	if !filter.IsZero() {
+		products.Filter = &filter
+	}
+	if products.Filter != nil {
+		products.Products = filterProducts(products.Products, *products.Filter)
+	}
 
 	return Response(http.StatusOK, products), nil
 }
+
+// rejectedResponse is returned for cursors of queries rejected because all workers were busy
+func rejectedResponse() (ImplResponse, error) {
+	return Response(http.StatusServiceUnavailable, nil, map[string]string{"Retry-After": retryAfterOverloaded}), errors.New("query rejected, all workers were busy")
+}
+
+// missedDeadline reports whether a provider is still running past its soft deadline
+func missedDeadline(status m.InternetProductsQueryStatus) bool {
+	return slices.ContainsFunc(status.Providers, func(provider m.ProviderStatus) bool {
+		return provider.PastDeadline && !provider.State.IsTerminal()
+	})
+}
+
+// coalesceKey identifies queries that yield the same products: same address and same providers
+func coalesceKey(address m.Address, providers []string) string {
+	names := make([]string, len(providers))
+	for idx, name := range providers {
+		names[idx] = strings.ToLower(name)
+	}
+	slices.Sort(names)
+	return m.AddressKey(address) + ":" + strings.Join(names, ",")
+}
+
+// coalesce tries to attach the query with cursor to a running query with the same key.
+// It returns the cursor of the running query, or an empty string if the caller holds the
+// lock for key and has to run the query itself. The lock is shared by all instances through the queue.
+func (s *InternetProductsAPIService) coalesce(ctx context.Context, key string, cursor string) (string, error) {
+	// retry once if the running query finished between acquiring and reading the lock
+	for range 2 {
+		ok, err := s.queue.SetIfNotExists(ctx, key, &m.InternetProductsCursor{NextCursor: cursor}, queryTimeout)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return "", nil
+		}
+
+		leader := new(m.InternetProductsCursor)
+		exists, err := s.queue.Get(ctx, key, leader)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			continue
+		}
+
+		// followers keep the query running until they cancel as well, see CancelInternetProductsQuery
+		_, err = s.queue.Append(ctx, followersKeyPrefix+leader.NextCursor, resultTTL, &m.InternetProductsCursor{NextCursor: cursor})
+		if err != nil {
+			return "", err
+		}
+		// followers get their own cursor pointing to the result log of the running query
+		err = s.queue.Set(ctx, aliasKeyPrefix+cursor, leader, time.Duration(1*time.Hour))
+		if err != nil {
+			return "", err
+		}
+		return leader.NextCursor, nil
+	}
+	return "", nil
+}
+
+// releaseCoalesceLock removes the lock for key if it is still held by the query with cursor
+func (s *InternetProductsAPIService) releaseCoalesceLock(ctx context.Context, key string, cursor string) {
+	holder := new(m.InternetProductsCursor)
+	exists, err := s.queue.Get(ctx, key, holder)
+	if err != nil {
+		slog.Error("Error getting coalesce lock from cache", "error", err)
+		return
+	}
+	if !exists || holder.NextCursor != cursor {
+		return
+	}
+	if err := s.queue.Delete(ctx, key); err != nil {
+		slog.Error("Error releasing coalesce lock", "error", err)
+	}
+}
+
+// resolveCursor returns the cursor of the query that a coalesced cursor is attached to,
+// or the cursor itself if it was not coalesced
+func (s *InternetProductsAPIService) resolveCursor(ctx context.Context, cursor string) (string, error) {
+	leader := new(m.InternetProductsCursor)
+	exists, err := s.queue.Get(ctx, aliasKeyPrefix+cursor, leader)
+	if err != nil {
+		slog.Error("Error getting cursor alias from cache", "error", err)
+		return "", err
+	}
+	if !exists {
+		return cursor, nil
+	}
+	return leader.NextCursor, nil
+}
@@ -0,0 +1,426 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/rotmanjanez/check24-gendev-7/internal/requestmanager"
+	"github.com/rotmanjanez/check24-gendev-7/internal/workerpool"
+	i "github.com/rotmanjanez/check24-gendev-7/pkg/interfaces"
+	m "github.com/rotmanjanez/check24-gendev-7/pkg/models"
+)
+
+// Types of the messages exchanged over a query socket
+const (
+	socketQuery    = "query"
+	socketFilter   = "filter"
+	socketRerun    = "rerun"
+	socketCancel   = "cancel"
+	socketProduct  = "product"
+	socketProducts = "products"
+	socketStatus   = "status"
+	socketComplete = "complete"
+	socketError    = "error"
+)
+
+// socketWriteTimeout limits how long writing a single message to a query socket may take
+const socketWriteTimeout = 10 * time.Second
+
+// socketPingInterval is how often idle query sockets are pinged to keep proxies from closing them
+const socketPingInterval = 30 * time.Second
+
+// SocketRequest is a message sent by the client of a query socket
+type SocketRequest struct {
+	// Type is one of query, filter, rerun and cancel
+	Type string `json:"type"`
+
+	// Address and Providers of a query message start the query of the session, all providers are queried if none are given
+	Address   *m.Address `json:"address,omitempty"`
+	Providers []string   `json:"providers,omitempty"`
+
+	// Filter replaces the filter and sort order of the session, it is set by filter messages and optional for query messages
+	Filter *m.InternetProductsFilter `json:"filter,omitempty"`
+
+	// Provider is the provider queried again by a rerun message
+	Provider string `json:"provider,omitempty"`
+}
+
+// SocketEvent is a message sent to the client of a query socket
+type SocketEvent struct {
+	// Type is one of product, products, status, complete and error
+	Type string `json:"type"`
+
+	// Product is a new product matching the filter of the session
+	Product *m.InternetProduct `json:"product,omitempty"`
+
+	// Products are all products of the session matching its filter in its sort order,
+	// sent whenever the filter changed or products were dropped for a rerun
+	Products []m.InternetProduct `json:"products,omitempty"`
+
+	// Status is the status of all providers of the session
+	Status *m.InternetProductsQueryStatus `json:"status,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// InternetProductsSocketController serves interactive queries over WebSockets.
+// Every socket runs a single query whose products are pushed as they arrive, the client can change
+// the filter and sort order, query a single provider again or cancel the query on the same socket.
+// Closing the socket cancels all provider requests of the query.
+type InternetProductsSocketController struct {
+	service  *InternetProductsAPIService
+	upgrader websocket.Upgrader
+}
+
+// NewInternetProductsSocketController creates a controller running the queries of sockets with the providers of service
+func NewInternetProductsSocketController(service *InternetProductsAPIService) *InternetProductsSocketController {
+	return &InternetProductsSocketController{service: service}
+}
+
+// Routes returns all the api routes for the InternetProductsSocketController
+func (c *InternetProductsSocketController) Routes() Routes {
+	return Routes{
+		"QueryInternetProductsSocket": Route{
+			http.MethodGet,
+			"/internet-products/socket",
+			c.QueryInternetProductsSocket,
+		},
+	}
+}
+
+// QueryInternetProductsSocket upgrades the request to a WebSocket and serves a query session on it
+func (c *InternetProductsSocketController) QueryInternetProductsSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := c.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader already responded with an error
+		slog.Warn("Error upgrading query socket", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	// the socket is hijacked, so the query is bound to the socket instead of the request
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// open sockets are closed with the streams, see CloseStreams
+	stop := context.AfterFunc(c.service.streams, cancel)
+	defer stop()
+
+	session := &socketSession{
+		conn:    conn,
+		rc:      c.service.rc,
+		pool:    c.service.pool,
+		updates: make(chan socketUpdate),
+		busy:    make(map[string]int),
+		runs:    make(map[int]context.CancelFunc),
+	}
+	session.serve(ctx)
+	if c.service.streams.Err() != nil {
+		session.close(websocket.CloseGoingAway, "server shutting down")
+	}
+}
+
+// socketUpdate is progress of a single run of a session
+type socketUpdate struct {
+	run     int
+	product *m.InternetProduct
+	status  *m.InternetProductsQueryStatus
+	done    bool
+}
+
+// socketRead is a message read from a query socket, err is set if it could not be decoded
+type socketRead struct {
+	request SocketRequest
+	err     error
+}
+
+// socketSession is the query of a single socket.
+// All of its state is owned by serve, runs report their progress through updates.
+type socketSession struct {
+	conn *websocket.Conn
+	rc   *requestmanager.RequestCoordinator
+	pool *workerpool.Pool // runs are admitted like the queries of the HTTP API
+
+	address  *m.Address
+	filter   m.InternetProductsFilter
+	products []m.InternetProduct
+	statuses []m.ProviderStatus
+
+	updates chan socketUpdate
+	busy    map[string]int // number of active runs per provider
+	runs    map[int]context.CancelFunc
+	nextRun int
+}
+
+// serve handles the messages of the client and the progress of the runs until the socket is closed or ctx is done
+func (s *socketSession) serve(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	// runs are cancelled with the session, but their final updates must not block
+	defer func() {
+		cancel()
+		for len(s.runs) > 0 {
+			update := <-s.updates
+			if update.done {
+				delete(s.runs, update.run)
+			}
+		}
+	}()
+
+	reads := make(chan socketRead)
+	go s.read(ctx, cancel, reads)
+
+	ping := time.NewTicker(socketPingInterval)
+	defer ping.Stop()
+
+	for {
+		var err error
+		select {
+		case read := <-reads:
+			if read.err != nil {
+				err = s.send(SocketEvent{Type: socketError, Error: read.err.Error()})
+				break
+			}
+			err = s.handle(ctx, read.request)
+		case update := <-s.updates:
+			err = s.progress(update)
+		case <-ping.C:
+			err = s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteTimeout))
+		case <-ctx.Done():
+			return
+		}
+		if err != nil {
+			slog.Debug("Closing query socket", "error", err)
+			return
+		}
+	}
+}
+
+// read forwards the messages of the client to reads and cancels the session once the socket is closed
+func (s *socketSession) read(ctx context.Context, cancel context.CancelFunc, reads chan<- socketRead) {
+	defer cancel()
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var read socketRead
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&read.request); err != nil {
+			read.err = &ParsingError{Err: err}
+		}
+		select {
+		case reads <- read:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handle applies a message of the client to the session. Invalid messages are answered with an error event,
+// the returned error is only set if the socket failed.
+func (s *socketSession) handle(ctx context.Context, req SocketRequest) error {
+	switch req.Type {
+	case socketQuery:
+		if s.address != nil {
+			return s.send(SocketEvent{Type: socketError, Error: "query already started"})
+		}
+		if req.Address == nil {
+			return s.send(SocketEvent{Type: socketError, Error: (&m.RequiredError{Field: "address"}).Error()})
+		}
+		if err := m.AssertAddressRequired(*req.Address); err != nil {
+			return s.send(SocketEvent{Type: socketError, Error: err.Error()})
+		}
+		rc, err := s.rc.Select(req.Providers)
+		if err != nil {
+			return s.send(SocketEvent{Type: socketError, Error: err.Error()})
+		}
+		if req.Filter != nil {
+			if err := m.AssertInternetProductsFilterConstraints(*req.Filter); err != nil {
+				return s.send(SocketEvent{Type: socketError, Error: err.Error()})
+			}
+			s.filter = *req.Filter
+		}
+		if err := s.start(ctx, rc, *req.Address); err != nil {
+			return s.send(SocketEvent{Type: socketError, Error: err.Error()})
+		}
+		s.address = req.Address
+		s.rc = rc
+		return nil
+	case socketFilter:
+		if req.Filter == nil {
+			return s.send(SocketEvent{Type: socketError, Error: (&m.RequiredError{Field: "filter"}).Error()})
+		}
+		if err := m.AssertInternetProductsFilterConstraints(*req.Filter); err != nil {
+			return s.send(SocketEvent{Type: socketError, Error: err.Error()})
+		}
+		s.filter = *req.Filter
+		return s.sendProducts()
+	case socketRerun:
+		if s.address == nil {
+			return s.send(SocketEvent{Type: socketError, Error: "query not started"})
+		}
+		rc, err := s.rc.Select([]string{req.Provider})
+		if err != nil || strings.TrimSpace(req.Provider) == "" {
+			return s.send(SocketEvent{Type: socketError, Error: fmt.Sprintf("provider %q not part of the query", req.Provider)})
+		}
+		// providers are selected case-insensitively, the session knows them by their name
+		name := rc.Names()[0]
+		if s.busy[name] > 0 {
+			return s.send(SocketEvent{Type: socketError, Error: fmt.Sprintf("provider %q still running", name)})
+		}
+		// a rerun asks for current products, so cached results are not served
+		if err := s.start(ctx, rc.Fresh(), *s.address); err != nil {
+			return s.send(SocketEvent{Type: socketError, Error: err.Error()})
+		}
+		// products of the new run are only applied after this message was handled
+		s.products = slices.DeleteFunc(s.products, func(product m.InternetProduct) bool {
+			return product.Provider == name
+		})
+		return s.sendProducts()
+	case socketCancel:
+		for _, cancel := range s.runs {
+			cancel()
+		}
+		return nil
+	}
+	return s.send(SocketEvent{Type: socketError, Error: fmt.Sprintf("unknown message type %q", req.Type)})
+}
+
+// start runs the providers of rc for address on the worker pool.
+// The run is rejected if all workers are busy.
+func (s *socketSession) start(ctx context.Context, rc *requestmanager.RequestCoordinator, address m.Address) error {
+	runCtx, cancel := context.WithTimeout(ctx, queryTimeout)
+	id := s.nextRun
+
+	err := s.pool.Submit(func() {
+		defer cancel()
+		prods, errs, progress := rc.Run(runCtx, i.Request{Address: address}, 0, 10)
+		go func() {
+			for err := range errs {
+				slog.Error("Error fetching products", "error", err)
+			}
+		}()
+		changed := progress.Changed()
+		for prods != nil || changed != nil {
+			update := socketUpdate{run: id}
+			select {
+			case product, ok := <-prods:
+				if !ok {
+					prods = nil
+					continue
+				}
+				update.product = &product
+			case _, ok := <-changed:
+				if !ok {
+					changed = nil
+					continue
+				}
+				status := progress.Snapshot()
+				update.status = &status
+			}
+			s.updates <- update
+		}
+		status := progress.Snapshot()
+		s.updates <- socketUpdate{run: id, status: &status, done: true}
+	})
+	if err != nil {
+		cancel()
+		slog.Warn("Rejecting socket run, all workers are busy", "stats", s.pool.Stats())
+		return fmt.Errorf("run rejected, retry later: %w", err)
+	}
+
+	// updates are only applied by serve, so the run is known before its first update
+	s.nextRun++
+	s.runs[id] = cancel
+	for _, name := range rc.Names() {
+		s.busy[name]++
+	}
+	return nil
+}
+
+// progress applies an update of a run to the session and pushes it to the client
+func (s *socketSession) progress(update socketUpdate) error {
+	if update.product != nil {
+		s.products = append(s.products, *update.product)
+		if matchesFilter(*update.product, s.filter) {
+			if err := s.send(SocketEvent{Type: socketProduct, Product: update.product}); err != nil {
+				return err
+			}
+		}
+	}
+	if update.status != nil {
+		for _, provider := range update.status.Providers {
+			idx := slices.IndexFunc(s.statuses, func(status m.ProviderStatus) bool {
+				return status.Provider == provider.Provider
+			})
+			if idx < 0 {
+				s.statuses = append(s.statuses, provider)
+			} else {
+				s.statuses[idx] = provider
+			}
+		}
+	}
+	if !update.done {
+		if update.status == nil {
+			return nil
+		}
+		return s.send(SocketEvent{Type: socketStatus, Status: s.status()})
+	}
+
+	delete(s.runs, update.run)
+	for _, provider := range update.status.Providers {
+		s.busy[provider.Provider]--
+	}
+	if len(s.runs) > 0 {
+		return s.send(SocketEvent{Type: socketStatus, Status: s.status()})
+	}
+	return s.send(SocketEvent{Type: socketComplete, Status: s.status()})
+}
+
+// status returns the status of all providers of the session
+func (s *socketSession) status() *m.InternetProductsQueryStatus {
+	status := &m.InternetProductsQueryStatus{
+		Providers: slices.Clone(s.statuses),
+		Complete:  len(s.runs) == 0,
+		Partial:   true,
+	}
+	for _, provider := range s.statuses {
+		if !provider.State.IsTerminal() && !provider.PastDeadline {
+			status.Partial = false
+		}
+		if provider.State == m.CANCELLED {
+			status.Cancelled = true
+		}
+	}
+	return status
+}
+
+// sendProducts pushes all products of the session matching its filter
+func (s *socketSession) sendProducts() error {
+	return s.send(SocketEvent{Type: socketProducts, Products: filterProducts(s.products, s.filter)})
+}
+
+func (s *socketSession) send(event SocketEvent) error {
+	if err := s.conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout)); err != nil {
+		return err
+	}
+	return s.conn.WriteJSON(event)
+}
+
+// close tells the client why the socket is closed
+func (s *socketSession) close(code int, reason string) {
+	err := s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(socketWriteTimeout))
+	if err != nil && !errors.Is(err, websocket.ErrCloseSent) {
+		slog.Debug("Error closing query socket", "error", err)
+	}
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/rotmanjanez/check24-gendev-7/config"
+	"github.com/rotmanjanez/check24-gendev-7/internal/workerpool"
+	"github.com/rotmanjanez/check24-gendev-7/pkg/cache"
+	"github.com/rotmanjanez/check24-gendev-7/pkg/models"
+	"github.com/rotmanjanez/check24-gendev-7/pkg/provider"
+)
+
+// dialSocket starts a server for the query sockets of service and connects to it
+func dialSocket(t *testing.T, service *InternetProductsAPIService) *websocket.Conn {
+	t.Helper()
+	server := httptest.NewServer(NewRouter(NewInternetProductsSocketController(service)))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/internet-products/socket", nil)
+	if err != nil {
+		t.Fatalf("error connecting to socket: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readSocketEvent reads the next event of conn, failing the test if it is not of type typ
+func readSocketEvent(t *testing.T, conn *websocket.Conn, typ string) SocketEvent {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var event SocketEvent
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatalf("error reading %s event: %v", typ, err)
+	}
+	if event.Type != typ {
+		t.Fatalf("expected %s event, got %+v", typ, event)
+	}
+	return event
+}
+
+// readSocketRun reads the events of conn until the query completed and returns the products and the final status.
+// Products and status changes may arrive in any order.
+func readSocketRun(t *testing.T, conn *websocket.Conn) ([]models.InternetProduct, *models.InternetProductsQueryStatus) {
+	t.Helper()
+	var products []models.InternetProduct
+	for {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var event SocketEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("error reading events: %v", err)
+		}
+		switch event.Type {
+		case "product":
+			products = append(products, *event.Product)
+		case "status":
+		case "complete":
+			return products, event.Status
+		default:
+			t.Fatalf("unexpected event %+v", event)
+		}
+	}
+}
+
+func TestQueryInternetProductsSocket(t *testing.T) {
+	product := sampleProduct
+	product.Provider = "mock"
+	mockProvider := &mockProviderAdapter{returnProductsOnPrepare: true, product: &product}
+	conn := dialSocket(t, newCancellableTestService(mockProvider, cache.NewInstanceCache("test-queue")))
+
+	send := func(req SocketRequest) {
+		if err := conn.WriteJSON(req); err != nil {
+			t.Fatalf("error sending %s message: %v", req.Type, err)
+		}
+	}
+
+	send(SocketRequest{Type: "query", Address: &validAddressDE})
+	products, status := readSocketRun(t, conn)
+	if len(products) != 1 || products[0].Id != product.Id {
+		t.Errorf("expected the product of the provider, got %+v", products)
+	}
+	if len(status.Providers) != 1 || status.Providers[0].State != models.COMPLETED || !status.Complete {
+		t.Errorf("expected the provider to be completed, got %+v", status)
+	}
+
+	maxMonthlyCost := int32(1000)
+	send(SocketRequest{Type: "filter", Filter: &models.InternetProductsFilter{MaxMonthlyCost: &maxMonthlyCost}})
+	if event := readSocketEvent(t, conn, "products"); len(event.Products) != 0 {
+		t.Errorf("expected the product to be filtered, got %+v", event.Products)
+	}
+	send(SocketRequest{Type: "filter", Filter: &models.InternetProductsFilter{Sort: models.SPEED_DESC}})
+	if event := readSocketEvent(t, conn, "products"); len(event.Products) != 1 {
+		t.Errorf("expected the product without filter, got %+v", event.Products)
+	}
+
+	// the products of the provider are replaced by the products of the new run, providers are matched case-insensitively
+	send(SocketRequest{Type: "rerun", Provider: "MOCK"})
+	if event := readSocketEvent(t, conn, "products"); len(event.Products) != 0 {
+		t.Errorf("expected the products of the provider to be dropped, got %+v", event.Products)
+	}
+	if products, _ := readSocketRun(t, conn); len(products) != 1 {
+		t.Errorf("expected the product of the new run, got %+v", products)
+	}
+	if calls := mockProvider.prepareCalls.Load(); calls != 2 {
+		t.Errorf("expected the provider to be queried again, got %d calls", calls)
+	}
+
+	for _, invalid := range []SocketRequest{
+		{Type: "query", Address: &validAddressDE},
+		{Type: "rerun", Provider: "unknown"},
+		{Type: "filter", Filter: &models.InternetProductsFilter{Sort: "cheapest"}},
+		{Type: "unknown"},
+	} {
+		send(invalid)
+		readSocketEvent(t, conn, "error")
+	}
+}
+
+func TestQueryInternetProductsSocket_RerunBypassesResultCache(t *testing.T) {
+	product := sampleProduct
+	product.Provider = "mock"
+	mockProvider := &mockProviderAdapter{returnProductsOnPrepare: true, product: &product}
+	cfg := provider.NewProviderConfig(mockProvider, 0, 10*time.Second, 1, 0)
+	cfg.ResultCache = provider.NewResultCache("mock", config.ResultCacheConfig{TTL: time.Minute}, cache.NewInstanceCache("test-results"))
+	service := NewInternetProductsAPIService(nil, cache.NewInstanceCache("test-cache"), cache.NewInstanceCache("test-queue"), []*provider.ProviderConfig{cfg}, workerpool.New(1, 1))
+	conn := dialSocket(t, service)
+
+	if err := conn.WriteJSON(SocketRequest{Type: "query", Address: &validAddressDE}); err != nil {
+		t.Fatalf("error sending query: %v", err)
+	}
+	readSocketRun(t, conn)
+	if _, _, found := cfg.ResultCache.Get(context.Background(), validAddressDE); !found {
+		t.Fatal("expected the products of the query to be cached")
+	}
+
+	if err := conn.WriteJSON(SocketRequest{Type: "rerun", Provider: "mock"}); err != nil {
+		t.Fatalf("error sending rerun: %v", err)
+	}
+	readSocketEvent(t, conn, "products")
+	if products, _ := readSocketRun(t, conn); len(products) != 1 {
+		t.Errorf("expected the product of the new run, got %+v", products)
+	}
+	if calls := mockProvider.prepareCalls.Load(); calls != 2 {
+		t.Errorf("expected the rerun to query the provider instead of the result cache, got %d calls", calls)
+	}
+}
+
+func TestQueryInternetProductsSocket_RerunWhileRunning(t *testing.T) {
+	aborted := make(chan struct{})
+	mockServer := newBlockingServer(aborted)
+	defer mockServer.Close()
+
+	mockProvider := &mockProviderAdapter{mockServer: mockServer}
+	conn := dialSocket(t, newCancellableTestService(mockProvider, cache.NewInstanceCache("test-queue")))
+
+	if err := conn.WriteJSON(SocketRequest{Type: "query", Address: &validAddressDE}); err != nil {
+		t.Fatalf("error sending query: %v", err)
+	}
+	readSocketEvent(t, conn, "status")
+
+	if err := conn.WriteJSON(SocketRequest{Type: "rerun", Provider: "Mock"}); err != nil {
+		t.Fatalf("error sending rerun: %v", err)
+	}
+	// status events of the running query may arrive first
+	for {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var event SocketEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("error reading events: %v", err)
+		}
+		if event.Type == "status" {
+			continue
+		}
+		if event.Type != "error" || !strings.Contains(event.Error, `"mock" still running`) {
+			t.Errorf("expected the running provider to be rejected, got %+v", event)
+		}
+		break
+	}
+	if calls := mockProvider.prepareCalls.Load(); calls != 1 {
+		t.Errorf("expected the provider not to be queried again, got %d calls", calls)
+	}
+
+	// release the blocked provider request before the server is closed
+	conn.Close()
+	select {
+	case <-aborted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the provider request to be aborted once the socket was closed")
+	}
+}
+
+func TestQueryInternetProductsSocket_ClosingCancelsQuery(t *testing.T) {
+	aborted := make(chan struct{})
+	mockServer := newBlockingServer(aborted)
+	defer mockServer.Close()
+
+	mockProvider := &mockProviderAdapter{mockServer: mockServer}
+	conn := dialSocket(t, newCancellableTestService(mockProvider, cache.NewInstanceCache("test-queue")))
+
+	if err := conn.WriteJSON(SocketRequest{Type: "query", Address: &validAddressDE}); err != nil {
+		t.Fatalf("error sending query: %v", err)
+	}
+	readSocketEvent(t, conn, "status")
+	conn.Close()
+
+	select {
+	case <-aborted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the provider request to be aborted once the socket was closed")
+	}
+}
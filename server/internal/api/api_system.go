@@ -53,6 +53,11 @@ func (c *SystemAPIController) Routes() Routes {
 			"/version",
 			c.GetVersion,
 		},
+		"GetLoad": Route{
+			strings.ToUpper("Get"),
+			"/load",
+			c.GetLoad,
+		},
 	}
 }
 
@@ -67,3 +72,15 @@ func (c *SystemAPIController) GetVersion(w http.ResponseWriter, r *http.Request)
 	// If no error, encode the body and the result code
 	_ = EncodeJSONResponse(result.Body, &result.Code, w, result.Headers)
 }
+
+// GetLoad - Load information endpoint
+func (c *SystemAPIController) GetLoad(w http.ResponseWriter, r *http.Request) {
+	result, err := c.service.GetLoad(r.Context())
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	_ = EncodeJSONResponse(result.Body, &result.Code, w, result.Headers)
+}
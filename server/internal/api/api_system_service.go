@@ -12,6 +12,7 @@ import (
 	"context"
 
 	"github.com/rotmanjanez/check24-gendev-7/config"
+	"github.com/rotmanjanez/check24-gendev-7/internal/workerpool"
 	"github.com/rotmanjanez/check24-gendev-7/pkg/models"
 )
 
@@ -20,16 +21,21 @@ import (
 // Include any external packages or services that will be required by this service.
 type SystemAPIService struct {
 	config *config.Config
+	pool   *workerpool.Pool
 }
 
 // NewSystemAPIService creates a default api service
-func NewSystemAPIService(cfg *config.Config) *SystemAPIService {
+func NewSystemAPIService(cfg *config.Config, pool *workerpool.Pool) *SystemAPIService {
 	if cfg == nil {
 		panic("config cannot be nil")
 	}
+	if pool == nil {
+		panic("pool cannot be nil")
+	}
 
 	return &SystemAPIService{
 		config: cfg,
+		pool:   pool,
 	}
 }
 
@@ -41,3 +47,15 @@ func (s *SystemAPIService) GetVersion(ctx context.Context) (ImplResponse, error)
 		CommitHash: s.config.CommitHash,
 	}), nil
 }
+
+// GetLoad - Load information endpoint
+func (s *SystemAPIService) GetLoad(ctx context.Context) (ImplResponse, error) {
+	stats := s.pool.Stats()
+	return Response(200, models.ServerLoad{
+		Workers:       int32(stats.Workers),
+		Busy:          int32(stats.Busy),
+		Queued:        int32(stats.Queued),
+		QueueCapacity: int32(stats.QueueCapacity),
+		Rejected:      int64(stats.Rejected),
+	}), nil
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/rotmanjanez/check24-gendev-7/config"
+	"github.com/rotmanjanez/check24-gendev-7/internal/workerpool"
 	"github.com/rotmanjanez/check24-gendev-7/pkg/models"
 )
 
@@ -19,7 +20,7 @@ import (
 func setupVersionTest(t *testing.T) (*SystemAPIService, *SystemAPIController, *mux.Router, *config.Config, time.Time) {
 	buildDate := time.Now().UTC()
 	cfg := &config.Config{Version: "vX", BuildDate: buildDate, CommitHash: "hX"}
-	svc := NewSystemAPIService(cfg)
+	svc := NewSystemAPIService(cfg, workerpool.New(1, 1))
 	ctrl := NewSystemAPIController(svc)
 	router := NewRouter(ctrl)
 	if router == nil {
@@ -159,3 +160,22 @@ func TestGetVersionEndpointIgnoreHeaders(t *testing.T) {
 		})
 	}
 }
+
+func TestGetLoad(t *testing.T) {
+	pool := workerpool.New(2, 3)
+	router := NewRouter(NewSystemAPIController(NewSystemAPIService(&config.Config{}, pool)))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/load", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var data models.ServerLoad
+	if err := json.Unmarshal(rr.Body.Bytes(), &data); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if data.Workers != 2 || data.QueueCapacity != 3 || data.Busy != 0 || data.Queued != 0 {
+		t.Errorf("unexpected load %+v", data)
+	}
+}
@@ -57,5 +57,5 @@ func DefaultErrorHandler(w http.ResponseWriter, _ *http.Request, err error, resu
 	}
 
 	// Handle all other errors
-	_ = EncodeJSONResponse(err.Error(), &result.Code, w)
+	_ = EncodeJSONResponse(err.Error(), &result.Code, w, result.Headers)
 }
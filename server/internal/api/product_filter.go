@@ -0,0 +1,107 @@
+package api
+
+import (
+	"cmp"
+	"slices"
+
+	m "github.com/rotmanjanez/check24-gendev-7/pkg/models"
+	"github.com/rotmanjanez/check24-gendev-7/pkg/pricing"
+)
+
+// matchesFilter reports whether product passes every criterion set in filter
+func matchesFilter(product m.InternetProduct, filter m.InternetProductsFilter) bool {
+	if filter.MinSpeed != nil && product.ProductInfo.Speed < *filter.MinSpeed {
+		return false
+	}
+	if filter.MaxMonthlyCost != nil && product.Pricing.MonthlyCostInCent > *filter.MaxMonthlyCost {
+		return false
+	}
+	if len(filter.ConnectionTypes) > 0 && !slices.Contains(filter.ConnectionTypes, product.ProductInfo.ConnectionType) {
+		return false
+	}
+	if filter.MaxContractDuration != nil && contractCommitment(product) > *filter.MaxContractDuration {
+		return false
+	}
+	if filter.TvIncluded != nil && hasTv(product) != *filter.TvIncluded {
+		return false
+	}
+	if filter.InstallationIncluded != nil && product.Pricing.InstallationServiceIncluded != *filter.InstallationIncluded {
+		return false
+	}
+	if len(filter.Provider) > 0 && !slices.Contains(filter.Provider, product.Provider) {
+		return false
+	}
+	return true
+}
+
+func hasTv(product m.InternetProduct) bool {
+	return product.ProductInfo.Tv != nil && *product.ProductInfo.Tv != ""
+}
+
+// contractCommitment is the number of months a customer is bound to product, zero if it can be cancelled monthly
+func contractCommitment(product m.InternetProduct) int32 {
+	if product.Pricing.MinContractDurationInMonths != nil {
+		return *product.Pricing.MinContractDurationInMonths
+	}
+	if product.Pricing.ContractDurationInMonths != nil {
+		return *product.Pricing.ContractDurationInMonths
+	}
+	return 0
+}
+
+// effectiveMonthlyCost is the average monthly cost of product over its contract
+func effectiveMonthlyCost(product m.InternetProduct) int32 {
+	if product.Cost != nil {
+		return product.Cost.EffectiveMonthlyCostInCent
+	}
+	// products fetched before their cost was computed
+	cost, err := pricing.Calculate(product.Pricing)
+	if err != nil {
+		return product.Pricing.MonthlyCostInCent
+	}
+	return cost.EffectiveMonthlyCostInCent
+}
+
+// compareProducts orders products by order, all products are equal if order is not set
+func compareProducts(order m.InternetProductsSortOrder) func(a, b m.InternetProduct) int {
+	switch order {
+	case m.PRICE_ASC:
+		return func(a, b m.InternetProduct) int {
+			return cmp.Compare(a.Pricing.MonthlyCostInCent, b.Pricing.MonthlyCostInCent)
+		}
+	case m.PRICE_DESC:
+		return func(a, b m.InternetProduct) int {
+			return cmp.Compare(b.Pricing.MonthlyCostInCent, a.Pricing.MonthlyCostInCent)
+		}
+	case m.SPEED_ASC:
+		return func(a, b m.InternetProduct) int {
+			return cmp.Compare(a.ProductInfo.Speed, b.ProductInfo.Speed)
+		}
+	case m.SPEED_DESC:
+		return func(a, b m.InternetProduct) int {
+			return cmp.Compare(b.ProductInfo.Speed, a.ProductInfo.Speed)
+		}
+	case m.EFFECTIVE_COST_ASC:
+		return func(a, b m.InternetProduct) int {
+			return cmp.Compare(effectiveMonthlyCost(a), effectiveMonthlyCost(b))
+		}
+	case m.EFFECTIVE_COST_DESC:
+		return func(a, b m.InternetProduct) int {
+			return cmp.Compare(effectiveMonthlyCost(b), effectiveMonthlyCost(a))
+		}
+	}
+	return func(a, b m.InternetProduct) int { return 0 }
+}
+
+// filterProducts returns the products matching filter in its sort order.
+// Equal products keep the order they were fetched in.
+func filterProducts(products []m.InternetProduct, filter m.InternetProductsFilter) []m.InternetProduct {
+	matching := make([]m.InternetProduct, 0, len(products))
+	for _, product := range products {
+		if matchesFilter(product, filter) {
+			matching = append(matching, product)
+		}
+	}
+	slices.SortStableFunc(matching, compareProducts(filter.Sort))
+	return matching
+}
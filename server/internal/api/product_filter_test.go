@@ -0,0 +1,113 @@
+package api
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/rotmanjanez/check24-gendev-7/pkg/models"
+)
+
+func int32Ptr(v int32) *int32 { return &v }
+func boolPtr(v bool) *bool    { return &v }
+
+var (
+	cheapDSL = models.InternetProduct{
+		Id:          "cheap-dsl",
+		Provider:    "byteme",
+		ProductInfo: models.ProductInfo{Speed: 50, ConnectionType: models.DSL},
+		Pricing:     models.Pricing{MonthlyCostInCent: 2000, ContractDurationInMonths: int32Ptr(24)},
+	}
+	fastFiber = models.InternetProduct{
+		Id:          "fast-fiber",
+		Provider:    "webwunder",
+		ProductInfo: models.ProductInfo{Speed: 1000, ConnectionType: models.FIBER, Tv: &[]string{"TV Basic"}[0]},
+		Pricing:     models.Pricing{MonthlyCostInCent: 6000, MinContractDurationInMonths: int32Ptr(12), InstallationServiceIncluded: true},
+	}
+	// discountedCable costs more per month than cheapDSL, but less on average thanks to the discount
+	discountedCable = models.InternetProduct{
+		Id:          "discounted-cable",
+		Provider:    "verbyndich",
+		ProductInfo: models.ProductInfo{Speed: 250, ConnectionType: models.CABLE},
+		Pricing: models.Pricing{
+			MonthlyCostInCent:  2500,
+			PercentageDiscount: &models.PercentageDiscount{Percentage: 50},
+		},
+	}
+	filterTestProducts = []models.InternetProduct{cheapDSL, fastFiber, discountedCable}
+)
+
+func productIds(products []models.InternetProduct) []string {
+	ids := make([]string, len(products))
+	for idx, product := range products {
+		ids[idx] = product.Id
+	}
+	return ids
+}
+
+func TestFilterProducts(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   models.InternetProductsFilter
+		expected []string
+	}{
+		{"no filter keeps order", models.InternetProductsFilter{}, []string{"cheap-dsl", "fast-fiber", "discounted-cable"}},
+		{"min speed", models.InternetProductsFilter{MinSpeed: int32Ptr(250)}, []string{"fast-fiber", "discounted-cable"}},
+		{"max monthly cost", models.InternetProductsFilter{MaxMonthlyCost: int32Ptr(2500)}, []string{"cheap-dsl", "discounted-cable"}},
+		{"connection types", models.InternetProductsFilter{ConnectionTypes: []models.ConnectionType{models.DSL, models.FIBER}}, []string{"cheap-dsl", "fast-fiber"}},
+		{"max contract duration", models.InternetProductsFilter{MaxContractDuration: int32Ptr(12)}, []string{"fast-fiber", "discounted-cable"}},
+		{"tv included", models.InternetProductsFilter{TvIncluded: boolPtr(true)}, []string{"fast-fiber"}},
+		{"tv excluded", models.InternetProductsFilter{TvIncluded: boolPtr(false)}, []string{"cheap-dsl", "discounted-cable"}},
+		{"installation included", models.InternetProductsFilter{InstallationIncluded: boolPtr(true)}, []string{"fast-fiber"}},
+		{"provider", models.InternetProductsFilter{Provider: []string{"byteme", "verbyndich"}}, []string{"cheap-dsl", "discounted-cable"}},
+		{"combined", models.InternetProductsFilter{MinSpeed: int32Ptr(100), MaxMonthlyCost: int32Ptr(3000)}, []string{"discounted-cable"}},
+		{"price ascending", models.InternetProductsFilter{Sort: models.PRICE_ASC}, []string{"cheap-dsl", "discounted-cable", "fast-fiber"}},
+		{"price descending", models.InternetProductsFilter{Sort: models.PRICE_DESC}, []string{"fast-fiber", "discounted-cable", "cheap-dsl"}},
+		{"speed ascending", models.InternetProductsFilter{Sort: models.SPEED_ASC}, []string{"cheap-dsl", "discounted-cable", "fast-fiber"}},
+		{"speed descending", models.InternetProductsFilter{Sort: models.SPEED_DESC}, []string{"fast-fiber", "discounted-cable", "cheap-dsl"}},
+		{"effective cost ascending", models.InternetProductsFilter{Sort: models.EFFECTIVE_COST_ASC}, []string{"discounted-cable", "cheap-dsl", "fast-fiber"}},
+		{"effective cost descending", models.InternetProductsFilter{Sort: models.EFFECTIVE_COST_DESC}, []string{"fast-fiber", "cheap-dsl", "discounted-cable"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := productIds(filterProducts(filterTestProducts, tt.filter))
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+			for idx := range got {
+				if got[idx] != tt.expected[idx] {
+					t.Fatalf("expected %v, got %v", tt.expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseInternetProductsFilter(t *testing.T) {
+	query, _ := url.ParseQuery("minSpeed=100&maxMonthlyCost=3000&connectionTypes=DSL,FIBER&maxContractDuration=12&tvIncluded=true&installationIncluded=false&provider=byteme,webwunder&sort=effective-cost-desc")
+	filter, err := parseInternetProductsFilter(query)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *filter.MinSpeed != 100 || *filter.MaxMonthlyCost != 3000 || *filter.MaxContractDuration != 12 ||
+		len(filter.ConnectionTypes) != 2 || !*filter.TvIncluded || *filter.InstallationIncluded ||
+		len(filter.Provider) != 2 || filter.Sort != models.EFFECTIVE_COST_DESC {
+		t.Errorf("unexpected filter %+v", filter)
+	}
+
+	for _, invalid := range []string{"minSpeed=-1", "maxMonthlyCost=x", "maxContractDuration=", "connectionTypes=DSL,ISDN", "tvIncluded=maybe", "sort=cheapest"} {
+		query, _ := url.ParseQuery(invalid)
+		if _, err := parseInternetProductsFilter(query); err == nil {
+			t.Errorf("expected error for %s", invalid)
+		}
+	}
+
+	// of several invalid parameters, always the same one is reported
+	query, _ = url.ParseQuery("tvIncluded=maybe&maxContractDuration=x&sort=cheapest&minSpeed=-1&maxMonthlyCost=x")
+	for range 20 {
+		var parsingErr *ParsingError
+		if _, err := parseInternetProductsFilter(query); !errors.As(err, &parsingErr) || parsingErr.Param != "minSpeed" {
+			t.Fatalf("expected minSpeed to be reported, got %v", err)
+		}
+	}
+}
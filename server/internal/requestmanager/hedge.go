@@ -0,0 +1,145 @@
+package requestmanager
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	i "github.com/rotmanjanez/check24-gendev-7/pkg/interfaces"
+	m "github.com/rotmanjanez/check24-gendev-7/pkg/models"
+	p "github.com/rotmanjanez/check24-gendev-7/pkg/provider"
+)
+
+// sendResult is the outcome of one of the requests issued by send
+type sendResult struct {
+	idx    int
+	req    *http.Request
+	resp   *http.Response
+	err    error
+	start  time.Time
+	cancel context.CancelFunc
+}
+
+// cancelOnClose releases the context of a hedged request once its response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// send executes req and returns the request that was answered with its response.
+// If the provider hedges requests and no response arrived within its usual latency, the request is rebuilt
+// and sent a second time, if the rate and concurrency limits of the provider allow another call right away.
+// The first successful response is returned and the other request is cancelled. If both fail, the last failure is returned.
+// The caller holds the rate limit token and concurrency slot of req, those of the hedged request are taken and released here.
+func (c *RequestCoordinator) send(ctx context.Context, rc *requestContext, prepared i.PreparedRequest, attempt int, req *http.Request) (*http.Request, *http.Response, error) {
+	cfg := rc.config
+
+	delay, hedge := cfg.Hedger.Delay()
+	if !hedge {
+		start := time.Now()
+		resp, err := cfg.Client.Do(req)
+		if callOutcome(resp, err) == p.OutcomeSuccess {
+			cfg.Hedger.Observe(time.Since(start))
+		}
+		return req, resp, err
+	}
+
+	results := make(chan sendResult, 2)
+	var cancels []context.CancelFunc
+	do := func(req *http.Request, hedged bool) {
+		idx := len(cancels)
+		reqCtx, cancel := context.WithCancel(req.Context())
+		cancels = append(cancels, cancel)
+		go func() {
+			start := time.Now()
+			resp, err := cfg.Client.Do(req.WithContext(reqCtx))
+			if hedged {
+				cfg.Limiter.Release(callOutcome(resp, err), time.Since(start))
+			}
+			results <- sendResult{idx: idx, req: req, resp: resp, err: err, start: start, cancel: cancel}
+		}()
+	}
+	do(req, false)
+	pending := 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var last sendResult
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if !cfg.Hedger.TryHedge() {
+				continue
+			}
+			// rebuild the request, so its body is unread and its signature fresh
+			hedged, err := requestForAttempt(ctx, cfg, prepared, attempt, true)
+			if err != nil {
+				slog.Debug("Error rebuilding hedged request", "adapter", cfg.Adapter.Name(), "error", err)
+				continue
+			}
+			// the hedged request counts against the limits of the provider, but never waits for them
+			if err := cfg.RateLimiter.Allow(ctx); err != nil {
+				slog.Debug("Not hedging request, rate limited", "adapter", cfg.Adapter.Name(), "error", err)
+				continue
+			}
+			if !cfg.Limiter.TryAcquire() {
+				slog.Debug("Not hedging request, concurrency limit reached", "adapter", cfg.Adapter.Name())
+				continue
+			}
+			slog.Debug("Hedging slow request", "adapter", cfg.Adapter.Name(), "delay", delay, "attempt", attempt)
+			rc.progress.update(rc.index, func(status *m.ProviderStatus) {
+				status.RequestsSent++
+			})
+			do(hedged, true)
+			pending++
+		case result := <-results:
+			pending--
+			if last.cancel != nil {
+				discard(last)
+			}
+			last = result
+
+			if callOutcome(result.resp, result.err) == p.OutcomeSuccess {
+				cfg.Hedger.Observe(time.Since(result.start))
+				// cancel the request still in flight, the winner's context is released once its body is closed
+				for idx, cancel := range cancels {
+					if idx != result.idx {
+						cancel()
+					}
+				}
+				go func() {
+					for range pending {
+						discard(<-results)
+					}
+				}()
+				result.resp.Body = &cancelOnClose{ReadCloser: result.resp.Body, cancel: result.cancel}
+				return result.req, result.resp, nil
+			}
+		}
+	}
+
+	if last.err != nil {
+		last.cancel()
+		return last.req, nil, last.err
+	}
+	last.resp.Body = &cancelOnClose{ReadCloser: last.resp.Body, cancel: last.cancel}
+	return last.req, last.resp, nil
+}
+
+// discard closes the response of a request that lost the race and releases its context
+func discard(result sendResult) {
+	if result.resp != nil {
+		io.Copy(io.Discard, result.resp.Body)
+		result.resp.Body.Close()
+	}
+	result.cancel()
+}
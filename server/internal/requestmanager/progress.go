@@ -0,0 +1,245 @@
+package requestmanager
+
+import (
+	"sync"
+
+	"github.com/rotmanjanez/check24-gendev-7/pkg/checkpoint"
+	i "github.com/rotmanjanez/check24-gendev-7/pkg/interfaces"
+	m "github.com/rotmanjanez/check24-gendev-7/pkg/models"
+)
+
+// Progress tracks the status of every provider of a single Run.
+// It is safe for concurrent use.
+type Progress struct {
+	mutex     sync.Mutex
+	statuses  []m.ProviderStatus
+	errored   []bool
+	complete  bool
+	cancelled bool
+	changed   chan struct{}
+
+	partial       chan struct{}
+	partialClosed bool
+
+	// state needed to resume the query, see Checkpoint
+	prepared []bool
+	done     []bool
+	pending  []map[uint64]pendingRequest
+	nextID   uint64
+}
+
+// pendingRequest is a request that was not answered yet, err is set if it cannot be serialized
+type pendingRequest struct {
+	request checkpoint.Request
+	err     error
+}
+
+func newProgress(names []string) *Progress {
+	statuses := make([]m.ProviderStatus, len(names))
+	for idx, name := range names {
+		statuses[idx] = m.ProviderStatus{Provider: name, State: m.PENDING}
+	}
+	pending := make([]map[uint64]pendingRequest, len(names))
+	for idx := range pending {
+		pending[idx] = make(map[uint64]pendingRequest)
+	}
+	return &Progress{
+		statuses: statuses,
+		errored:  make([]bool, len(names)),
+		changed:  make(chan struct{}, 1),
+		partial:  make(chan struct{}),
+		prepared: make([]bool, len(names)),
+		done:     make([]bool, len(names)),
+		pending:  pending,
+	}
+}
+
+// Snapshot returns a copy of the current status of all providers
+func (p *Progress) Snapshot() m.InternetProductsQueryStatus {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	providers := make([]m.ProviderStatus, len(p.statuses))
+	copy(providers, p.statuses)
+	return m.InternetProductsQueryStatus{
+		Providers: providers,
+		Complete:  p.complete,
+		Partial:   p.partialClosed,
+		Cancelled: p.cancelled,
+	}
+}
+
+// Changed returns a channel that receives a value whenever the status changed.
+// Changes are coalesced, a receiver only learns that at least one change happened.
+// The channel is closed once all providers finished.
+func (p *Progress) Changed() <-chan struct{} {
+	return p.changed
+}
+
+// Partial returns a channel that is closed once every provider either finished or missed its soft deadline
+func (p *Progress) Partial() <-chan struct{} {
+	return p.partial
+}
+
+func (p *Progress) update(idx int, fn func(status *m.ProviderStatus)) {
+	p.mutex.Lock()
+	fn(&p.statuses[idx])
+	p.mutex.Unlock()
+	p.notify()
+}
+
+func (p *Progress) fail(idx int, phase i.ErrorPhase) {
+	p.mutex.Lock()
+	p.statuses[idx].LastErrorCategory = string(phase)
+	p.errored[idx] = true
+	p.mutex.Unlock()
+	p.notify()
+}
+
+// finish moves a provider that is still running into its terminal state.
+// Providers stopped because the query was cancelled end up CANCELLED.
+func (p *Progress) finish(idx int, cancelled bool) {
+	p.mutex.Lock()
+	status := &p.statuses[idx]
+	if !status.State.IsTerminal() {
+		switch {
+		case cancelled:
+			status.State = m.CANCELLED
+			p.cancelled = true
+		case status.RequestsSent == 0 && status.ProductCount == 0 && !p.errored[idx]:
+			status.State = m.SKIPPED_UNSUPPORTED
+		case status.ProductCount == 0 && p.errored[idx]:
+			status.State = m.FAILED
+		default:
+			status.State = m.COMPLETED
+		}
+	}
+	p.done[idx] = !cancelled
+	p.checkPartial()
+	p.mutex.Unlock()
+	p.notify()
+}
+
+// Checkpoint returns the state of all providers needed to resume the query with RequestCoordinator.Resume.
+// It fails if a pending request cannot be serialized, e.g. because the type of its metadata is not registered.
+func (p *Progress) Checkpoint() ([]checkpoint.Provider, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	providers := make([]checkpoint.Provider, len(p.statuses))
+	for idx, status := range p.statuses {
+		providers[idx] = checkpoint.Provider{
+			Status:   status,
+			Prepared: p.prepared[idx],
+			Done:     p.done[idx],
+		}
+		for _, pending := range p.pending[idx] {
+			if pending.err != nil {
+				return nil, pending.err
+			}
+			providers[idx].Pending = append(providers[idx].Pending, pending.request)
+		}
+	}
+	return providers, nil
+}
+
+// restore sets the state of a provider from a checkpoint, unfinished providers are running again
+func (p *Progress) restore(idx int, state checkpoint.Provider) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	status := state.Status
+	if !state.Done {
+		status.State = m.PENDING
+		if state.Prepared {
+			status.State = m.RUNNING
+		}
+	}
+	p.statuses[idx] = status
+	p.errored[idx] = status.LastErrorCategory != ""
+	p.prepared[idx] = state.Prepared
+	p.done[idx] = state.Done
+}
+
+// markPrepared records that PrepareRequest returned and its requests are pending
+func (p *Progress) markPrepared(idx int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.prepared[idx] = true
+}
+
+// addPending records a request scheduled at depth and returns its id
+func (p *Progress) addPending(idx int, prepared i.PreparedRequest, depth int) uint64 {
+	request, err := checkpoint.Encode(prepared, depth)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.nextID++
+	p.pending[idx][p.nextID] = pendingRequest{request: request, err: err}
+	return p.nextID
+}
+
+// removePending forgets an answered request
+func (p *Progress) removePending(idx int, id uint64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.pending[idx], id)
+}
+
+// missDeadline marks a provider that is still running at its soft deadline
+func (p *Progress) missDeadline(idx int) {
+	p.mutex.Lock()
+	status := &p.statuses[idx]
+	if status.State.IsTerminal() {
+		p.mutex.Unlock()
+		return
+	}
+	status.PastDeadline = true
+	p.checkPartial()
+	p.mutex.Unlock()
+	p.notify()
+}
+
+// pastDeadline reports whether the provider missed its soft deadline
+func (p *Progress) pastDeadline(idx int) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.statuses[idx].PastDeadline
+}
+
+// checkPartial closes the partial channel once no provider is waited for anymore.
+// The mutex must be held.
+func (p *Progress) checkPartial() {
+	if p.partialClosed {
+		return
+	}
+	for _, status := range p.statuses {
+		if !status.State.IsTerminal() && !status.PastDeadline {
+			return
+		}
+	}
+	p.partialClosed = true
+	close(p.partial)
+}
+
+func (p *Progress) close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.complete = true
+	p.checkPartial()
+	close(p.changed)
+}
+
+// notify signals a change, it is a no-op once the progress is closed,
+// e.g. for a soft deadline that fired while the last provider finished
+func (p *Progress) notify() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.complete {
+		return
+	}
+	select {
+	case p.changed <- struct{}{}:
+	default:
+	}
+}
@@ -2,28 +2,86 @@ package requestmanager
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/rotmanjanez/check24-gendev-7/pkg/checkpoint"
 	i "github.com/rotmanjanez/check24-gendev-7/pkg/interfaces"
 	m "github.com/rotmanjanez/check24-gendev-7/pkg/models"
+	pc "github.com/rotmanjanez/check24-gendev-7/pkg/pricing"
 	p "github.com/rotmanjanez/check24-gendev-7/pkg/provider"
 )
 
 // requestContext tracks inflight work per provider
 // via its own WaitGroup for follow-up requests.
 type requestContext struct {
-	config *p.ProviderConfig
-	wg     sync.WaitGroup
+	config   *p.ProviderConfig
+	wg       sync.WaitGroup
+	progress *Progress
+	index    int // position of the provider in progress
+
+	mutex    sync.Mutex
+	products []m.InternetProduct // emitted products, collected for the result cache
+	failed   atomic.Bool         // set if not all products could be fetched
+
+	budget  *p.FanOutBudget
+	stopped atomic.Bool // set once the provider exceeded its budget
+
+	jobs   *distributor                  // set if requests are sent as jobs through a work queue
+	parsed func(parsed i.ParsedResponse) // set by workers to return parsed responses instead of handling them
+}
+
+func newRequestContext(cfg *p.ProviderConfig, progress *Progress, index int) *requestContext {
+	return &requestContext{config: cfg, progress: progress, index: index, budget: p.NewFanOutBudget(cfg.FanOut)}
+}
+
+// refreshTimeout limits background refreshes of cached results
+const refreshTimeout = 60 * time.Second
+
+// fail reports err for the provider and records its phase in the progress
+func (rc *requestContext) fail(errors chan<- *i.ProviderError, err *i.ProviderError) {
+	err.Provider = rc.config.Adapter.Name()
+	// invalid products are dropped, the remaining result is still complete
+	if err.Phase != i.PhaseValidation {
+		rc.failed.Store(true)
+	}
+	rc.progress.fail(rc.index, err.Phase)
+	errors <- err
+}
+
+// stop reports a budget violation once and stops emitting products and scheduling requests for the provider.
+// Requests in flight are finished, but their results are dropped.
+func (rc *requestContext) stop(errors chan<- *i.ProviderError, err error) {
+	if rc.stopped.CompareAndSwap(false, true) {
+		slog.Warn("Stopping provider, fan-out budget exceeded", "adapter", rc.config.Adapter.Name(), "error", err)
+		rc.fail(errors, &i.ProviderError{Phase: i.PhaseBudget, Err: err})
+	}
+}
+
+// collect remembers an emitted product if the provider's results are cached
+func (rc *requestContext) collect(product m.InternetProduct) {
+	if rc.config.ResultCache == nil {
+		return
+	}
+	rc.mutex.Lock()
+	rc.products = append(rc.products, product)
+	rc.mutex.Unlock()
 }
 
 // RequestCoordinator dispatches a Request across providers
 // and collects results from fresh channels per call.
 type RequestCoordinator struct {
 	providers []*p.ProviderConfig
+	queue     *WorkQueue // nil unless requests are distributed
+	fresh     bool       // set if cached results are not served
 }
 
 // NewRequestCoordinator returns a coordinator over the given provider configs
@@ -31,64 +89,304 @@ func NewRequestCoordinator(cfgs []*p.ProviderConfig) *RequestCoordinator {
 	return &RequestCoordinator{providers: cfgs}
 }
 
+// WithWorkQueue returns a coordinator over the same providers that sends all requests as jobs through queue.
+// Its Work method executes the jobs of all instances.
+func (c *RequestCoordinator) WithWorkQueue(queue *WorkQueue) *RequestCoordinator {
+	return &RequestCoordinator{providers: c.providers, queue: queue, fresh: c.fresh}
+}
+
+// Fresh returns a coordinator over the same providers that queries them even if their results are cached.
+// The results of its runs still replace the cached results.
+func (c *RequestCoordinator) Fresh() *RequestCoordinator {
+	return &RequestCoordinator{providers: c.providers, queue: c.queue, fresh: true}
+}
+
+// UnknownProviderError is returned when selecting a provider that is not configured
+type UnknownProviderError struct {
+	Name string
+}
+
+func (e *UnknownProviderError) Error() string {
+	return fmt.Sprintf("unknown provider '%s'", e.Name)
+}
+
+// Select returns a coordinator over the subset of providers with the given names.
+// Names are matched case-insensitively, empty names and duplicates are ignored.
+// The returned coordinator shares the provider configs, and with them concurrency limits and breakers.
+func (c *RequestCoordinator) Select(names []string) (*RequestCoordinator, error) {
+	selected := make([]*p.ProviderConfig, 0, len(names))
+	seen := make(map[*p.ProviderConfig]bool)
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		idx := slices.IndexFunc(c.providers, func(cfg *p.ProviderConfig) bool {
+			return strings.EqualFold(cfg.Adapter.Name(), name)
+		})
+		if idx < 0 {
+			return nil, &UnknownProviderError{Name: name}
+		}
+		if cfg := c.providers[idx]; !seen[cfg] {
+			seen[cfg] = true
+			selected = append(selected, cfg)
+		}
+	}
+
+	if len(selected) == 0 {
+		return c, nil
+	}
+	return &RequestCoordinator{providers: selected, queue: c.queue, fresh: c.fresh}, nil
+}
+
+// Names returns the names of all providers of the coordinator
+func (c *RequestCoordinator) Names() []string {
+	names := make([]string, len(c.providers))
+	for idx, cfg := range c.providers {
+		names[idx] = cfg.Adapter.Name()
+	}
+	return names
+}
+
 // Run executes req on all providers, returning new channels for responses and errors
-func (c *RequestCoordinator) Run(ctx context.Context, req i.Request, respBuf, errBuf int) (<-chan m.InternetProduct, <-chan error) {
+// and the progress of each provider
+func (c *RequestCoordinator) Run(ctx context.Context, req i.Request, respBuf, errBuf int) (<-chan m.InternetProduct, <-chan *i.ProviderError, *Progress) {
+	return c.run(ctx, req, nil, respBuf, errBuf)
+}
+
+// Resume continues a query from the state of its providers taken with Progress.Checkpoint, possibly on another instance.
+// Finished providers are not queried again, prepared providers only send their pending requests
+// and all other providers are started from scratch. Providers are matched by name.
+func (c *RequestCoordinator) Resume(ctx context.Context, req i.Request, providers []checkpoint.Provider, respBuf, errBuf int) (<-chan m.InternetProduct, <-chan *i.ProviderError, *Progress) {
+	states := make(map[string]checkpoint.Provider, len(providers))
+	for _, state := range providers {
+		states[state.Status.Provider] = state
+	}
+	return c.run(ctx, req, states, respBuf, errBuf)
+}
+
+func (c *RequestCoordinator) run(ctx context.Context, req i.Request, states map[string]checkpoint.Provider, respBuf, errBuf int) (<-chan m.InternetProduct, <-chan *i.ProviderError, *Progress) {
 	responses := make(chan m.InternetProduct, respBuf)
-	errors := make(chan error, errBuf)
+	errors := make(chan *i.ProviderError, errBuf)
+	progress := newProgress(c.Names())
 	var wg sync.WaitGroup
 
+	var jobs *distributor
+	if c.queue != nil {
+		jobs = newDistributor(c.queue)
+		go jobs.consume(ctx, c, req, responses, errors)
+	}
+
 	// dispatch per provider
-	for _, cfg := range c.providers {
+	for idx, cfg := range c.providers {
 		wg.Add(1)
-		rctx := &requestContext{config: cfg}
-		go func(pc *p.ProviderConfig, rc *requestContext) {
+		rctx := newRequestContext(cfg, progress, idx)
+		rctx.jobs = jobs
+		state, resumed := states[cfg.Adapter.Name()]
+		if resumed {
+			progress.restore(idx, state)
+		}
+		go func(rc *requestContext) {
 			defer wg.Done()
-			// initial preparation and follow-ups
-			c.dispatchProvider(ctx, pc, req, responses, errors, &rc.wg)
-			// wait for all follow-up requests to finish
-			rc.wg.Wait()
-		}(cfg, rctx)
+			if cfg.SoftDeadline > 0 {
+				deadline := time.AfterFunc(cfg.SoftDeadline, func() {
+					slog.Info("Provider missed its soft deadline", "adapter", cfg.Adapter.Name(), "deadline", cfg.SoftDeadline)
+					progress.missDeadline(rc.index)
+				})
+				defer deadline.Stop()
+			}
+			switch {
+			case resumed && state.Done:
+			case resumed && state.Prepared:
+				c.resumeProvider(ctx, rc, req, state.Pending, responses, errors)
+			default:
+				c.runProvider(ctx, rc, req, responses, errors)
+			}
+			// a deadline ends the query as usual, only explicit cancellation is reported
+			progress.finish(rc.index, ctx.Err() == context.Canceled)
+		}(rctx)
 	}
 
 	// close channels when all work completes
 	go func() {
 		wg.Wait()
+		if jobs != nil {
+			jobs.close()
+		}
 		close(responses)
 		close(errors)
+		progress.close()
 	}()
 
-	return responses, errors
+	return responses, errors, progress
+}
+
+// runProvider serves the products of a single provider from the result cache if possible
+// and queries the provider otherwise. Stale cached products are refreshed in the background.
+func (c *RequestCoordinator) runProvider(
+	ctx context.Context,
+	rc *requestContext,
+	req i.Request,
+	responses chan<- m.InternetProduct,
+	errors chan<- *i.ProviderError,
+) {
+	cfg := rc.config
+	if c.fresh {
+		c.fetch(ctx, rc, req, responses, errors)
+		return
+	}
+
+	products, fresh, found := cfg.ResultCache.Get(ctx, req.Address)
+	if !found {
+		c.fetch(ctx, rc, req, responses, errors)
+		return
+	}
+
+	slog.Debug("Serving cached products", "adapter", cfg.Adapter.Name(), "count", len(products), "fresh", fresh)
+	for _, product := range products {
+		responses <- product
+	}
+	rc.progress.update(rc.index, func(status *m.ProviderStatus) {
+		status.ProductCount += int32(len(products))
+	})
+	if !fresh {
+		c.refresh(ctx, cfg, req)
+	}
+}
+
+// resumeProvider sends the pending requests of a provider that was prepared before the query was resumed.
+// Its products are not cached, as the products emitted before are unknown.
+func (c *RequestCoordinator) resumeProvider(
+	ctx context.Context,
+	rc *requestContext,
+	req i.Request,
+	pending []checkpoint.Request,
+	responses chan<- m.InternetProduct,
+	errors chan<- *i.ProviderError,
+) {
+	slog.Info("Resuming provider", "adapter", rc.config.Adapter.Name(), "pending", len(pending))
+	for _, request := range pending {
+		prepared, err := request.Decode(ctx, c.adapter)
+		if err != nil {
+			rc.fail(errors, &i.ProviderError{Phase: i.PhasePrepare, Err: fmt.Errorf("error resuming request: %w", err)})
+			continue
+		}
+		c.schedule(ctx, rc, prepared, request.Depth, req, responses, errors)
+	}
+	rc.wg.Wait()
+}
+
+// adapter returns the adapter of the provider called name, or nil if there is none
+func (c *RequestCoordinator) adapter(name string) i.ProviderAdapter {
+	if cfg := c.provider(name); cfg != nil {
+		return cfg.Adapter
+	}
+	return nil
+}
+
+// fetch queries the provider and caches its products if all calls succeeded
+func (c *RequestCoordinator) fetch(
+	ctx context.Context,
+	rc *requestContext,
+	req i.Request,
+	responses chan<- m.InternetProduct,
+	errors chan<- *i.ProviderError,
+) {
+	// initial preparation and follow-ups
+	c.dispatchProvider(ctx, rc, req, responses, errors)
+	// wait for all follow-up requests to finish
+	rc.wg.Wait()
+
+	if !rc.failed.Load() && ctx.Err() == nil {
+		rc.config.ResultCache.Set(ctx, req.Address, rc.products)
+	}
+}
+
+// refresh updates the cached products of a provider in the background.
+// At most one refresh per provider and address runs at a time across all instances.
+func (c *RequestCoordinator) refresh(ctx context.Context, cfg *p.ProviderConfig, req i.Request) {
+	ctx = context.WithoutCancel(ctx)
+	if !cfg.ResultCache.LockRefresh(ctx, req.Address, refreshTimeout) {
+		return
+	}
+
+	go func() {
+		defer cfg.ResultCache.UnlockRefresh(ctx, req.Address)
+		refreshCtx, cancel := context.WithTimeout(ctx, refreshTimeout)
+		defer cancel()
+
+		responses := make(chan m.InternetProduct)
+		errors := make(chan *i.ProviderError)
+		go func() {
+			for range responses {
+			}
+		}()
+		go func() {
+			for err := range errors {
+				slog.Debug("Error refreshing cached products", "error", err)
+			}
+		}()
+
+		rc := newRequestContext(cfg, newProgress([]string{cfg.Adapter.Name()}), 0)
+		c.fetch(refreshCtx, rc, req, responses, errors)
+		close(responses)
+		close(errors)
+	}()
 }
 
 // dispatchProvider handles a single provider's preparation
-// and issues follow-up requests via the provided wait group.
+// and issues follow-up requests via the request context's wait group.
 func (c *RequestCoordinator) dispatchProvider(
 	ctx context.Context,
-	cfg *p.ProviderConfig,
+	rc *requestContext,
 	initialReq i.Request,
 	responses chan<- m.InternetProduct,
-	errors chan<- error,
-	wg *sync.WaitGroup,
+	errors chan<- *i.ProviderError,
 ) {
+	cfg := rc.config
+
+	// skip providers with an open breaker before doing any work
+	if cfg.Breaker.State(ctx) == p.BreakerOpen {
+		slog.Info("Skipping provider, circuit breaker is open", "adapter", cfg.Adapter.Name())
+		rc.progress.update(rc.index, func(status *m.ProviderStatus) {
+			status.State = m.SKIPPED_CIRCUIT_OPEN
+		})
+		rc.fail(errors, &i.ProviderError{Phase: i.PhaseCircuitOpen, Err: p.ErrCircuitOpen})
+		return
+	}
+
+	rc.progress.update(rc.index, func(status *m.ProviderStatus) {
+		status.State = m.RUNNING
+	})
+
 	parsedResp, err := cfg.Adapter.PrepareRequest(ctx, initialReq)
 	if err != nil {
-		errors <- err
+		rc.fail(errors, &i.ProviderError{Phase: i.PhasePrepare, Err: err})
 		return
 	}
 	// handle initial parse and spawn follow-ups
-	c.handleParsed(ctx, cfg, parsedResp, initialReq, responses, errors, wg)
+	c.handleParsed(ctx, rc, parsedResp, 0, initialReq, responses, errors)
+	// all initial requests are pending now, until then a resumed query prepares the provider again
+	rc.progress.markPrepared(rc.index)
 }
 
-// handleParsed emits products and schedules follow-up requests
+// handleParsed emits products and schedules follow-up requests.
+// depth is the follow-up level of the requests in parsed, zero for those returned by PrepareRequest.
 func (c *RequestCoordinator) handleParsed(
 	ctx context.Context,
-	cfg *p.ProviderConfig,
+	rc *requestContext,
 	parsed i.ParsedResponse,
+	depth int,
 	orig i.Request,
 	responses chan<- m.InternetProduct,
-	errors chan<- error,
-	wg *sync.WaitGroup,
+	errors chan<- *i.ProviderError,
 ) {
+	cfg := rc.config
+	if rc.stopped.Load() {
+		return
+	}
+
 	// emit parsed products
 	for _, p := range parsed.InternetProducts {
 		// check if product date offered is zero and set to now
@@ -100,18 +398,33 @@ func (c *RequestCoordinator) handleParsed(
 		err := m.AssertInternetProductRequired(p)
 		if err != nil {
 			slog.Warn("InternetProduct missing fields", "provider", cfg.Adapter.Name(), "product", p, "error", err)
-			errors <- fmt.Errorf("invalid product from %s: %w", cfg.Adapter.Name(), err)
+			rc.fail(errors, &i.ProviderError{Phase: i.PhaseValidation, Err: fmt.Errorf("invalid product %s: %w", p.Id, err)})
 			continue
 		}
 
 		err = m.AssertInternetProductConstraints(p)
 		if err != nil {
 			slog.Warn("Invalid InternetProduct constraints", "provider", cfg.Adapter.Name(), "product", p, "error", err)
-			errors <- fmt.Errorf("invalid product from %s: %w", cfg.Adapter.Name(), err)
+			rc.fail(errors, &i.ProviderError{Phase: i.PhaseValidation, Err: fmt.Errorf("invalid product %s: %w", p.Id, err)})
 			continue
 		}
-		// canonicalize the product before sending
+		if err := rc.budget.Product(); err != nil {
+			rc.stop(errors, err)
+			return
+		}
+		// products whose cost cannot be computed are still offered, just without cost
+		if cost, err := pc.Calculate(p.Pricing); err != nil {
+			slog.Warn("Cannot compute InternetProduct cost", "provider", cfg.Adapter.Name(), "product", p.Id, "error", err)
+		} else {
+			p.Cost = &cost
+		}
+		// only emitted products are flagged, cached ones are not late for later queries
+		rc.collect(p)
+		p.Late = rc.progress.pastDeadline(rc.index)
 		responses <- p
+		rc.progress.update(rc.index, func(status *m.ProviderStatus) {
+			status.ProductCount++
+		})
 	}
 
 	// issue follow-up requests in parallel
@@ -121,75 +434,245 @@ func (c *RequestCoordinator) handleParsed(
 			continue // skip if no follow-up request
 		}
 
+		if err := rc.budget.Request(depth); err != nil {
+			rc.stop(errors, err)
+			return
+		}
+
 		if follow.Callback == nil {
 			follow.Callback = cfg.Adapter
 		}
-		follow.Request = follow.Request.WithContext(ctx)
+		c.schedule(ctx, rc, follow, depth, orig, responses, errors)
+	}
+}
 
-		wg.Add(1)
-		// each follow-up decrements on completion
-		go func(r i.Response) {
-			defer wg.Done()
-			c.dispatchRequest(ctx, cfg, r, orig, responses, errors, wg)
-		}(i.Response{InitialRequestData: orig, Request: follow})
+// schedule dispatches a request in the background, or pushes it as job if requests are distributed,
+// and tracks it as pending until it was handled
+func (c *RequestCoordinator) schedule(
+	ctx context.Context,
+	rc *requestContext,
+	prepared i.PreparedRequest,
+	depth int,
+	orig i.Request,
+	responses chan<- m.InternetProduct,
+	errors chan<- *i.ProviderError,
+) {
+	if rc.jobs != nil {
+		rc.jobs.enqueue(ctx, rc, prepared, depth, orig, errors)
+		return
+	}
+
+	prepared.Request = prepared.Request.WithContext(ctx)
+	id := rc.progress.addPending(rc.index, prepared, depth)
+
+	rc.wg.Add(1)
+	// each follow-up decrements on completion
+	go func(r i.Response) {
+		defer rc.wg.Done()
+		c.dispatchRequest(ctx, rc, r, depth, orig, responses, errors)
+		// requests abandoned with the query stay pending, so that they are sent again when it is resumed
+		if ctx.Err() == nil {
+			rc.progress.removePending(rc.index, id)
+		}
+	}(i.Response{InitialRequestData: orig, Request: prepared})
+}
+
+// quotaExhausted reports whether err was caused by the daily quota of a provider
+func quotaExhausted(err error) bool {
+	return errors.Is(err, p.ErrQuotaExhausted)
+}
+
+// callOutcome classifies the result of a call for the concurrency limiter
+func callOutcome(resp *http.Response, err error) p.CallOutcome {
+	var netErr net.Error
+	switch {
+	case err != nil && (errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())):
+		return p.OutcomeOverload
+	case err != nil:
+		return p.OutcomeFailure
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return p.OutcomeOverload
+	case resp.StatusCode >= 500:
+		return p.OutcomeFailure
+	default:
+		return p.OutcomeSuccess
 	}
 }
 
-// dispatchRequest executes a single HTTP call with retry and backoff
+// requestForAttempt returns the signed request to send for attempt.
+// Adapters implementing RequestRefresher rebuild the request before every attempt,
+// otherwise the body of the prepared request is replayed with GetBody if replay is set.
+// replay must be set whenever the prepared request may have been sent before.
+// The signers of the provider are applied to the rebuilt request.
+func requestForAttempt(ctx context.Context, cfg *p.ProviderConfig, prepared i.PreparedRequest, attempt int, replay bool) (*http.Request, error) {
+	req, err := rebuildRequest(ctx, cfg.Adapter, prepared, attempt, replay)
+	if err != nil || cfg.Signer == nil {
+		return req, err
+	}
+
+	// sign a copy, so the prepared request stays unchanged for later attempts
+	if req == prepared.Request {
+		req = req.Clone(req.Context())
+	}
+	if err := cfg.Signer.Sign(req); err != nil {
+		return nil, fmt.Errorf("error signing request: %w", err)
+	}
+	return req, nil
+}
+
+func rebuildRequest(ctx context.Context, adapter i.ProviderAdapter, prepared i.PreparedRequest, attempt int, replay bool) (*http.Request, error) {
+	if refresher, ok := adapter.(i.RequestRefresher); ok {
+		req, err := refresher.RefreshRequest(ctx, prepared, attempt)
+		if err != nil {
+			return nil, fmt.Errorf("error refreshing request: %w", err)
+		}
+		return req, nil
+	}
+	return replayRequest(prepared, replay)
+}
+
+func replayRequest(prepared i.PreparedRequest, replay bool) (*http.Request, error) {
+	req := prepared.Request
+	if !replay || req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("request body of %s %s cannot be replayed", req.Method, req.URL.Redacted())
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("error replaying request body: %w", err)
+	}
+	retry := req.Clone(req.Context())
+	retry.Body = body
+	return retry, nil
+}
+
+// dispatchRequest executes a single HTTP call with retry and backoff.
+// Waits between attempts are aborted as soon as ctx is done.
 func (c *RequestCoordinator) dispatchRequest(
 	ctx context.Context,
-	cfg *p.ProviderConfig,
+	rc *requestContext,
 	respWrapper i.Response,
+	depth int,
 	orig i.Request,
 	responses chan<- m.InternetProduct,
-	errors chan<- error,
-	wg *sync.WaitGroup,
+	errors chan<- *i.ProviderError,
 ) {
+	cfg := rc.config
+	prepared := respWrapper.Request
+
 	for attempt := 0; attempt <= cfg.RetryCount; attempt++ {
+		if rc.stopped.Load() {
+			return
+		}
 		if attempt > 0 {
 			slog.Info("Retrying request", "adapter", cfg.Adapter.Name(), "attempt", attempt)
 		}
-		cfg.Semaphore <- struct{}{}
-		resp, err := cfg.Client.Do(respWrapper.Request.Request)
-		<-cfg.Semaphore
+		if err := cfg.Breaker.Allow(ctx); err != nil {
+			slog.Debug("Skipping request, circuit breaker is open", "adapter", cfg.Adapter.Name())
+			rc.fail(errors, &i.ProviderError{Phase: i.PhaseCircuitOpen, Attempt: attempt, Err: err})
+			return
+		}
+
+		// calls allowed by the breaker are recorded once sent, all other paths release them
+		release := func() { cfg.Breaker.Release(context.WithoutCancel(ctx)) }
+
+		if err := cfg.RateLimiter.Wait(ctx); err != nil {
+			release()
+			if quotaExhausted(err) {
+				slog.Warn("Skipping request, daily quota exhausted", "adapter", cfg.Adapter.Name())
+				rc.progress.update(rc.index, func(status *m.ProviderStatus) {
+					status.State = m.QUOTA_EXHAUSTED
+				})
+			} else {
+				err = fmt.Errorf("request abandoned: %w", err)
+			}
+			rc.fail(errors, &i.ProviderError{Phase: i.PhaseTransport, Attempt: attempt, Err: err})
+			return
+		}
+
+		req, err := requestForAttempt(ctx, cfg, prepared, attempt, attempt > 0)
 		if err != nil {
+			release()
+			rc.fail(errors, &i.ProviderError{Phase: i.PhasePrepare, Attempt: attempt, Err: err})
+			return
+		}
+		rc.progress.update(rc.index, func(status *m.ProviderStatus) {
+			status.RequestsSent++
+			if attempt > 0 {
+				status.Retries++
+			}
+		})
+
+		if err := cfg.Limiter.Acquire(ctx); err != nil {
+			release()
+			rc.fail(errors, &i.ProviderError{Phase: i.PhaseTransport, Attempt: attempt, Err: fmt.Errorf("request abandoned: %w", err)})
+			return
+		}
+		start := time.Now()
+		sent, resp, err := c.send(ctx, rc, prepared, attempt, req)
+		latency := time.Since(start)
+		cfg.Limiter.Release(callOutcome(resp, err), latency)
+		// client errors and abandoned queries are not the provider's fault and do not count as failures
+		if ctx.Err() == nil {
+			cfg.Breaker.Record(ctx, err == nil && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500, latency)
+		} else {
+			release()
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				slog.Debug("Query abandoned, stopping request", "adapter", cfg.Adapter.Name(), "error", ctx.Err())
+				rc.fail(errors, &i.ProviderError{Phase: i.PhaseTransport, Attempt: attempt, Err: fmt.Errorf("request abandoned: %w", ctx.Err())})
+				return
+			}
 			slog.Debug("Error executing request", "adapter", cfg.Adapter.Name(), "error", err, "attempt", attempt)
 			if attempt == cfg.RetryCount {
 				slog.Debug("Max retries reached, giving up", "adapter", cfg.Adapter.Name(), "error", err)
-				errors <- err
+				rc.fail(errors, &i.ProviderError{Phase: i.PhaseTransport, Attempt: attempt, Retryable: true, Err: err})
+				return
+			}
+			if werr := cfg.RetryPolicy.Wait(ctx, cfg.RetryPolicy.Delay(attempt, nil)); werr != nil {
+				rc.fail(errors, &i.ProviderError{Phase: i.PhaseTransport, Attempt: attempt, Retryable: true, Err: fmt.Errorf("retry abandoned: %w", werr)})
+				return
 			}
-			time.Sleep(cfg.BackoffInterval)
 			continue
 		}
 
-		switch resp.StatusCode {
-		case http.StatusOK:
+		if resp.StatusCode == http.StatusOK {
+			// the hedged request may have won the race
+			respWrapper.Request.Request = sent
 			respWrapper.HTTPResponse = resp
 			parsed, perr := cfg.Adapter.ParseResponse(ctx, respWrapper)
 			resp.Body.Close()
 			if perr != nil {
-				errors <- perr
+				rc.fail(errors, &i.ProviderError{Phase: i.PhaseParse, Attempt: attempt, StatusCode: resp.StatusCode, Err: perr})
+			} else if rc.parsed != nil {
+				rc.parsed(parsed)
 			} else {
-				c.handleParsed(ctx, cfg, parsed, orig, responses, errors, wg)
+				c.handleParsed(ctx, rc, parsed, depth+1, orig, responses, errors)
 			}
 			return
+		}
 
-		case http.StatusTooManyRequests:
-			resp.Body.Close()
-			time.Sleep(cfg.BackoffInterval)
-			continue // retry after backoff
-
-		default:
-			slog.Debug("Unexpected response from provider",
-				"adapter", cfg.Adapter.Name(),
-				"statusCode", resp.StatusCode,
-				"request", respWrapper.Request.Request.URL.String(),
-			)
-			resp.Body.Close()
-			if attempt == cfg.RetryCount {
-				errors <- fmt.Errorf("unexpected responses after mutliple retries from %s: %s", cfg.Adapter.Name(), resp.Status)
-			}
-			continue
+		slog.Debug("Unexpected response from provider",
+			"adapter", cfg.Adapter.Name(),
+			"statusCode", resp.StatusCode,
+			"request", prepared.Request.URL.String(), // unsigned, so no credentials are logged
+		)
+		resp.Body.Close()
+
+		if !cfg.RetryPolicy.Retryable(resp.StatusCode) {
+			rc.fail(errors, &i.ProviderError{Phase: i.PhaseStatus, Attempt: attempt, StatusCode: resp.StatusCode, Err: fmt.Errorf("unexpected response: %s", resp.Status)})
+			return
+		}
+		if attempt == cfg.RetryCount {
+			rc.fail(errors, &i.ProviderError{Phase: i.PhaseStatus, Attempt: attempt, StatusCode: resp.StatusCode, Retryable: true, Err: fmt.Errorf("unexpected responses after multiple retries: %s", resp.Status)})
+			return
+		}
+		if werr := cfg.RetryPolicy.Wait(ctx, cfg.RetryPolicy.Delay(attempt, resp)); werr != nil {
+			rc.fail(errors, &i.ProviderError{Phase: i.PhaseStatus, Attempt: attempt, StatusCode: resp.StatusCode, Retryable: true, Err: fmt.Errorf("retry abandoned after %s: %w", resp.Status, werr)})
+			return
 		}
 	}
 }
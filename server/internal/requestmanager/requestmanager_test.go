@@ -2,11 +2,23 @@ package requestmanager
 
 import (
 	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/rotmanjanez/check24-gendev-7/config"
+	"github.com/rotmanjanez/check24-gendev-7/pkg/cache"
 	i "github.com/rotmanjanez/check24-gendev-7/pkg/interfaces"
 	m "github.com/rotmanjanez/check24-gendev-7/pkg/models"
+	pc "github.com/rotmanjanez/check24-gendev-7/pkg/pricing"
 	p "github.com/rotmanjanez/check24-gendev-7/pkg/provider"
 )
 
@@ -30,6 +42,16 @@ func (f *fakeAdapter) Name() string {
 	return "fake"
 }
 
+// namedAdapter is a fakeAdapter with a custom name
+type namedAdapter struct {
+	fakeAdapter
+	name string
+}
+
+func (n *namedAdapter) Name() string {
+	return n.name
+}
+
 // newProvider creates a ProviderConfig with the fake adapter
 func newProvider(adapter i.ProviderAdapter) *p.ProviderConfig {
 	// retries=0, timeout minimal, concurrency=1, backoff minimal
@@ -37,9 +59,9 @@ func newProvider(adapter i.ProviderAdapter) *p.ProviderConfig {
 }
 
 // collectChannels drains response and error channels with timeout
-func collectChannels(responses <-chan m.InternetProduct, errs <-chan error) ([]m.InternetProduct, []error) {
+func collectChannels(responses <-chan m.InternetProduct, errs <-chan *i.ProviderError) ([]m.InternetProduct, []*i.ProviderError) {
 	var got []m.InternetProduct
-	var errsCollected []error
+	var errsCollected []*i.ProviderError
 	for responses != nil || errs != nil {
 		select {
 		case r, ok := <-responses:
@@ -82,13 +104,16 @@ func TestSingleProviderValid(t *testing.T) {
 	}
 	adapter := &fakeAdapter{prepareResp: i.ParsedResponse{InternetProducts: []m.InternetProduct{prod}}}
 	coord := NewRequestCoordinator([]*p.ProviderConfig{newProvider(adapter)})
-	res, errs := coord.Run(context.Background(), i.Request{}, 1, 1)
+	res, errs, _ := coord.Run(context.Background(), i.Request{}, 1, 1)
 	out, errsOut := collectChannels(res, errs)
 	if len(errsOut) != 0 {
 		t.Errorf("expected no errors, got %v", errsOut)
 	}
 	if len(out) != 1 || out[0].Id != prod.Id {
-		t.Errorf("expected product %v, got %v", prod, out)
+		t.Fatalf("expected product %v, got %v", prod, out)
+	}
+	if cost := out[0].Cost; cost == nil || cost.TotalCostInCent != 12000 || cost.EffectiveMonthlyCostInCent != 1000 {
+		t.Errorf("expected the cost over the contract to be computed, got %+v", cost)
 	}
 }
 
@@ -98,13 +123,13 @@ func TestRequiredFieldError(t *testing.T) {
 	bad := m.InternetProduct{Provider: "p", Name: "prod", DateOffered: time.Now(), ProductInfo: info(1, m.DSL), Pricing: pricing(1, 1)}
 	adapter := &fakeAdapter{prepareResp: i.ParsedResponse{InternetProducts: []m.InternetProduct{bad}}}
 	coord := NewRequestCoordinator([]*p.ProviderConfig{newProvider(adapter)})
-	res, errs := coord.Run(context.Background(), i.Request{}, 1, 1)
+	res, errs, _ := coord.Run(context.Background(), i.Request{}, 1, 1)
 	out, errsOut := collectChannels(res, errs)
 	if len(out) != 0 {
 		t.Errorf("expected no responses, got %v", out)
 	}
-	if len(errsOut) != 1 {
-		t.Errorf("expected one error, got %v", errsOut)
+	if len(errsOut) != 1 || errsOut[0].Phase != i.PhaseValidation || errsOut[0].Provider != "fake" {
+		t.Errorf("expected one validation error, got %v", errsOut)
 	}
 }
 
@@ -113,7 +138,7 @@ func TestConstraintError(t *testing.T) {
 	bad := m.InternetProduct{Id: "1", Provider: "p", Name: "prod", DateOffered: time.Now(), ProductInfo: info(-1, m.DSL), Pricing: pricing(1, 1)}
 	adapter := &fakeAdapter{prepareResp: i.ParsedResponse{InternetProducts: []m.InternetProduct{bad}}}
 	coord := NewRequestCoordinator([]*p.ProviderConfig{newProvider(adapter)})
-	res, errs := coord.Run(context.Background(), i.Request{}, 1, 1)
+	res, errs, _ := coord.Run(context.Background(), i.Request{}, 1, 1)
 	out, errsOut := collectChannels(res, errs)
 	if len(out) != 0 {
 		t.Errorf("expected no responses, got %v", out)
@@ -123,6 +148,21 @@ func TestConstraintError(t *testing.T) {
 	}
 }
 
+// Test products with contracts too long to compute their cost for are emitted without cost
+func TestPricingError(t *testing.T) {
+	long := m.InternetProduct{Id: "1", Provider: "p", Name: "prod", DateOffered: time.Now(), ProductInfo: info(1, m.DSL), Pricing: pricing(1, pc.MaxDurationInMonths+1)}
+	adapter := &fakeAdapter{prepareResp: i.ParsedResponse{InternetProducts: []m.InternetProduct{long}}}
+	coord := NewRequestCoordinator([]*p.ProviderConfig{newProvider(adapter)})
+	res, errs, _ := coord.Run(context.Background(), i.Request{}, 1, 1)
+	out, errsOut := collectChannels(res, errs)
+	if len(errsOut) != 0 {
+		t.Errorf("expected no errors, got %v", errsOut)
+	}
+	if len(out) != 1 || out[0].Id != long.Id || out[0].Cost != nil {
+		t.Errorf("expected the product without cost, got %+v", out)
+	}
+}
+
 // Test multiple providers emit all products concurrently
 func TestMultipleProviders(t *testing.T) {
 	prod1 := m.InternetProduct{Id: "1", Provider: "p1", Name: "a", DateOffered: time.Now(), ProductInfo: info(1, m.DSL), Pricing: pricing(1, 1)}
@@ -130,7 +170,7 @@ func TestMultipleProviders(t *testing.T) {
 	ad1 := &fakeAdapter{prepareResp: i.ParsedResponse{InternetProducts: []m.InternetProduct{prod1}}}
 	ad2 := &fakeAdapter{prepareResp: i.ParsedResponse{InternetProducts: []m.InternetProduct{prod2}}}
 	coord := NewRequestCoordinator([]*p.ProviderConfig{newProvider(ad1), newProvider(ad2)})
-	res, errs := coord.Run(context.Background(), i.Request{}, 2, 2)
+	res, errs, _ := coord.Run(context.Background(), i.Request{}, 2, 2)
 	out, errsOut := collectChannels(res, errs)
 	if len(errsOut) != 0 {
 		t.Errorf("expected no errors, got %v", errsOut)
@@ -139,3 +179,827 @@ func TestMultipleProviders(t *testing.T) {
 		t.Errorf("expected two products, got %v", out)
 	}
 }
+
+// Test providers with an open circuit breaker are skipped and reported
+func TestOpenCircuitBreakerSkipsProvider(t *testing.T) {
+	prod := m.InternetProduct{Id: "1", Provider: "p", Name: "prod", DateOffered: time.Now(), ProductInfo: info(1, m.DSL), Pricing: pricing(1, 1)}
+	adapter := &fakeAdapter{prepareResp: i.ParsedResponse{InternetProducts: []m.InternetProduct{prod}}}
+	cfg := newProvider(adapter)
+	cfg.Breaker = p.NewCircuitBreaker("fake", config.CircuitBreakerConfig{MinimumRequests: 1}, cache.NewInstanceCache("test-breaker"))
+	cfg.Breaker.Record(context.Background(), false, time.Millisecond)
+
+	coord := NewRequestCoordinator([]*p.ProviderConfig{cfg})
+	res, errs, _ := coord.Run(context.Background(), i.Request{}, 1, 1)
+	out, errsOut := collectChannels(res, errs)
+	if len(out) != 0 {
+		t.Errorf("expected no responses, got %v", out)
+	}
+	if len(errsOut) != 1 || !errors.Is(errsOut[0], p.ErrCircuitOpen) || errsOut[0].Phase != i.PhaseCircuitOpen {
+		t.Errorf("expected one circuit open error, got %v", errsOut)
+	}
+}
+
+// Test the probe of a half-open circuit breaker is freed if its call is abandoned
+func TestCircuitBreakerAbandonedProbe(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	cfg := p.NewProviderConfig(&fakeAdapter{prepareResp: i.ParsedResponse{Requests: []i.PreparedRequest{{Request: req}}}}, 0, time.Second, 1, time.Millisecond)
+	cfg.Breaker = p.NewCircuitBreaker("fake", config.CircuitBreakerConfig{MinimumRequests: 1, OpenDuration: 10 * time.Millisecond}, cache.NewInstanceCache("test-breaker"))
+	cfg.Breaker.Record(context.Background(), false, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	coord := NewRequestCoordinator([]*p.ProviderConfig{cfg})
+
+	// the probe is abandoned while it is sent
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	res, errs, _ := coord.Run(ctx, i.Request{}, 1, 1)
+	collectChannels(res, errs)
+
+	res, errs, _ = coord.Run(context.Background(), i.Request{}, 1, 1)
+	if _, errsOut := collectChannels(res, errs); len(errsOut) != 0 {
+		t.Errorf("expected the next probe to be allowed, got %v", errsOut)
+	}
+	if calls := calls.Load(); calls != 2 {
+		t.Errorf("expected two calls, got %d", calls)
+	}
+	if state := cfg.Breaker.State(context.Background()); state != p.BreakerClosed {
+		t.Errorf("expected closed after successful probe, got %s", state)
+	}
+}
+
+// Test throttled requests are retried after the provider's Retry-After
+func TestRetryAfterThrottling(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	prod := m.InternetProduct{Id: "1", Provider: "p", Name: "prod", DateOffered: time.Now(), ProductInfo: info(1, m.DSL), Pricing: pricing(1, 1)}
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	adapter := &fakeAdapter{
+		prepareResp: i.ParsedResponse{Requests: []i.PreparedRequest{{Request: req}}},
+		parseResp:   i.ParsedResponse{InternetProducts: []m.InternetProduct{prod}},
+	}
+	cfg := p.NewProviderConfig(adapter, 1, time.Second, 1, time.Hour)
+
+	coord := NewRequestCoordinator([]*p.ProviderConfig{cfg})
+	res, errs, _ := coord.Run(context.Background(), i.Request{}, 1, 1)
+	out, errsOut := collectChannels(res, errs)
+	if len(errsOut) != 0 {
+		t.Errorf("expected no errors, got %v", errsOut)
+	}
+	if len(out) != 1 || calls != 2 {
+		t.Errorf("expected one product after one retry, got %v after %d calls", out, calls)
+	}
+}
+
+// Test non-retryable status codes fail without retrying
+func TestNonRetryableStatus(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	adapter := &fakeAdapter{prepareResp: i.ParsedResponse{Requests: []i.PreparedRequest{{Request: req}}}}
+	coord := NewRequestCoordinator([]*p.ProviderConfig{p.NewProviderConfig(adapter, 3, time.Second, 1, time.Millisecond)})
+	res, errs, _ := coord.Run(context.Background(), i.Request{}, 1, 1)
+	_, errsOut := collectChannels(res, errs)
+	if len(errsOut) != 1 || calls != 1 {
+		t.Fatalf("expected one error after a single call, got %v after %d calls", errsOut, calls)
+	}
+	if err := errsOut[0]; err.Phase != i.PhaseStatus || err.StatusCode != http.StatusBadRequest || err.Retryable {
+		t.Errorf("expected non-retryable status error, got %+v", err)
+	}
+}
+
+// Test cancelling the query aborts the backoff wait
+func TestRetryWaitCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	adapter := &fakeAdapter{prepareResp: i.ParsedResponse{Requests: []i.PreparedRequest{{Request: req}}}}
+	cfg := p.NewProviderConfig(adapter, 3, time.Second, 1, time.Hour)
+	cfg.RetryPolicy.MaxDelay = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	coord := NewRequestCoordinator([]*p.ProviderConfig{cfg})
+	res, errs, _ := coord.Run(ctx, i.Request{}, 1, 1)
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected retry wait to be cancelled")
+	}
+	for range res {
+	}
+}
+
+// Test only the selected providers are run
+func TestSelectProviders(t *testing.T) {
+	prod1 := m.InternetProduct{Id: "1", Provider: "p1", Name: "a", DateOffered: time.Now(), ProductInfo: info(1, m.DSL), Pricing: pricing(1, 1)}
+	prod2 := m.InternetProduct{Id: "2", Provider: "p2", Name: "b", DateOffered: time.Now(), ProductInfo: info(2, m.FIBER), Pricing: pricing(2, 2)}
+	ad1 := &namedAdapter{fakeAdapter{prepareResp: i.ParsedResponse{InternetProducts: []m.InternetProduct{prod1}}}, "first"}
+	ad2 := &namedAdapter{fakeAdapter{prepareResp: i.ParsedResponse{InternetProducts: []m.InternetProduct{prod2}}}, "second"}
+	coord := NewRequestCoordinator([]*p.ProviderConfig{newProvider(ad1), newProvider(ad2)})
+
+	selected, err := coord.Select([]string{" Second ", "second"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res, errs, _ := selected.Run(context.Background(), i.Request{}, 2, 2)
+	out, _ := collectChannels(res, errs)
+	if len(out) != 1 || out[0].Id != prod2.Id {
+		t.Errorf("expected only product of selected provider, got %v", out)
+	}
+
+	var unknown *UnknownProviderError
+	if _, err := coord.Select([]string{"first", "third"}); !errors.As(err, &unknown) || unknown.Name != "third" {
+		t.Errorf("expected unknown provider error for third, got %v", err)
+	}
+
+	if all, err := coord.Select(nil); err != nil || all != coord {
+		t.Errorf("expected empty selection to return all providers, got %v", err)
+	}
+}
+
+// Test the progress reports a terminal state per provider once the run completes
+func TestProgressStates(t *testing.T) {
+	prod := m.InternetProduct{Id: "1", Provider: "p", Name: "prod", DateOffered: time.Now(), ProductInfo: info(1, m.DSL), Pricing: pricing(1, 1)}
+	completed := &namedAdapter{fakeAdapter{prepareResp: i.ParsedResponse{InternetProducts: []m.InternetProduct{prod}}}, "completed"}
+	unsupported := &namedAdapter{fakeAdapter{}, "unsupported"}
+	failed := &namedAdapter{fakeAdapter{prepareErr: errors.New("prepare failed")}, "failed"}
+	coord := NewRequestCoordinator([]*p.ProviderConfig{newProvider(completed), newProvider(unsupported), newProvider(failed)})
+
+	res, errs, progress := coord.Run(context.Background(), i.Request{}, 1, 1)
+	collectChannels(res, errs)
+	for range progress.Changed() {
+	}
+
+	status := progress.Snapshot()
+	if !status.Complete {
+		t.Errorf("expected status to be complete")
+	}
+	want := []struct {
+		state    m.ProviderState
+		products int32
+		category string
+	}{
+		{m.COMPLETED, 1, ""},
+		{m.SKIPPED_UNSUPPORTED, 0, ""},
+		{m.FAILED, 0, string(i.PhasePrepare)},
+	}
+	for idx, w := range want {
+		got := status.Providers[idx]
+		if got.State != w.state || got.ProductCount != w.products || got.LastErrorCategory != w.category {
+			t.Errorf("provider %s: expected %v, got %+v", got.Provider, w, got)
+		}
+	}
+}
+
+// Test errors carry the phase and attempt in which they occurred
+func TestProviderErrorPhases(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	parseErr := errors.New("malformed body")
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	parsing := &namedAdapter{fakeAdapter{
+		prepareResp: i.ParsedResponse{Requests: []i.PreparedRequest{{Request: req}}},
+		parseErr:    parseErr,
+	}, "parsing"}
+	unreachable, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:1", nil)
+	transport := &namedAdapter{fakeAdapter{
+		prepareResp: i.ParsedResponse{Requests: []i.PreparedRequest{{Request: unreachable}}},
+	}, "transport"}
+	coord := NewRequestCoordinator([]*p.ProviderConfig{p.NewProviderConfig(parsing, 0, time.Second, 1, time.Millisecond), p.NewProviderConfig(transport, 1, time.Second, 1, time.Millisecond)})
+
+	res, errs, _ := coord.Run(context.Background(), i.Request{}, 2, 2)
+	_, errsOut := collectChannels(res, errs)
+	if len(errsOut) != 2 {
+		t.Fatalf("expected two errors, got %v", errsOut)
+	}
+
+	byProvider := make(map[string]*i.ProviderError)
+	for _, err := range errsOut {
+		byProvider[err.Provider] = err
+	}
+	if err := byProvider["parsing"]; err == nil || err.Phase != i.PhaseParse || !errors.Is(err, parseErr) {
+		t.Errorf("expected parse error wrapping the cause, got %v", err)
+	}
+	if err := byProvider["transport"]; err == nil || err.Phase != i.PhaseTransport || err.Attempt != 1 || !err.Retryable {
+		t.Errorf("expected retryable transport error on the last attempt, got %v", err)
+	}
+}
+
+// countingAdapter is a fakeAdapter that counts calls to PrepareRequest
+type countingAdapter struct {
+	fakeAdapter
+	prepareCalls atomic.Int32
+}
+
+func (c *countingAdapter) PrepareRequest(ctx context.Context, req i.Request) (i.ParsedResponse, error) {
+	c.prepareCalls.Add(1)
+	return c.fakeAdapter.PrepareRequest(ctx, req)
+}
+
+// Test cached results are served without querying the provider and stale ones are refreshed
+func TestResultCache(t *testing.T) {
+	prod := m.InternetProduct{Id: "1", Provider: "p", Name: "prod", ProductInfo: info(1, m.DSL), Pricing: pricing(1, 1)}
+	adapter := &countingAdapter{fakeAdapter: fakeAdapter{prepareResp: i.ParsedResponse{InternetProducts: []m.InternetProduct{prod}}}}
+	cfg := newProvider(adapter)
+	cfg.ResultCache = p.NewResultCache("fake", config.ResultCacheConfig{TTL: 50 * time.Millisecond, StaleTTL: time.Minute}, cache.NewInstanceCache("test-results"))
+	coord := NewRequestCoordinator([]*p.ProviderConfig{cfg})
+	req := i.Request{Address: m.Address{Street: "Teststrasse", City: "Berlin", PostalCode: "10115", CountryCode: m.DE}}
+
+	res, errs, _ := coord.Run(context.Background(), req, 1, 1)
+	first, _ := collectChannels(res, errs)
+	if len(first) != 1 {
+		t.Fatalf("expected one product, got %v", first)
+	}
+
+	res, errs, progress := coord.Run(context.Background(), req, 1, 1)
+	cached, _ := collectChannels(res, errs)
+	if len(cached) != 1 || !cached[0].DateOffered.Equal(first[0].DateOffered) {
+		t.Errorf("expected cached product with original DateOffered, got %v", cached)
+	}
+	if calls := adapter.prepareCalls.Load(); calls != 1 {
+		t.Errorf("expected fresh result to be served from cache, got %d calls", calls)
+	}
+	if status := progress.Snapshot().Providers[0]; status.State != m.COMPLETED || status.ProductCount != 1 {
+		t.Errorf("expected completed status for cached products, got %+v", status)
+	}
+
+	res, errs, _ = coord.Fresh().Run(context.Background(), req, 1, 1)
+	if fresh, _ := collectChannels(res, errs); len(fresh) != 1 || fresh[0].DateOffered.Equal(first[0].DateOffered) {
+		t.Errorf("expected product of a new query, got %v", fresh)
+	}
+	if calls := adapter.prepareCalls.Load(); calls != 2 {
+		t.Errorf("expected fresh coordinator to query the provider, got %d calls", calls)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	res, errs, _ = coord.Run(context.Background(), req, 1, 1)
+	stale, _ := collectChannels(res, errs)
+	if len(stale) != 1 {
+		t.Errorf("expected stale product to be served, got %v", stale)
+	}
+	time.Sleep(20 * time.Millisecond) // Give the refresh time to run
+	if calls := adapter.prepareCalls.Load(); calls != 3 {
+		t.Errorf("expected stale result to be refreshed once, got %d calls", calls)
+	}
+}
+
+// Test providers over their daily quota are not called and reported as quota exhausted
+func TestQuotaExhausted(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	adapter := &namedAdapter{fakeAdapter{
+		prepareResp: i.ParsedResponse{Requests: []i.PreparedRequest{{Request: req}, {Request: req}}},
+	}, "limited"}
+	cfg := p.NewProviderConfig(adapter, 0, time.Second, 1, time.Millisecond)
+	cfg.RateLimiter = p.NewRateLimiter("limited", config.RateLimitConfig{DailyQuota: 1}, cache.NewInstanceCache("test-quota"), cache.NewInstanceCache("test-quota-local"))
+	coord := NewRequestCoordinator([]*p.ProviderConfig{cfg})
+
+	res, errs, progress := coord.Run(context.Background(), i.Request{}, 1, 1)
+	_, errsOut := collectChannels(res, errs)
+	for range progress.Changed() {
+	}
+
+	if calls.Load() != 1 {
+		t.Errorf("expected one call within the quota, got %d", calls.Load())
+	}
+	if len(errsOut) != 1 || !errors.Is(errsOut[0], p.ErrQuotaExhausted) || errsOut[0].Retryable {
+		t.Errorf("expected non-retryable quota error, got %v", errsOut)
+	}
+	if state := progress.Snapshot().Providers[0].State; state != m.QUOTA_EXHAUSTED {
+		t.Errorf("expected state %s, got %s", m.QUOTA_EXHAUSTED, state)
+	}
+}
+
+// refreshingAdapter is a fakeAdapter that rebuilds the body of a request for every attempt
+type refreshingAdapter struct {
+	fakeAdapter
+}
+
+func (r *refreshingAdapter) RefreshRequest(ctx context.Context, req i.PreparedRequest, attempt int) (*http.Request, error) {
+	refreshed, err := http.NewRequestWithContext(ctx, req.Request.Method, req.Request.URL.String(), strings.NewReader("payload-"+strconv.Itoa(attempt)))
+	if err != nil {
+		return nil, err
+	}
+	refreshed.Header.Set("X-Attempt", strconv.Itoa(attempt))
+	return refreshed, nil
+}
+
+// Test retries send the full request body and adapters can rebuild requests before every attempt
+func TestRequestReplay(t *testing.T) {
+	var mutex sync.Mutex
+	var bodies, attempts, signed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		defer mutex.Unlock()
+		bodies = append(bodies, string(body))
+		attempts = append(attempts, r.Header.Get("X-Attempt"))
+		signed = append(signed, r.Header.Get("X-Signed"))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		name     string
+		adapter  func(req *http.Request) i.ProviderAdapter
+		signer   bool
+		bodies   []string
+		attempts []string
+		signed   []string
+	}{
+		{"get body", func(req *http.Request) i.ProviderAdapter {
+			return &fakeAdapter{prepareResp: i.ParsedResponse{Requests: []i.PreparedRequest{{Request: req}}}}
+		}, false, []string{"payload", "payload"}, []string{"", ""}, []string{"", ""}},
+		{"refresher", func(req *http.Request) i.ProviderAdapter {
+			return &refreshingAdapter{fakeAdapter{prepareResp: i.ParsedResponse{Requests: []i.PreparedRequest{{Request: req}}}}}
+		}, false, []string{"payload-0", "payload-1"}, []string{"0", "1"}, []string{"", ""}},
+		{"refresher with signer", func(req *http.Request) i.ProviderAdapter {
+			return &refreshingAdapter{fakeAdapter{prepareResp: i.ParsedResponse{Requests: []i.PreparedRequest{{Request: req}}}}}
+		}, true, []string{"payload-0", "payload-1"}, []string{"0", "1"}, []string{"1", "2"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			bodies, attempts, signed = nil, nil, nil
+			req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("payload"))
+			cfg := p.NewProviderConfig(tc.adapter(req), 1, time.Second, 1, time.Millisecond)
+			if tc.signer {
+				cfg.Signer = &countingSigner{}
+			}
+			coord := NewRequestCoordinator([]*p.ProviderConfig{cfg})
+
+			res, errs, _ := coord.Run(context.Background(), i.Request{}, 1, 1)
+			if _, errsOut := collectChannels(res, errs); len(errsOut) != 0 {
+				t.Fatalf("unexpected errors: %v", errsOut)
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if !slices.Equal(bodies, tc.bodies) {
+				t.Errorf("expected bodies %q, got %q", tc.bodies, bodies)
+			}
+			if !slices.Equal(attempts, tc.attempts) {
+				t.Errorf("expected attempts %q, got %q", tc.attempts, attempts)
+			}
+			if !slices.Equal(signed, tc.signed) {
+				t.Errorf("expected the refreshed requests to be signed %q, got %q", tc.signed, signed)
+			}
+		})
+	}
+}
+
+// countingSigner numbers the requests it signs
+type countingSigner struct {
+	signed atomic.Int32
+}
+
+func (s *countingSigner) Sign(req *http.Request) error {
+	req.Header.Set("X-Signed", strconv.Itoa(int(s.signed.Add(1))))
+	return nil
+}
+
+// Test every attempt is signed again without changing the prepared request
+func TestSigner(t *testing.T) {
+	var mutex sync.Mutex
+	var signed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		signed = append(signed, r.Header.Get("X-Signed"))
+		if len(signed) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	cfg := p.NewProviderConfig(&fakeAdapter{prepareResp: i.ParsedResponse{Requests: []i.PreparedRequest{{Request: req}}}}, 1, time.Second, 1, time.Millisecond)
+	cfg.Signer = &countingSigner{}
+	coord := NewRequestCoordinator([]*p.ProviderConfig{cfg})
+
+	res, errs, _ := coord.Run(context.Background(), i.Request{}, 1, 1)
+	if _, errsOut := collectChannels(res, errs); len(errsOut) != 0 {
+		t.Fatalf("unexpected errors: %v", errsOut)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if !slices.Equal(signed, []string{"1", "2"}) {
+		t.Errorf("expected every attempt to be signed, got %q", signed)
+	}
+	if req.Header.Get("X-Signed") != "" {
+		t.Errorf("expected prepared request to stay unsigned")
+	}
+}
+
+// Test providers paging forever or returning too many products are stopped by their fan-out budget
+func TestFanOutBudget(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	next := i.ParsedResponse{Requests: []i.PreparedRequest{{Request: req}}}
+	prod := m.InternetProduct{Id: "1", Provider: "p", Name: "prod", ProductInfo: info(1, m.DSL), Pricing: pricing(1, 1)}
+
+	tests := []struct {
+		name     string
+		adapter  i.ProviderAdapter
+		budget   config.FanOutConfig
+		calls    int32
+		products int
+		limit    p.BudgetLimit
+	}{
+		{"depth", &fakeAdapter{prepareResp: next, parseResp: next}, config.FanOutConfig{MaxDepth: 3}, 4, 0, p.LimitDepth},
+		{"requests", &fakeAdapter{prepareResp: next, parseResp: next}, config.FanOutConfig{MaxRequests: 2}, 2, 0, p.LimitRequests},
+		{"products", &fakeAdapter{prepareResp: i.ParsedResponse{InternetProducts: []m.InternetProduct{prod, prod, prod}}}, config.FanOutConfig{MaxProducts: 2}, 0, 2, p.LimitProducts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls.Store(0)
+			cfg := p.NewProviderConfig(tt.adapter, 0, time.Second, 1, time.Millisecond)
+			cfg.FanOut = tt.budget
+			coord := NewRequestCoordinator([]*p.ProviderConfig{cfg})
+
+			res, errs, progress := coord.Run(context.Background(), i.Request{}, 10, 10)
+			products, errsOut := collectChannels(res, errs)
+			for range progress.Changed() {
+			}
+
+			if calls.Load() != tt.calls || len(products) != tt.products {
+				t.Errorf("expected %d calls and %d products, got %d and %d", tt.calls, tt.products, calls.Load(), len(products))
+			}
+			var budgetErr *p.BudgetExceededError
+			if len(errsOut) != 1 || errsOut[0].Phase != i.PhaseBudget || !errors.As(errsOut[0], &budgetErr) || budgetErr.Limit != tt.limit {
+				t.Errorf("expected one %s budget error, got %v", tt.limit, errsOut)
+			}
+			if category := progress.Snapshot().Providers[0].LastErrorCategory; category != string(i.PhaseBudget) {
+				t.Errorf("expected error category %s, got %s", i.PhaseBudget, category)
+			}
+		})
+	}
+}
+
+// Test providers missing their soft deadline do not hold back partial completion and their products are flagged late
+func TestSoftDeadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	onTime := m.InternetProduct{Id: "1", Provider: "p", Name: "on time", ProductInfo: info(1, m.DSL), Pricing: pricing(1, 1)}
+	late := m.InternetProduct{Id: "2", Provider: "p", Name: "late", ProductInfo: info(1, m.DSL), Pricing: pricing(1, 1)}
+	fast := &namedAdapter{fakeAdapter{prepareResp: i.ParsedResponse{InternetProducts: []m.InternetProduct{onTime}}}, "fast"}
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	slow := &namedAdapter{fakeAdapter{
+		prepareResp: i.ParsedResponse{Requests: []i.PreparedRequest{{Request: req}}},
+		parseResp:   i.ParsedResponse{InternetProducts: []m.InternetProduct{late}},
+	}, "slow"}
+	slowCfg := p.NewProviderConfig(slow, 0, time.Second, 1, time.Millisecond)
+	slowCfg.SoftDeadline = 20 * time.Millisecond
+	coord := NewRequestCoordinator([]*p.ProviderConfig{newProvider(fast), slowCfg})
+
+	res, _, progress := coord.Run(context.Background(), i.Request{}, 2, 2)
+
+	select {
+	case <-progress.Partial():
+	case <-time.After(80 * time.Millisecond):
+		t.Fatalf("expected query to be partially complete after the soft deadline")
+	}
+	status := progress.Snapshot()
+	if !status.Partial || status.Complete || status.Providers[0].PastDeadline || !status.Providers[1].PastDeadline {
+		t.Errorf("expected only the slow provider past its deadline, got %+v", status)
+	}
+
+	var products []m.InternetProduct
+	for product := range res {
+		products = append(products, product)
+	}
+	if len(products) != 2 {
+		t.Fatalf("expected two products, got %v", products)
+	}
+	for _, product := range products {
+		if product.Late != (product.Name == "late") {
+			t.Errorf("unexpected late flag on product %s: %v", product.Name, product.Late)
+		}
+	}
+}
+
+// parseRecorder is a fakeAdapter that records the requests whose responses it parsed
+type parseRecorder struct {
+	fakeAdapter
+	mutex  sync.Mutex
+	parsed []*http.Request
+}
+
+func (r *parseRecorder) ParseResponse(ctx context.Context, resp i.Response) (i.ParsedResponse, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.parsed = append(r.parsed, resp.Request.Request)
+	return r.fakeAdapter.ParseResponse(ctx, resp)
+}
+
+// Test slow requests are hedged with a replayed copy within the limits of the provider and the faster response wins
+func TestHedgedRequest(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		maxConcurrent int
+		rateLimit     config.RateLimitConfig
+		sent          int32
+	}{
+		{"hedged", 2, config.RateLimitConfig{}, 2},
+		{"concurrency limit reached", 1, config.RateLimitConfig{}, 1},
+		{"rate limited", 2, config.RateLimitConfig{RequestsPerSecond: 0.1, Burst: 1}, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			var mutex sync.Mutex
+			var bodies []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mutex.Lock()
+				bodies = append(bodies, string(body))
+				mutex.Unlock()
+				if calls.Add(1) == 1 {
+					// the first request hangs until it is cancelled
+					select {
+					case <-r.Context().Done():
+					case <-time.After(300 * time.Millisecond):
+					}
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			prod := m.InternetProduct{Id: "1", Provider: "p", Name: "prod", ProductInfo: info(1, m.DSL), Pricing: pricing(1, 1)}
+			req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("payload"))
+			adapter := &parseRecorder{fakeAdapter: fakeAdapter{
+				prepareResp: i.ParsedResponse{Requests: []i.PreparedRequest{{Request: req}}},
+				parseResp:   i.ParsedResponse{InternetProducts: []m.InternetProduct{prod}},
+			}}
+			cfg := p.NewProviderConfig(adapter, 0, 2*time.Second, tc.maxConcurrent, time.Millisecond)
+			cfg.Hedger = p.NewHedger("fake", config.HedgeConfig{Enabled: true, Budget: 1, MinSamples: 1})
+			cfg.Hedger.Observe(10 * time.Millisecond)
+			cfg.RateLimiter = p.NewRateLimiter("fake", tc.rateLimit, nil, cache.NewInstanceCache("test-rate-local"))
+			coord := NewRequestCoordinator([]*p.ProviderConfig{cfg})
+
+			start := time.Now()
+			res, errs, progress := coord.Run(context.Background(), i.Request{}, 1, 1)
+			go func() {
+				for err := range errs {
+					t.Errorf("unexpected error: %v", err)
+				}
+			}()
+			// requests that are not hedged take until the first request gives up
+			var products []m.InternetProduct
+			for product := range res {
+				products = append(products, product)
+			}
+			if len(products) != 1 {
+				t.Fatalf("expected one product, got %v", products)
+			}
+			for range progress.Changed() {
+			}
+			if sent := progress.Snapshot().Providers[0].RequestsSent; sent != tc.sent {
+				t.Errorf("expected %d requests sent, got %d", tc.sent, sent)
+			}
+			if tc.sent == 1 {
+				return
+			}
+
+			if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+				t.Errorf("expected hedged request to finish early, took %v", elapsed)
+			}
+			if len(adapter.parsed) != 1 || adapter.parsed[0] == req {
+				t.Errorf("expected the response to be parsed with the hedged request, got %v", adapter.parsed)
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			if !slices.Equal(bodies, []string{"payload", "payload"}) {
+				t.Errorf("expected the hedged request to replay the body, got %q", bodies)
+			}
+		})
+	}
+}
+
+// Test cancelling the query aborts requests in flight and marks the unfinished providers cancelled
+func TestCancelledQuery(t *testing.T) {
+	aborted := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			close(aborted)
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	done := m.InternetProduct{Id: "1", Provider: "p", Name: "done", ProductInfo: info(1, m.DSL), Pricing: pricing(1, 1)}
+	fast := &namedAdapter{fakeAdapter{prepareResp: i.ParsedResponse{InternetProducts: []m.InternetProduct{done}}}, "fast"}
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	slow := &namedAdapter{fakeAdapter{prepareResp: i.ParsedResponse{Requests: []i.PreparedRequest{{Request: req}}}}, "slow"}
+	breaker := p.NewCircuitBreaker("slow", config.CircuitBreakerConfig{MinimumRequests: 1}, cache.NewInstanceCache("test-breaker"))
+	slowCfg := p.NewProviderConfig(slow, 0, time.Second, 1, time.Millisecond)
+	slowCfg.Breaker = breaker
+	coord := NewRequestCoordinator([]*p.ProviderConfig{newProvider(fast), slowCfg})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	res, errs, progress := coord.Run(ctx, i.Request{}, 2, 2)
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	var products []m.InternetProduct
+	for product := range res {
+		products = append(products, product)
+	}
+	for range errs {
+	}
+
+	select {
+	case <-aborted:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("expected the request in flight to be aborted")
+	}
+	if len(products) != 1 {
+		t.Errorf("expected only the product of the finished provider, got %v", products)
+	}
+	status := progress.Snapshot()
+	if !status.Cancelled || status.Providers[0].State != m.COMPLETED || status.Providers[1].State != m.CANCELLED {
+		t.Errorf("expected the unfinished provider to be cancelled, got %+v", status)
+	}
+	if err := breaker.Allow(context.Background()); err != nil {
+		t.Errorf("expected cancelled requests not to count as provider failures, got %v", err)
+	}
+}
+
+// Test a query is resumed from a checkpoint with only the requests that were not answered
+func TestResumeFromCheckpoint(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body, _ := io.ReadAll(r.Body); string(body) != "page=2" {
+			t.Errorf("expected the original body, got %q", body)
+		}
+		// the first instance is stopped while its request is in flight
+		if calls.Add(1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	done := m.InternetProduct{Id: "1", Provider: "p", Name: "done", ProductInfo: info(1, m.DSL), Pricing: pricing(1, 1)}
+	resumed := m.InternetProduct{Id: "2", Provider: "p", Name: "resumed", ProductInfo: info(1, m.DSL), Pricing: pricing(1, 1)}
+	newCoordinator := func() *RequestCoordinator {
+		fast := &namedAdapter{fakeAdapter{prepareResp: i.ParsedResponse{InternetProducts: []m.InternetProduct{done}}}, "fast"}
+		req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("page=2"))
+		slow := &namedAdapter{fakeAdapter{
+			prepareResp: i.ParsedResponse{Requests: []i.PreparedRequest{{Request: req, Metadata: "page-2"}}},
+			parseResp:   i.ParsedResponse{InternetProducts: []m.InternetProduct{resumed}},
+		}, "slow"}
+		return NewRequestCoordinator([]*p.ProviderConfig{newProvider(fast), p.NewProviderConfig(slow, 0, time.Second, 1, time.Millisecond)})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	res, errs, progress := newCoordinator().Run(ctx, i.Request{}, 2, 2)
+	time.AfterFunc(20*time.Millisecond, cancel)
+	products, _ := collectChannels(res, errs)
+	if len(products) != 1 || products[0].Name != "done" {
+		t.Fatalf("expected only the product of the finished provider, got %v", products)
+	}
+
+	state, err := progress.Checkpoint()
+	if err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if !state[0].Done || state[1].Done || !state[1].Prepared || len(state[1].Pending) != 1 {
+		t.Fatalf("expected the abandoned request to be pending, got %+v", state)
+	}
+
+	res, errs, progress = newCoordinator().Resume(context.Background(), i.Request{}, state, 2, 2)
+	products, _ = collectChannels(res, errs)
+	if len(products) != 1 || products[0].Name != "resumed" {
+		t.Errorf("expected only the product of the resumed request, got %v", products)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected the pending request to be sent once more, got %d calls", calls.Load())
+	}
+	status := progress.Snapshot()
+	if status.Cancelled || status.Providers[0].ProductCount != 1 || status.Providers[1].State != m.COMPLETED {
+		t.Errorf("expected resumed query to complete with the restored status, got %+v", status)
+	}
+	if state, _ := progress.Checkpoint(); len(state[1].Pending) != 0 || !state[1].Done {
+		t.Errorf("expected no pending requests after the resumed query finished, got %+v", state)
+	}
+}
+
+// Test requests of distributed queries are sent by the workers of another instance,
+// while products and budgets are handled by the instance running the query
+func TestDistributedRun(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	prod := m.InternetProduct{Id: "1", Provider: "p", Name: "prod", ProductInfo: info(1, m.DSL), Pricing: pricing(1, 1)}
+	newCoordinator := func() *RequestCoordinator {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		next := []i.PreparedRequest{{Request: req, Metadata: "next"}}
+		adapter := &fakeAdapter{
+			prepareResp: i.ParsedResponse{Requests: next},
+			parseResp:   i.ParsedResponse{InternetProducts: []m.InternetProduct{prod}, Requests: next},
+		}
+		cfg := p.NewProviderConfig(adapter, 0, time.Second, 1, time.Millisecond)
+		cfg.FanOut = config.FanOutConfig{MaxDepth: 1}
+		return NewRequestCoordinator([]*p.ProviderConfig{cfg})
+	}
+	queue := NewWorkQueue(cache.NewInstanceCache("test-jobs"), config.WorkQueueConfig{Workers: 2, PollInterval: time.Millisecond})
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go newCoordinator().WithWorkQueue(queue).Work(ctx)
+
+	coord, _ := newCoordinator().WithWorkQueue(queue).Select([]string{"fake"})
+	res, errs, progress := coord.Run(context.Background(), i.Request{}, 10, 10)
+	var products []m.InternetProduct
+	var errsOut []*i.ProviderError
+	for res != nil || errs != nil {
+		select {
+		case product, ok := <-res:
+			if !ok {
+				res = nil
+				continue
+			}
+			products = append(products, product)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			errsOut = append(errsOut, err)
+		case <-time.After(time.Second):
+			t.Fatalf("distributed query did not finish")
+		}
+	}
+
+	if calls.Load() != 2 || len(products) != 2 {
+		t.Errorf("expected 2 calls and 2 products, got %d and %d", calls.Load(), len(products))
+	}
+	if len(errsOut) != 1 || errsOut[0].Phase != i.PhaseBudget || errsOut[0].Provider != "fake" {
+		t.Errorf("expected the follow-up beyond the depth limit to be stopped, got %v", errsOut)
+	}
+	status := progress.Snapshot().Providers[0]
+	if status.RequestsSent != 2 || status.ProductCount != 2 || status.State != m.COMPLETED {
+		t.Errorf("expected the status reported by the workers, got %+v", status)
+	}
+}
@@ -0,0 +1,434 @@
+package requestmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/rotmanjanez/check24-gendev-7/config"
+	"github.com/rotmanjanez/check24-gendev-7/pkg/checkpoint"
+	i "github.com/rotmanjanez/check24-gendev-7/pkg/interfaces"
+	m "github.com/rotmanjanez/check24-gendev-7/pkg/models"
+	p "github.com/rotmanjanez/check24-gendev-7/pkg/provider"
+)
+
+// jobsQueue is the queue shared by all instances that provider requests are sent through
+const jobsQueue = "provider-jobs"
+
+// resultsQueuePrefix is prefixed to the id of a run for the queue its results are returned through
+const resultsQueuePrefix = "provider-results:"
+
+// WorkQueue distributes provider requests across the workers of all instances.
+// The instance running a query prepares the providers, emits their products and enforces their budgets.
+// Every prepared request is pushed as a job and may be sent and parsed by a worker on any instance,
+// which returns the parsed products and follow-up requests to the running query.
+// Jobs of crashed workers are delivered again once their visibility timeout passed.
+type WorkQueue struct {
+	jobs          i.JobQueue
+	workers       int
+	visibility    time.Duration
+	maxDeliveries int
+	pollInterval  time.Duration
+}
+
+// NewWorkQueue creates a work queue on jobs, filling unset values of cfg with defaults
+func NewWorkQueue(jobs i.JobQueue, cfg config.WorkQueueConfig) *WorkQueue {
+	q := &WorkQueue{
+		jobs:          jobs,
+		workers:       cfg.Workers,
+		visibility:    cfg.VisibilityTimeout,
+		maxDeliveries: cfg.MaxDeliveries,
+		pollInterval:  cfg.PollInterval,
+	}
+	if q.workers <= 0 {
+		q.workers = 8
+	}
+	if q.visibility <= 0 {
+		q.visibility = 30 * time.Second
+	}
+	if q.maxDeliveries <= 0 {
+		q.maxDeliveries = 3
+	}
+	if q.pollInterval <= 0 {
+		q.pollInterval = 50 * time.Millisecond
+	}
+	return q
+}
+
+// job is a prepared request as sent to the workers
+type job struct {
+	// Results is the queue the result is pushed to
+	Results  string             `json:"results"`
+	Provider string             `json:"provider"`
+	Request  checkpoint.Request `json:"request"`
+	Address  m.Address          `json:"address"`
+	// Deadline of the query, jobs are dropped afterwards
+	Deadline time.Time `json:"deadline,omitzero"`
+}
+
+// jobResult is the outcome of a job as returned to the running query
+type jobResult struct {
+	Products []m.InternetProduct `json:"products,omitempty"`
+	// Requests are the follow-up requests returned by ParseResponse
+	Requests []checkpoint.Request `json:"requests,omitempty"`
+	Errors   []jobError           `json:"errors,omitempty"`
+
+	RequestsSent   int32 `json:"requestsSent"`
+	Retries        int32 `json:"retries"`
+	QuotaExhausted bool  `json:"quotaExhausted,omitempty"`
+}
+
+// jobError is the serializable form of an i.ProviderError, its cause is only kept as message
+type jobError struct {
+	Phase      i.ErrorPhase `json:"phase"`
+	Attempt    int          `json:"attempt"`
+	StatusCode int          `json:"statusCode,omitempty"`
+	Retryable  bool         `json:"retryable,omitempty"`
+	Message    string       `json:"message"`
+}
+
+func newJobError(err *i.ProviderError) jobError {
+	return jobError{Phase: err.Phase, Attempt: err.Attempt, StatusCode: err.StatusCode, Retryable: err.Retryable, Message: err.Err.Error()}
+}
+
+func (e jobError) providerError() *i.ProviderError {
+	return &i.ProviderError{Phase: e.Phase, Attempt: e.Attempt, StatusCode: e.StatusCode, Retryable: e.Retryable, Err: errors.New(e.Message)}
+}
+
+// distributor pushes the requests of a single run as jobs and handles their results
+type distributor struct {
+	queue   *WorkQueue
+	results string
+
+	mutex  sync.Mutex
+	jobs   map[string]*distributedJob // jobs waiting for their result by id
+	closed bool                       // set once the run was abandoned, no further jobs are pushed
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// distributedJob is a job of a run waiting for its result
+type distributedJob struct {
+	rc      *requestContext
+	depth   int
+	pending uint64 // id of the request in the progress
+}
+
+func newDistributor(queue *WorkQueue) *distributor {
+	return &distributor{
+		queue:   queue,
+		results: resultsQueuePrefix + uuid.New().String(),
+		jobs:    make(map[string]*distributedJob),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// enqueue pushes a request of the provider as job. The request stays inflight until its result was handled.
+func (d *distributor) enqueue(ctx context.Context, rc *requestContext, prepared i.PreparedRequest, depth int, orig i.Request, errors chan<- *i.ProviderError) {
+	request, err := checkpoint.Encode(prepared, depth)
+	if err != nil {
+		rc.fail(errors, &i.ProviderError{Phase: i.PhasePrepare, Err: fmt.Errorf("error encoding job: %w", err)})
+		return
+	}
+	deadline, _ := ctx.Deadline()
+	payload, err := json.Marshal(job{
+		Results:  d.results,
+		Provider: rc.config.Adapter.Name(),
+		Request:  request,
+		Address:  orig.Address,
+		Deadline: deadline,
+	})
+	if err != nil {
+		rc.fail(errors, &i.ProviderError{Phase: i.PhasePrepare, Err: fmt.Errorf("error encoding job: %w", err)})
+		return
+	}
+
+	id := uuid.New().String()
+	d.mutex.Lock()
+	if d.closed {
+		d.mutex.Unlock()
+		rc.fail(errors, &i.ProviderError{Phase: i.PhaseTransport, Err: fmt.Errorf("request abandoned: %w", ctx.Err())})
+		return
+	}
+	d.jobs[id] = &distributedJob{rc: rc, depth: depth, pending: rc.progress.addPending(rc.index, prepared, depth)}
+	rc.wg.Add(1)
+	d.mutex.Unlock()
+
+	if err := d.queue.jobs.Push(ctx, jobsQueue, id, payload, time.Time{}); err != nil {
+		if dj := d.take(id); dj != nil {
+			if ctx.Err() == nil {
+				rc.progress.removePending(rc.index, dj.pending)
+			}
+			rc.fail(errors, &i.ProviderError{Phase: i.PhaseTransport, Err: fmt.Errorf("error pushing job: %w", err)})
+			rc.wg.Done()
+		}
+	}
+}
+
+// take removes the job with id, it returns nil if the job is unknown or was already handled
+func (d *distributor) take(id string) *distributedJob {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	dj := d.jobs[id]
+	delete(d.jobs, id)
+	return dj
+}
+
+// consume handles the results of the run until it finished or ctx is done.
+// Jobs still waiting once ctx is done are abandoned and stay pending in the progress, like requests sent locally.
+func (d *distributor) consume(ctx context.Context, c *RequestCoordinator, orig i.Request, responses chan<- m.InternetProduct, errors chan<- *i.ProviderError) {
+	defer close(d.done)
+	ticker := time.NewTicker(d.queue.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			popped, ok, err := d.queue.jobs.Pop(ctx, d.results, d.queue.visibility)
+			if err != nil && ctx.Err() == nil {
+				slog.Warn("Error receiving job results", "queue", d.results, "error", err)
+			}
+			if !ok {
+				break
+			}
+			d.handle(ctx, c, popped, orig, responses, errors)
+			if err := d.queue.jobs.Ack(ctx, d.results, popped.ID); err != nil {
+				slog.Warn("Error acknowledging job result", "queue", d.results, "id", popped.ID, "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			d.abandon(ctx, errors)
+			return
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handle emits the products of a job result and schedules its follow-up requests
+func (d *distributor) handle(ctx context.Context, c *RequestCoordinator, popped i.Job, orig i.Request, responses chan<- m.InternetProduct, errors chan<- *i.ProviderError) {
+	dj := d.take(popped.ID)
+	if dj == nil {
+		// results of redelivered jobs may arrive twice
+		slog.Debug("Dropping result of unknown job", "id", popped.ID)
+		return
+	}
+	rc := dj.rc
+	defer rc.wg.Done()
+
+	var result jobResult
+	if err := json.Unmarshal(popped.Payload, &result); err != nil {
+		rc.fail(errors, &i.ProviderError{Phase: i.PhaseParse, Err: fmt.Errorf("error decoding job result: %w", err)})
+		rc.progress.removePending(rc.index, dj.pending)
+		return
+	}
+
+	rc.progress.update(rc.index, func(status *m.ProviderStatus) {
+		status.RequestsSent += result.RequestsSent
+		status.Retries += result.Retries
+		if result.QuotaExhausted {
+			status.State = m.QUOTA_EXHAUSTED
+		}
+	})
+	for _, err := range result.Errors {
+		rc.fail(errors, err.providerError())
+	}
+
+	parsed := i.ParsedResponse{InternetProducts: result.Products}
+	for _, request := range result.Requests {
+		prepared, err := request.Decode(ctx, c.adapter)
+		if err != nil {
+			rc.fail(errors, &i.ProviderError{Phase: i.PhaseParse, Err: fmt.Errorf("error decoding follow-up request: %w", err)})
+			continue
+		}
+		parsed.Requests = append(parsed.Requests, prepared)
+	}
+	c.handleParsed(ctx, rc, parsed, dj.depth+1, orig, responses, errors)
+	rc.progress.removePending(rc.index, dj.pending)
+}
+
+// abandon stops waiting for the results of all jobs
+func (d *distributor) abandon(ctx context.Context, errors chan<- *i.ProviderError) {
+	d.mutex.Lock()
+	d.closed = true
+	jobs := d.jobs
+	d.jobs = make(map[string]*distributedJob)
+	d.mutex.Unlock()
+
+	for _, dj := range jobs {
+		dj.rc.fail(errors, &i.ProviderError{Phase: i.PhaseTransport, Err: fmt.Errorf("request abandoned: %w", ctx.Err())})
+		dj.rc.wg.Done()
+	}
+}
+
+// close stops consuming results once the run finished and removes its results queue.
+// Results of redelivered jobs pushed afterwards expire with the deadline of the query.
+func (d *distributor) close() {
+	close(d.stop)
+	<-d.done
+	if err := d.queue.jobs.DeleteQueue(context.Background(), d.results); err != nil {
+		slog.Warn("Error deleting job results", "queue", d.results, "error", err)
+	}
+}
+
+// Work executes jobs with the workers of the work queue until ctx is done.
+// Jobs interrupted by ctx are not finished and delivered to another worker.
+func (c *RequestCoordinator) Work(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range c.queue.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.workLoop(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (c *RequestCoordinator) workLoop(ctx context.Context) {
+	q := c.queue
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		popped, ok, err := q.jobs.Pop(ctx, jobsQueue, q.visibility)
+		if err != nil && ctx.Err() == nil {
+			slog.Warn("Error receiving job", "error", err)
+		}
+		if ok {
+			c.work(ctx, popped)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// work executes a single job and returns its result to the running query
+func (c *RequestCoordinator) work(ctx context.Context, popped i.Job) {
+	q := c.queue
+	var j job
+	if err := json.Unmarshal(popped.Payload, &j); err != nil {
+		slog.Error("Dropping malformed job", "id", popped.ID, "error", err)
+		q.jobs.Ack(ctx, jobsQueue, popped.ID)
+		return
+	}
+	if !j.Deadline.IsZero() && time.Now().After(j.Deadline) {
+		slog.Debug("Dropping job of expired query", "id", popped.ID, "provider", j.Provider)
+		q.jobs.Ack(ctx, jobsQueue, popped.ID)
+		return
+	}
+
+	result := c.execute(ctx, popped, j)
+	if ctx.Err() != nil {
+		return
+	}
+	payload, err := json.Marshal(result)
+	if err != nil {
+		slog.Error("Error encoding job result", "id", popped.ID, "error", err)
+		return
+	}
+	// results of a query are of no use after its deadline, even if it was abandoned without deleting its queue
+	if err := q.jobs.Push(ctx, j.Results, popped.ID, payload, j.Deadline); err != nil {
+		// the job is delivered again after its visibility timeout
+		return
+	}
+	if err := q.jobs.Ack(ctx, jobsQueue, popped.ID); err != nil {
+		slog.Warn("Error acknowledging job", "id", popped.ID, "error", err)
+	}
+}
+
+// execute sends the request of a job with the retries, limits and breaker of its provider on this instance
+// and parses the response. Follow-up requests are not sent, but returned with the result.
+func (c *RequestCoordinator) execute(ctx context.Context, popped i.Job, j job) jobResult {
+	var result jobResult
+	cfg := c.provider(j.Provider)
+	if cfg == nil {
+		err := &i.ProviderError{Phase: i.PhasePrepare, Err: fmt.Errorf("provider %s is not configured on this instance", j.Provider)}
+		result.Errors = append(result.Errors, newJobError(err))
+		return result
+	}
+	if popped.Deliveries > c.queue.maxDeliveries {
+		slog.Warn("Giving up job delivered too often", "id", popped.ID, "provider", j.Provider, "deliveries", popped.Deliveries)
+		err := &i.ProviderError{Phase: i.PhaseTransport, Retryable: true, Err: fmt.Errorf("job given up after %d deliveries", c.queue.maxDeliveries)}
+		result.Errors = append(result.Errors, newJobError(err))
+		return result
+	}
+
+	if !j.Deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, j.Deadline)
+		defer cancel()
+	}
+	prepared, err := j.Request.Decode(ctx, c.adapter)
+	if err != nil {
+		err := &i.ProviderError{Phase: i.PhasePrepare, Err: fmt.Errorf("error decoding job: %w", err)}
+		result.Errors = append(result.Errors, newJobError(err))
+		return result
+	}
+
+	rc := newRequestContext(cfg, newProgress([]string{cfg.Adapter.Name()}), 0)
+	var parseErrors []jobError
+	rc.parsed = func(parsed i.ParsedResponse) {
+		result.Products = parsed.InternetProducts
+		for _, follow := range parsed.Requests {
+			if follow.Request == nil {
+				slog.Warn("No follow-up request provided, skipping", "followUp", follow)
+				continue
+			}
+			if follow.Callback == nil {
+				follow.Callback = cfg.Adapter
+			}
+			request, err := checkpoint.Encode(follow, j.Request.Depth+1)
+			if err != nil {
+				parseErrors = append(parseErrors, newJobError(&i.ProviderError{Phase: i.PhaseParse, Err: fmt.Errorf("error encoding follow-up request: %w", err)}))
+				continue
+			}
+			result.Requests = append(result.Requests, request)
+		}
+	}
+
+	errs := make(chan *i.ProviderError)
+	collected := make(chan []jobError)
+	go func() {
+		var jobErrors []jobError
+		for err := range errs {
+			jobErrors = append(jobErrors, newJobError(err))
+		}
+		collected <- jobErrors
+	}()
+	orig := i.Request{Address: j.Address}
+	c.dispatchRequest(ctx, rc, i.Response{InitialRequestData: orig, Request: prepared}, j.Request.Depth, orig, nil, errs)
+	close(errs)
+	result.Errors = append(<-collected, parseErrors...)
+
+	status := rc.progress.Snapshot().Providers[0]
+	result.RequestsSent = status.RequestsSent
+	result.Retries = status.Retries
+	result.QuotaExhausted = status.State == m.QUOTA_EXHAUSTED
+	return result
+}
+
+// provider returns the config of the provider called name, or nil if there is none
+func (c *RequestCoordinator) provider(name string) *p.ProviderConfig {
+	for _, cfg := range c.providers {
+		if cfg.Adapter.Name() == name {
+			return cfg
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package workerpool
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultWorkers   = 32
+	defaultQueueSize = 128
+)
+
+// ErrQueueFull is returned when a task is submitted while all workers are busy and the wait queue is full
+var ErrQueueFull = errors.New("worker pool queue is full")
+
+// ErrClosed is returned when a task is submitted after the pool was closed
+var ErrClosed = errors.New("worker pool is closed")
+
+// Stats is a snapshot of the utilization of a pool
+type Stats struct {
+	Workers       int
+	Busy          int
+	Queued        int
+	QueueCapacity int
+	Rejected      uint64 // total number of rejected tasks since the pool was created
+}
+
+// Pool runs tasks on a fixed number of workers.
+// Tasks that cannot be started immediately wait in a bounded queue.
+type Pool struct {
+	tasks    chan func()
+	workers  int
+	busy     atomic.Int64
+	rejected atomic.Uint64
+	wg       sync.WaitGroup
+
+	mutex  sync.RWMutex // guards closing tasks against concurrent submits
+	closed bool
+}
+
+// New starts a pool with the given number of workers and wait queue size.
+// Non-positive values are replaced with defaults.
+func New(workers int, queueSize int) *Pool {
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+
+	p := &Pool{
+		tasks:   make(chan func(), queueSize),
+		workers: workers,
+	}
+	p.wg.Add(workers)
+	for range workers {
+		go p.work()
+	}
+	return p
+}
+
+// Submit queues task for execution without blocking.
+// ErrQueueFull is returned if the wait queue is full, ErrClosed if the pool was closed.
+func (p *Pool) Submit(task func()) error {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.closed {
+		p.rejected.Add(1)
+		return ErrClosed
+	}
+	select {
+	case p.tasks <- task:
+		return nil
+	default:
+		p.rejected.Add(1)
+		return ErrQueueFull
+	}
+}
+
+// Stats returns the current utilization of the pool
+func (p *Pool) Stats() Stats {
+	return Stats{
+		Workers:       p.workers,
+		Busy:          int(p.busy.Load()),
+		Queued:        len(p.tasks),
+		QueueCapacity: cap(p.tasks),
+		Rejected:      p.rejected.Load(),
+	}
+}
+
+// Close stops accepting tasks and waits until all queued tasks finished.
+// Tasks submitted after Close are rejected with ErrClosed.
+func (p *Pool) Close() {
+	p.mutex.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.tasks)
+	}
+	p.mutex.Unlock()
+	p.wg.Wait()
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for task := range p.tasks {
+		p.busy.Add(1)
+		task()
+		p.busy.Add(-1)
+	}
+}
@@ -0,0 +1,73 @@
+package workerpool
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestPool_RejectsWhenQueueFull(t *testing.T) {
+	pool := New(1, 1)
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	if err := pool.Submit(func() { close(started); <-release }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	<-started
+	if err := pool.Submit(func() {}); err != nil {
+		t.Fatalf("expected task to be queued, got %v", err)
+	}
+	if err := pool.Submit(func() {}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull, got %v", err)
+	}
+
+	stats := pool.Stats()
+	want := Stats{Workers: 1, Busy: 1, Queued: 1, QueueCapacity: 1, Rejected: 1}
+	if stats != want {
+		t.Errorf("expected stats %+v, got %+v", want, stats)
+	}
+
+	close(release)
+	pool.Close()
+	if stats := pool.Stats(); stats.Busy != 0 || stats.Queued != 0 {
+		t.Errorf("expected idle pool after close, got %+v", stats)
+	}
+}
+
+func TestPool_RunsAllTasks(t *testing.T) {
+	pool := New(4, 16)
+	var mutex sync.Mutex
+	count := 0
+	for range 16 {
+		if err := pool.Submit(func() {
+			mutex.Lock()
+			count++
+			mutex.Unlock()
+		}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	pool.Close()
+	if count != 16 {
+		t.Errorf("expected 16 tasks to run, got %d", count)
+	}
+}
+
+func TestPool_Defaults(t *testing.T) {
+	pool := New(0, 0)
+	defer pool.Close()
+	if stats := pool.Stats(); stats.Workers != defaultWorkers || stats.QueueCapacity != defaultQueueSize {
+		t.Errorf("expected default sizes, got %+v", stats)
+	}
+}
+
+func TestPool_RejectsAfterClose(t *testing.T) {
+	pool := New(1, 1)
+	pool.Close()
+	if err := pool.Submit(func() {}); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+	// closing twice is a no-op
+	pool.Close()
+}
@@ -0,0 +1,118 @@
+// Package auth provides signers that authenticate requests to providers.
+// All signers are safe for concurrent use and redact their secrets when they are logged or printed.
+package auth
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/rotmanjanez/check24-gendev-7/config"
+)
+
+const redacted = "[REDACTED]"
+
+// Secret is a credential that is never revealed by fmt, slog or encoding/json
+type Secret string
+
+func (Secret) String() string {
+	return redacted
+}
+
+func (Secret) GoString() string {
+	return redacted
+}
+
+func (Secret) LogValue() slog.Value {
+	return slog.StringValue(redacted)
+}
+
+func (Secret) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+// Signer adds credentials to a request before it is sent.
+// Sign is called before every attempt of a request, so signatures are always fresh.
+type Signer interface {
+	Sign(req *http.Request) error
+}
+
+// Chain applies multiple signers in order
+type Chain []Signer
+
+func (c Chain) Sign(req *http.Request) error {
+	for _, signer := range c {
+		if err := signer.Sign(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// New creates the signers declared in cfgs, reading their secrets from the environment.
+// It returns nil if cfgs is empty.
+func New(cfgs []config.AuthConfig) (Signer, error) {
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+
+	chain := make(Chain, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		signer, err := newSigner(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s auth: %w", cfg.Type, err)
+		}
+		chain = append(chain, signer)
+	}
+	if len(chain) == 1 {
+		return chain[0], nil
+	}
+	return chain, nil
+}
+
+func newSigner(cfg config.AuthConfig) (Signer, error) {
+	switch cfg.Type {
+	case config.APIKeyHeaderAuth:
+		key, err := requireEnv(cfg.KeyEnv)
+		if err != nil {
+			return nil, err
+		}
+		return NewAPIKeyHeader(cfg.Name, key), nil
+	case config.APIKeyQueryAuth:
+		key, err := requireEnv(cfg.KeyEnv)
+		if err != nil {
+			return nil, err
+		}
+		return NewAPIKeyQuery(cfg.Name, key), nil
+	case config.BasicAuth:
+		username, err := requireEnv(cfg.UsernameEnv)
+		if err != nil {
+			return nil, err
+		}
+		password, err := requireEnv(cfg.PasswordEnv)
+		if err != nil {
+			return nil, err
+		}
+		return NewBasicAuth(username, password), nil
+	case config.HMACAuth:
+		secret, err := requireEnv(cfg.KeyEnv)
+		if err != nil {
+			return nil, err
+		}
+		return NewHMAC(secret, cfg.Name, cfg.TimestampHeader), nil
+	default:
+		return nil, fmt.Errorf("unknown auth type")
+	}
+}
+
+func requireEnv(key string) (Secret, error) {
+	if key == "" {
+		return "", fmt.Errorf("no environment variable configured")
+	}
+	v := os.Getenv(key)
+	if v == "" {
+		return "", fmt.Errorf("environment variable %s is required and cannot be empty", key)
+	}
+	return Secret(v), nil
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rotmanjanez/check24-gendev-7/config"
+)
+
+func TestSigners(t *testing.T) {
+	tests := []struct {
+		name   string
+		signer Signer
+		check  func(req *http.Request) bool
+	}{
+		{"api key header", NewAPIKeyHeader("", "key"), func(req *http.Request) bool {
+			return req.Header.Get("X-Api-Key") == "key"
+		}},
+		{"api key query", NewAPIKeyQuery("token", "key"), func(req *http.Request) bool {
+			return req.URL.Query().Get("token") == "key" && req.URL.Query().Get("page") == "1"
+		}},
+		{"basic auth", NewBasicAuth("user", "pass"), func(req *http.Request) bool {
+			username, password, ok := req.BasicAuth()
+			return ok && username == "user" && password == "pass"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "http://localhost/data?page=1", nil)
+			if err := tt.signer.Sign(req); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.check(req) {
+				t.Errorf("credentials missing in %v %v", req.URL, req.Header)
+			}
+		})
+	}
+}
+
+func TestHMAC(t *testing.T) {
+	signer := NewHMAC("secret", "", "")
+	signer.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(`1700000000:{"a":1}`))
+	want := hex.EncodeToString(mac.Sum(nil))
+
+	// signing concurrently must not mix up signatures
+	var wg sync.WaitGroup
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodPost, "http://localhost", io.NopCloser(strings.NewReader(`{"a":1}`)))
+			if err := signer.Sign(req); err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if got := req.Header.Get("X-Signature"); got != want {
+				t.Errorf("expected signature %s, got %s", want, got)
+			}
+			if got := req.Header.Get("X-Timestamp"); got != "1700000000" {
+				t.Errorf("expected timestamp 1700000000, got %s", got)
+			}
+			if body, _ := io.ReadAll(req.Body); string(body) != `{"a":1}` {
+				t.Errorf("expected body to be kept, got %s", body)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNew(t *testing.T) {
+	t.Setenv("TEST_API_KEY", "key")
+	t.Setenv("TEST_SECRET", "secret")
+
+	signer, err := New([]config.AuthConfig{
+		{Type: config.APIKeyHeaderAuth, Name: "X-Client-ID", KeyEnv: "TEST_API_KEY"},
+		{Type: config.HMACAuth, KeyEnv: "TEST_SECRET"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req, _ := http.NewRequest(http.MethodPost, "http://localhost", strings.NewReader("body"))
+	if err := signer.Sign(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Header.Get("X-Client-ID") != "key" || req.Header.Get("X-Signature") == "" {
+		t.Errorf("expected all signers to be applied, got %v", req.Header)
+	}
+
+	if signer, err := New(nil); signer != nil || err != nil {
+		t.Errorf("expected no signer without config, got %v, %v", signer, err)
+	}
+	if _, err := New([]config.AuthConfig{{Type: config.APIKeyHeaderAuth, KeyEnv: "TEST_MISSING_KEY"}}); err == nil {
+		t.Errorf("expected error for missing environment variable")
+	}
+	if _, err := New([]config.AuthConfig{{Type: "oauth"}}); err == nil {
+		t.Errorf("expected error for unknown type")
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	signers := Chain{
+		NewAPIKeyHeader("", "top-secret"),
+		NewAPIKeyQuery("", "top-secret"),
+		NewBasicAuth("top-secret", "top-secret"),
+		NewHMAC("top-secret", "", ""),
+	}
+
+	var logged strings.Builder
+	logger := slog.New(slog.NewJSONHandler(&logged, nil))
+	logger.Info("signers", "signers", signers, "secret", Secret("top-secret"))
+
+	for _, out := range []string{fmt.Sprint(signers), fmt.Sprintf("%+v", signers), fmt.Sprintf("%#v", Secret("top-secret")), logged.String()} {
+		if strings.Contains(out, "top-secret") {
+			t.Errorf("secret revealed in %s", out)
+		}
+	}
+}
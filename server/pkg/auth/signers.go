@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// APIKeyHeader sends an API key in a request header
+type APIKeyHeader struct {
+	header string
+	key    Secret
+}
+
+// NewAPIKeyHeader creates a signer setting header to key, X-Api-Key if header is empty
+func NewAPIKeyHeader(header string, key Secret) *APIKeyHeader {
+	if header == "" {
+		header = "X-Api-Key"
+	}
+	return &APIKeyHeader{header: header, key: key}
+}
+
+func (s *APIKeyHeader) Sign(req *http.Request) error {
+	req.Header.Set(s.header, string(s.key))
+	return nil
+}
+
+func (s *APIKeyHeader) String() string {
+	return fmt.Sprintf("APIKeyHeader(%s: %s)", s.header, s.key)
+}
+
+// APIKeyQuery sends an API key as a query parameter
+type APIKeyQuery struct {
+	param string
+	key   Secret
+}
+
+// NewAPIKeyQuery creates a signer setting the query parameter param to key, apiKey if param is empty
+func NewAPIKeyQuery(param string, key Secret) *APIKeyQuery {
+	if param == "" {
+		param = "apiKey"
+	}
+	return &APIKeyQuery{param: param, key: key}
+}
+
+func (s *APIKeyQuery) Sign(req *http.Request) error {
+	query := req.URL.Query()
+	query.Set(s.param, string(s.key))
+	req.URL.RawQuery = query.Encode()
+	return nil
+}
+
+func (s *APIKeyQuery) String() string {
+	return fmt.Sprintf("APIKeyQuery(%s=%s)", s.param, s.key)
+}
+
+// BasicAuth sends credentials with HTTP basic authentication
+type BasicAuth struct {
+	username Secret
+	password Secret
+}
+
+func NewBasicAuth(username Secret, password Secret) *BasicAuth {
+	return &BasicAuth{username: username, password: password}
+}
+
+func (s *BasicAuth) Sign(req *http.Request) error {
+	req.SetBasicAuth(string(s.username), string(s.password))
+	return nil
+}
+
+func (s *BasicAuth) String() string {
+	return fmt.Sprintf("BasicAuth(%s:%s)", s.username, s.password)
+}
+
+// HMAC signs requests with HMAC-SHA256 over "<unix timestamp>:<body>".
+// The hex encoded signature and the timestamp are sent in headers.
+type HMAC struct {
+	secret          Secret
+	signatureHeader string
+	timestampHeader string
+	now             func() time.Time
+}
+
+// NewHMAC creates an HMAC signer, the headers default to X-Signature and X-Timestamp
+func NewHMAC(secret Secret, signatureHeader string, timestampHeader string) *HMAC {
+	if signatureHeader == "" {
+		signatureHeader = "X-Signature"
+	}
+	if timestampHeader == "" {
+		timestampHeader = "X-Timestamp"
+	}
+	return &HMAC{
+		secret:          secret,
+		signatureHeader: signatureHeader,
+		timestampHeader: timestampHeader,
+		now:             time.Now,
+	}
+}
+
+func (s *HMAC) Sign(req *http.Request) error {
+	body, err := readBody(req)
+	if err != nil {
+		return fmt.Errorf("error reading request body for signature: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	// hashes are not safe for concurrent use, create one per signature
+	mac := hmac.New(sha256.New, []byte(s.secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte(":"))
+	mac.Write(body)
+
+	req.Header.Set(s.signatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	req.Header.Set(s.timestampHeader, timestamp)
+	return nil
+}
+
+func (s *HMAC) String() string {
+	return fmt.Sprintf("HMAC(%s, %s: %s)", s.signatureHeader, s.timestampHeader, s.secret)
+}
+
+// readBody returns the body of req without consuming it
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		reader, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
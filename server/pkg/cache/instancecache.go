@@ -4,6 +4,9 @@ import (
 	"context"
 	"encoding"
 	"log/slog"
+	"math"
+	"slices"
+	"strconv"
 	"sync"
 	"time"
 
@@ -25,19 +28,53 @@ type cacheItem struct {
 	expiresAt time.Time
 }
 
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+type logItem struct {
+	entries   [][]byte
+	expiresAt time.Time
+}
+
+type queuedJob struct {
+	payload    []byte
+	visibleAt  time.Time
+	deliveries int
+	order      uint64    // jobs are delivered in the order they were pushed
+	expiresAt  time.Time // zero if the job never expires
+}
+
+type lease struct {
+	owner     string
+	expiresAt time.Time
+}
+
 type InstanceCache struct {
-	data   map[string]cacheItem
-	mutex  sync.RWMutex
-	ticker *time.Ticker
-	done   chan bool
-	logger *slog.Logger
+	data    map[string]cacheItem
+	buckets map[string]*tokenBucket
+	leases  map[string]map[string]lease
+	jobs    map[string]map[string]*queuedJob
+	logs    map[string]*logItem
+	subs    map[string]map[chan struct{}]struct{}
+	pushed  uint64
+	mutex   sync.RWMutex
+	ticker  *time.Ticker
+	done    chan bool
+	logger  *slog.Logger
 }
 
 func NewInstanceCache(name string) *InstanceCache {
 	cache := &InstanceCache{
-		data:   make(map[string]cacheItem),
-		done:   make(chan bool),
-		logger: slog.Default().With("cache", name),
+		data:    make(map[string]cacheItem),
+		buckets: make(map[string]*tokenBucket),
+		leases:  make(map[string]map[string]lease),
+		jobs:    make(map[string]map[string]*queuedJob),
+		logs:    make(map[string]*logItem),
+		subs:    make(map[string]map[chan struct{}]struct{}),
+		done:    make(chan bool),
+		logger:  slog.Default().With("cache", name),
 	}
 
 	// Start cleanup goroutine that runs every 30 seconds
@@ -114,7 +151,8 @@ func (c *InstanceCache) SetIfNotExists(ctx context.Context, key string, setValue
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	if _, exists := c.data[key]; exists {
+	// expired items that were not cleaned up yet do not count as existing
+	if item, exists := c.data[key]; exists && (item.expiresAt.IsZero() || time.Now().Before(item.expiresAt)) {
 		return false, nil
 	}
 
@@ -150,5 +188,245 @@ func (c *InstanceCache) Persist(ctx context.Context, key string) error {
 	return nil
 }
 
+func (c *InstanceCache) Increment(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	item, exists := c.data[key]
+	if !exists || (!item.expiresAt.IsZero() && now.After(item.expiresAt)) {
+		item = cacheItem{value: []byte("0")}
+		if ttl > 0 {
+			item.expiresAt = now.Add(ttl)
+		}
+	}
+
+	value, err := strconv.ParseInt(string(item.value), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	value++
+	item.value = []byte(strconv.FormatInt(value, 10))
+	c.data[key] = item
+
+	return value, nil
+}
+
+func (c *InstanceCache) TakeToken(ctx context.Context, key string, rate float64, burst int) (time.Duration, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	bucket, exists := c.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: float64(burst), updatedAt: now}
+		c.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(float64(burst), bucket.tokens+now.Sub(bucket.updatedAt).Seconds()*rate)
+	bucket.updatedAt = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return 0, nil
+	}
+	return time.Duration((1 - bucket.tokens) / rate * float64(time.Second)), nil
+}
+
+func (c *InstanceCache) Lease(ctx context.Context, group string, key string, owner string, ttl time.Duration) (bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	leases := c.leases[group]
+	if leases == nil {
+		leases = make(map[string]lease)
+		c.leases[group] = leases
+	}
+	if current, exists := leases[key]; exists && current.owner != owner && now.Before(current.expiresAt) {
+		return false, nil
+	}
+	leases[key] = lease{owner: owner, expiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+func (c *InstanceCache) Release(ctx context.Context, group string, key string, owner string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if current, exists := c.leases[group][key]; exists && current.owner == owner {
+		c.leases[group][key] = lease{owner: owner}
+	}
+	return nil
+}
+
+func (c *InstanceCache) Remove(ctx context.Context, group string, key string, owner string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if current, exists := c.leases[group][key]; exists && current.owner == owner {
+		delete(c.leases[group], key)
+	}
+	return nil
+}
+
+func (c *InstanceCache) Expired(ctx context.Context, group string, limit int) ([]string, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	now := time.Now()
+	var expired []string
+	for key, current := range c.leases[group] {
+		if len(expired) >= limit {
+			break
+		}
+		if !now.Before(current.expiresAt) {
+			expired = append(expired, key)
+		}
+	}
+	return expired, nil
+}
+
+func (c *InstanceCache) Append(ctx context.Context, key string, ttl time.Duration, values ...encoding.BinaryMarshaler) (int64, error) {
+	entries := make([][]byte, len(values))
+	for idx, value := range values {
+		data, err := value.MarshalBinary()
+		if err != nil {
+			return 0, err
+		}
+		entries[idx] = data
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	log, exists := c.logs[key]
+	if !exists || (!log.expiresAt.IsZero() && time.Now().After(log.expiresAt)) {
+		log = &logItem{}
+		c.logs[key] = log
+	}
+	log.entries = append(log.entries, entries...)
+	log.expiresAt = time.Time{}
+	if ttl > 0 {
+		log.expiresAt = time.Now().Add(ttl)
+	}
+	return int64(len(log.entries)), nil
+}
+
+func (c *InstanceCache) Range(ctx context.Context, key string, offset int64, limit int64) ([][]byte, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	log, exists := c.logs[key]
+	if !exists || (!log.expiresAt.IsZero() && time.Now().After(log.expiresAt)) || offset >= int64(len(log.entries)) {
+		return nil, nil
+	}
+	end := int64(len(log.entries))
+	if limit > 0 {
+		end = min(end, offset+limit)
+	}
+	return slices.Clone(log.entries[offset:end]), nil
+}
+
+func (c *InstanceCache) Publish(ctx context.Context, channel string) error {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	for sub := range c.subs[channel] {
+		select {
+		case sub <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (c *InstanceCache) Subscribe(ctx context.Context, channel string) (<-chan struct{}, error) {
+	sub := make(chan struct{}, 1)
+
+	c.mutex.Lock()
+	if c.subs[channel] == nil {
+		c.subs[channel] = make(map[chan struct{}]struct{})
+	}
+	c.subs[channel][sub] = struct{}{}
+	c.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		delete(c.subs[channel], sub)
+		if len(c.subs[channel]) == 0 {
+			delete(c.subs, channel)
+		}
+	}()
+	return sub, nil
+}
+
+func (c *InstanceCache) Push(ctx context.Context, queue string, id string, payload []byte, expiresAt time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	jobs := c.jobs[queue]
+	if jobs == nil {
+		jobs = make(map[string]*queuedJob)
+		c.jobs[queue] = jobs
+	}
+	c.pushed++
+	jobs[id] = &queuedJob{payload: payload, visibleAt: time.Now(), order: c.pushed, expiresAt: expiresAt}
+	// like a Redis key, the whole queue expires together
+	if !expiresAt.IsZero() {
+		for _, job := range jobs {
+			job.expiresAt = expiresAt
+		}
+	}
+	return nil
+}
+
+func (c *InstanceCache) Pop(ctx context.Context, queue string, visibility time.Duration) (interfaces.Job, bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	var nextID string
+	var next *queuedJob
+	for id, job := range c.jobs[queue] {
+		if !job.expiresAt.IsZero() && now.After(job.expiresAt) {
+			delete(c.jobs[queue], id)
+			continue
+		}
+		if now.Before(job.visibleAt) {
+			continue
+		}
+		if next == nil || job.order < next.order {
+			nextID, next = id, job
+		}
+	}
+	if next == nil {
+		return interfaces.Job{}, false, nil
+	}
+
+	next.visibleAt = now.Add(visibility)
+	next.deliveries++
+	return interfaces.Job{ID: nextID, Payload: next.payload, Deliveries: next.deliveries}, true, nil
+}
+
+func (c *InstanceCache) Ack(ctx context.Context, queue string, id string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.jobs[queue], id)
+	return nil
+}
+
+func (c *InstanceCache) DeleteQueue(ctx context.Context, queue string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.jobs, queue)
+	return nil
+}
+
 // Ensure InstanceCache implements the Cache interface
 var _ interfaces.Cache = (*InstanceCache)(nil)
+var _ interfaces.RateLimitStore = (*InstanceCache)(nil)
+var _ interfaces.LeaseStore = (*InstanceCache)(nil)
+var _ interfaces.JobQueue = (*InstanceCache)(nil)
+var _ interfaces.QueueCache = (*InstanceCache)(nil)
+var _ interfaces.Notifier = (*InstanceCache)(nil)
@@ -121,6 +121,25 @@ func TestInstanceCache_TTL(t *testing.T) {
 	}
 }
 
+func TestInstanceCache_SetIfNotExistsExpired(t *testing.T) {
+	cache := NewInstanceCache("test")
+	ctx := context.Background()
+	key := "foo"
+
+	if set, err := cache.SetIfNotExists(ctx, key, &testValue{Data: "bar"}, 10*time.Millisecond); err != nil || !set {
+		t.Fatalf("Expected SetIfNotExists to set value, got %v, %v", set, err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	set, err := cache.SetIfNotExists(ctx, key, &testValue{Data: "baz"}, 0)
+	if err != nil {
+		t.Fatalf("SetIfNotExists failed: %v", err)
+	}
+	if !set {
+		t.Errorf("Expected SetIfNotExists to replace an expired value")
+	}
+}
+
 func TestInstanceCache_Persist(t *testing.T) {
 	cache := NewInstanceCache("test")
 	ctx := context.Background()
@@ -130,3 +149,157 @@ func TestInstanceCache_Persist(t *testing.T) {
 		t.Errorf("Persist should be a no-op and not error, got: %v", err)
 	}
 }
+
+func TestInstanceCache_Lease(t *testing.T) {
+	cache := NewInstanceCache("test")
+	ctx := context.Background()
+
+	if ok, err := cache.Lease(ctx, "group", "foo", "a", 20*time.Millisecond); err != nil || !ok {
+		t.Fatalf("Expected lease to be acquired, got %v, %v", ok, err)
+	}
+	if ok, _ := cache.Lease(ctx, "group", "foo", "b", time.Second); ok {
+		t.Errorf("Expected lease held by another owner to be refused")
+	}
+	if ok, _ := cache.Lease(ctx, "group", "foo", "a", 20*time.Millisecond); !ok {
+		t.Errorf("Expected owner to renew its lease")
+	}
+	if expired, _ := cache.Expired(ctx, "group", 10); len(expired) != 0 {
+		t.Errorf("Expected no expired leases, got %v", expired)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if expired, _ := cache.Expired(ctx, "group", 10); len(expired) != 1 || expired[0] != "foo" {
+		t.Errorf("Expected expired lease, got %v", expired)
+	}
+	if ok, _ := cache.Lease(ctx, "group", "foo", "b", time.Second); !ok {
+		t.Fatalf("Expected expired lease to be taken over")
+	}
+
+	// only the owner can release and remove a lease
+	_ = cache.Release(ctx, "group", "foo", "a")
+	if expired, _ := cache.Expired(ctx, "group", 10); len(expired) != 0 {
+		t.Errorf("Expected lease of another owner to stay, got %v", expired)
+	}
+	_ = cache.Release(ctx, "group", "foo", "b")
+	if expired, _ := cache.Expired(ctx, "group", 10); len(expired) != 1 {
+		t.Errorf("Expected released lease to expire immediately, got %v", expired)
+	}
+	_ = cache.Remove(ctx, "group", "foo", "b")
+	if expired, _ := cache.Expired(ctx, "group", 10); len(expired) != 0 {
+		t.Errorf("Expected removed lease to be gone, got %v", expired)
+	}
+}
+
+func TestInstanceCache_JobQueue(t *testing.T) {
+	cache := NewInstanceCache("test")
+	ctx := context.Background()
+
+	_ = cache.Push(ctx, "jobs", "first", []byte("1"), time.Time{})
+	_ = cache.Push(ctx, "jobs", "second", []byte("2"), time.Time{})
+
+	job, ok, err := cache.Pop(ctx, "jobs", 20*time.Millisecond)
+	if err != nil || !ok || job.ID != "first" || string(job.Payload) != "1" || job.Deliveries != 1 {
+		t.Fatalf("Expected first job, got %+v, %v, %v", job, ok, err)
+	}
+	if job, ok, _ := cache.Pop(ctx, "jobs", time.Second); !ok || job.ID != "second" {
+		t.Fatalf("Expected second job, got %+v, %v", job, ok)
+	}
+	if _, ok, _ := cache.Pop(ctx, "jobs", time.Second); ok {
+		t.Errorf("Expected taken jobs to be invisible")
+	}
+
+	// the consumer of the first job crashed without acknowledging it
+	time.Sleep(30 * time.Millisecond)
+	job, ok, _ = cache.Pop(ctx, "jobs", time.Second)
+	if !ok || job.ID != "first" || job.Deliveries != 2 {
+		t.Fatalf("Expected first job to be delivered again, got %+v, %v", job, ok)
+	}
+
+	_ = cache.Ack(ctx, "jobs", "first")
+	_ = cache.Ack(ctx, "jobs", "second")
+	time.Sleep(30 * time.Millisecond)
+	if _, ok, _ := cache.Pop(ctx, "jobs", time.Second); ok {
+		t.Errorf("Expected acknowledged jobs to be gone")
+	}
+
+	_ = cache.Push(ctx, "results", "first", []byte("1"), time.Time{})
+	_ = cache.Push(ctx, "results", "second", []byte("2"), time.Now().Add(20*time.Millisecond))
+	time.Sleep(30 * time.Millisecond)
+	if job, ok, _ := cache.Pop(ctx, "results", time.Second); ok {
+		t.Errorf("Expected expired queue to be gone, got %+v", job)
+	}
+
+	_ = cache.Push(ctx, "results", "third", []byte("3"), time.Time{})
+	_ = cache.DeleteQueue(ctx, "results")
+	if job, ok, _ := cache.Pop(ctx, "results", time.Second); ok {
+		t.Errorf("Expected deleted queue to be gone, got %+v", job)
+	}
+}
+
+func TestInstanceCache_Log(t *testing.T) {
+	cache := NewInstanceCache("test")
+	ctx := context.Background()
+
+	if entries, err := cache.Range(ctx, "log", 0, 0); err != nil || len(entries) != 0 {
+		t.Fatalf("Expected missing log to be empty, got %v, %v", entries, err)
+	}
+	if length, err := cache.Append(ctx, "log", 20*time.Millisecond, &testValue{Data: "a"}, &testValue{Data: "b"}); err != nil || length != 2 {
+		t.Fatalf("Expected log of length 2, got %d, %v", length, err)
+	}
+	if length, _ := cache.Append(ctx, "log", 20*time.Millisecond, &testValue{Data: "c"}); length != 3 {
+		t.Fatalf("Expected log of length 3, got %d", length)
+	}
+
+	entries, _ := cache.Range(ctx, "log", 1, 0)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries from offset 1, got %d", len(entries))
+	}
+	value := new(testValue)
+	if err := value.UnmarshalBinary(entries[0]); err != nil || value.Data != "b" {
+		t.Errorf("Expected entry b, got %v, %v", value, err)
+	}
+	if entries, _ := cache.Range(ctx, "log", 0, 1); len(entries) != 1 {
+		t.Errorf("Expected limit to be respected, got %d entries", len(entries))
+	}
+	if entries, _ := cache.Range(ctx, "log", 3, 0); len(entries) != 0 {
+		t.Errorf("Expected no entries past the end, got %d", len(entries))
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if entries, _ := cache.Range(ctx, "log", 0, 0); len(entries) != 0 {
+		t.Errorf("Expected expired log to be empty, got %d entries", len(entries))
+	}
+}
+
+func TestInstanceCache_Notifier(t *testing.T) {
+	cache := NewInstanceCache("test")
+	ctx, cancel := context.WithCancel(context.Background())
+
+	updates, err := cache.Subscribe(ctx, "channel")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	_ = cache.Publish(context.Background(), "other")
+	_ = cache.Publish(context.Background(), "channel")
+	_ = cache.Publish(context.Background(), "channel")
+
+	select {
+	case <-updates:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected a notification")
+	}
+	select {
+	case <-updates:
+		t.Errorf("Expected notifications to be coalesced")
+	default:
+	}
+
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	_ = cache.Publish(context.Background(), "channel")
+	select {
+	case <-updates:
+		t.Errorf("Expected no notification after the subscription ended")
+	default:
+	}
+}
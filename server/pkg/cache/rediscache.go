@@ -5,6 +5,8 @@ import (
 	"encoding"
 	"fmt"
 	"log/slog"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -50,12 +52,18 @@ func (f *RedisCacheFactory) Create(name string) (interfaces.Cache, error) {
 type RedisCache struct {
 	prefix string
 	client *redis.Client
+
+	// all subscribers share a single pattern subscription to the channels of the cache
+	subMutex sync.Mutex
+	pubsub   *redis.PubSub
+	subs     map[string]map[chan struct{}]struct{}
 }
 
 func NewRedisCache(prefix string, client *redis.Client) *RedisCache {
 	return &RedisCache{
 		prefix: prefix + ":",
 		client: client,
+		subs:   make(map[string]map[chan struct{}]struct{}),
 	}
 }
 
@@ -127,4 +135,321 @@ func (r *RedisCache) SetIfNotExists(ctx context.Context, key string, setValue en
 	return val, nil
 }
 
+// takeTokenScript refills and takes from a token bucket stored as a hash.
+// The time of the redis server is used, so that the clocks of the instances do not matter.
+var takeTokenScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'updatedAt')
+local tokens = tonumber(bucket[1]) or burst
+local updatedAt = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + (now - updatedAt) / 1000 * rate)
+
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updatedAt', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return wait
+`)
+
+func (r *RedisCache) Increment(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	key = r.prefix + key
+	slog.Debug("Incrementing counter in Redis", "key", key, "ttl", ttl)
+	val, err := r.client.Incr(ctx, key).Result()
+	if err != nil {
+		slog.Error("Error incrementing counter in Redis", "key", key, "error", err)
+		return 0, err
+	}
+	if val == 1 && ttl > 0 {
+		if err := r.client.Expire(ctx, key, ttl).Err(); err != nil {
+			slog.Error("Error setting ttl of counter in Redis", "key", key, "error", err)
+			return 0, err
+		}
+	}
+	return val, nil
+}
+
+func (r *RedisCache) TakeToken(ctx context.Context, key string, rate float64, burst int) (time.Duration, error) {
+	key = r.prefix + key
+	wait, err := takeTokenScript.Run(ctx, r.client, []string{key}, rate, burst).Int64()
+	if err != nil {
+		slog.Error("Error taking token in Redis", "key", key, "error", err)
+		return 0, err
+	}
+	return time.Duration(wait) * time.Millisecond, nil
+}
+
+// Leases of a group are stored in a sorted set scored by their expiry in milliseconds
+// and a hash mapping the keys to their owners. Expired keys stay in the group until they are removed.
+// The time of the redis server is used, so that the clocks of the instances do not matter.
+var leaseScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local expiresAt = tonumber(redis.call('ZSCORE', KEYS[1], ARGV[1]))
+local owner = redis.call('HGET', KEYS[2], ARGV[1])
+if expiresAt and expiresAt > now and owner ~= ARGV[2] then
+	return 0
+end
+
+redis.call('ZADD', KEYS[1], now + tonumber(ARGV[3]), ARGV[1])
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[2])
+return 1
+`)
+
+var releaseLeaseScript = redis.NewScript(`
+if redis.call('HGET', KEYS[2], ARGV[1]) ~= ARGV[2] then
+	return 0
+end
+if ARGV[3] == 'remove' then
+	redis.call('ZREM', KEYS[1], ARGV[1])
+	redis.call('HDEL', KEYS[2], ARGV[1])
+else
+	redis.call('ZADD', KEYS[1], 0, ARGV[1])
+end
+return 1
+`)
+
+var expiredLeasesScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+return redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', now, 'LIMIT', 0, tonumber(ARGV[1]))
+`)
+
+func (r *RedisCache) leaseKeys(group string) []string {
+	return []string{r.prefix + group, r.prefix + group + ":owners"}
+}
+
+func (r *RedisCache) Lease(ctx context.Context, group string, key string, owner string, ttl time.Duration) (bool, error) {
+	ok, err := leaseScript.Run(ctx, r.client, r.leaseKeys(group), key, owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		slog.Error("Error leasing key in Redis", "group", group, "key", key, "error", err)
+		return false, err
+	}
+	return ok == 1, nil
+}
+
+func (r *RedisCache) Release(ctx context.Context, group string, key string, owner string) error {
+	err := releaseLeaseScript.Run(ctx, r.client, r.leaseKeys(group), key, owner, "release").Err()
+	if err != nil {
+		slog.Error("Error releasing lease in Redis", "group", group, "key", key, "error", err)
+	}
+	return err
+}
+
+func (r *RedisCache) Remove(ctx context.Context, group string, key string, owner string) error {
+	err := releaseLeaseScript.Run(ctx, r.client, r.leaseKeys(group), key, owner, "remove").Err()
+	if err != nil {
+		slog.Error("Error removing lease in Redis", "group", group, "key", key, "error", err)
+	}
+	return err
+}
+
+func (r *RedisCache) Expired(ctx context.Context, group string, limit int) ([]string, error) {
+	keys, err := expiredLeasesScript.Run(ctx, r.client, r.leaseKeys(group)[:1], limit).StringSlice()
+	if err != nil {
+		slog.Error("Error getting expired leases from Redis", "group", group, "error", err)
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *RedisCache) Append(ctx context.Context, key string, ttl time.Duration, values ...encoding.BinaryMarshaler) (int64, error) {
+	key = r.prefix + key
+	entries := make([]any, len(values))
+	for idx, value := range values {
+		entries[idx] = value
+	}
+
+	pipe := r.client.TxPipeline()
+	length := pipe.RPush(ctx, key, entries...)
+	if ttl > 0 {
+		pipe.Expire(ctx, key, ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Error("Error appending to log in Redis", "key", key, "error", err)
+		return 0, err
+	}
+	return length.Val(), nil
+}
+
+func (r *RedisCache) Range(ctx context.Context, key string, offset int64, limit int64) ([][]byte, error) {
+	key = r.prefix + key
+	stop := int64(-1)
+	if limit > 0 {
+		stop = offset + limit - 1
+	}
+	values, err := r.client.LRange(ctx, key, offset, stop).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error reading log from redis: %w", err)
+	}
+	entries := make([][]byte, len(values))
+	for idx, value := range values {
+		entries[idx] = []byte(value)
+	}
+	return entries, nil
+}
+
+func (r *RedisCache) Publish(ctx context.Context, channel string) error {
+	if err := r.client.Publish(ctx, r.prefix+channel, "").Err(); err != nil {
+		slog.Error("Error publishing notification to Redis", "channel", channel, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (r *RedisCache) Subscribe(ctx context.Context, channel string) (<-chan struct{}, error) {
+	sub := make(chan struct{}, 1)
+
+	r.subMutex.Lock()
+	if r.pubsub == nil {
+		pubsub := r.client.PSubscribe(context.Background(), r.prefix+"*")
+		// wait for the confirmation, so that no notification published afterwards is missed
+		if _, err := pubsub.Receive(ctx); err != nil {
+			r.subMutex.Unlock()
+			pubsub.Close()
+			return nil, fmt.Errorf("error subscribing to redis channels: %w", err)
+		}
+		r.pubsub = pubsub
+		go r.notify(pubsub.Channel())
+	}
+	if r.subs[channel] == nil {
+		r.subs[channel] = make(map[chan struct{}]struct{})
+	}
+	r.subs[channel][sub] = struct{}{}
+	r.subMutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		r.subMutex.Lock()
+		defer r.subMutex.Unlock()
+		delete(r.subs[channel], sub)
+		if len(r.subs[channel]) == 0 {
+			delete(r.subs, channel)
+		}
+	}()
+	return sub, nil
+}
+
+// notify fans the messages of the pattern subscription out to the subscribers of their channel
+func (r *RedisCache) notify(messages <-chan *redis.Message) {
+	for message := range messages {
+		channel := strings.TrimPrefix(message.Channel, r.prefix)
+		r.subMutex.Lock()
+		for sub := range r.subs[channel] {
+			select {
+			case sub <- struct{}{}:
+			default:
+			}
+		}
+		r.subMutex.Unlock()
+	}
+}
+
+// Jobs of a queue are stored in a sorted set scored by the time they become visible in milliseconds,
+// their payloads and delivery counts in hashes. Taking a job moves its score past the visibility timeout.
+// Queues pushed with an expiry are removed at the expiry in milliseconds, the delivery counts inherit it when taken.
+var pushJobScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[2])
+redis.call('ZADD', KEYS[1], now, ARGV[1])
+local expiresAt = tonumber(ARGV[3])
+if expiresAt > 0 then
+	redis.call('PEXPIREAT', KEYS[1], expiresAt)
+	redis.call('PEXPIREAT', KEYS[2], expiresAt)
+	redis.call('PEXPIREAT', KEYS[3], expiresAt)
+end
+return 1
+`)
+
+var popJobScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', now, 'LIMIT', 0, 1)
+if #ids == 0 then
+	return false
+end
+
+local id = ids[1]
+redis.call('ZADD', KEYS[1], now + tonumber(ARGV[1]), id)
+local deliveries = redis.call('HINCRBY', KEYS[3], id, 1)
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[3], ttl)
+end
+return {id, redis.call('HGET', KEYS[2], id), deliveries}
+`)
+
+func (r *RedisCache) jobKeys(queue string) []string {
+	key := r.prefix + queue
+	return []string{key, key + ":payloads", key + ":deliveries"}
+}
+
+func (r *RedisCache) Push(ctx context.Context, queue string, id string, payload []byte, expiresAt time.Time) error {
+	var expiry int64
+	if !expiresAt.IsZero() {
+		expiry = expiresAt.UnixMilli()
+	}
+	err := pushJobScript.Run(ctx, r.client, r.jobKeys(queue), id, payload, expiry).Err()
+	if err != nil {
+		slog.Error("Error pushing job to Redis", "queue", queue, "id", id, "error", err)
+	}
+	return err
+}
+
+func (r *RedisCache) Pop(ctx context.Context, queue string, visibility time.Duration) (interfaces.Job, bool, error) {
+	result, err := popJobScript.Run(ctx, r.client, r.jobKeys(queue), visibility.Milliseconds()).Slice()
+	if err == redis.Nil {
+		return interfaces.Job{}, false, nil
+	}
+	if err != nil {
+		slog.Error("Error popping job from Redis", "queue", queue, "error", err)
+		return interfaces.Job{}, false, err
+	}
+	if len(result) != 3 {
+		return interfaces.Job{}, false, fmt.Errorf("unexpected job from Redis: %v", result)
+	}
+
+	id, _ := result[0].(string)
+	payload, _ := result[1].(string)
+	deliveries, _ := result[2].(int64)
+	return interfaces.Job{ID: id, Payload: []byte(payload), Deliveries: int(deliveries)}, true, nil
+}
+
+func (r *RedisCache) Ack(ctx context.Context, queue string, id string) error {
+	keys := r.jobKeys(queue)
+	pipe := r.client.TxPipeline()
+	pipe.ZRem(ctx, keys[0], id)
+	pipe.HDel(ctx, keys[1], id)
+	pipe.HDel(ctx, keys[2], id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Error("Error acknowledging job in Redis", "queue", queue, "id", id, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (r *RedisCache) DeleteQueue(ctx context.Context, queue string) error {
+	if err := r.client.Del(ctx, r.jobKeys(queue)...).Err(); err != nil {
+		slog.Error("Error deleting job queue from Redis", "queue", queue, "error", err)
+		return err
+	}
+	return nil
+}
+
 var _ interfaces.Cache = (*RedisCache)(nil)
+var _ interfaces.RateLimitStore = (*RedisCache)(nil)
+var _ interfaces.LeaseStore = (*RedisCache)(nil)
+var _ interfaces.JobQueue = (*RedisCache)(nil)
+var _ interfaces.QueueCache = (*RedisCache)(nil)
+var _ interfaces.Notifier = (*RedisCache)(nil)
@@ -0,0 +1,129 @@
+// Package checkpoint serializes the state of running queries, so that another instance
+// can continue a query if the instance running it shuts down or crashes.
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	i "github.com/rotmanjanez/check24-gendev-7/pkg/interfaces"
+	m "github.com/rotmanjanez/check24-gendev-7/pkg/models"
+)
+
+// Provider is the state of a single provider of a query
+type Provider struct {
+	Status m.ProviderStatus `json:"status"`
+
+	// Prepared is set once PrepareRequest returned, otherwise the provider is started from scratch
+	Prepared bool `json:"prepared"`
+
+	// Done is set once the provider finished, it is not resumed
+	Done bool `json:"done"`
+
+	// Pending are the requests that were not answered yet
+	Pending []Request `json:"pending,omitempty"`
+}
+
+// Query is the state of a running query needed to continue it
+type Query struct {
+	// Cursor is the cursor the query was initiated with
+	Cursor string `json:"cursor"`
+
+	Address   m.Address  `json:"address"`
+	Deadline  time.Time  `json:"deadline"`
+	Providers []Provider `json:"providers"`
+}
+
+func (q Query) MarshalBinary() ([]byte, error) {
+	return json.Marshal(q)
+}
+
+func (q *Query) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, q)
+}
+
+const keyPrefix = "checkpoint:"
+const leaseGroup = "checkpoint-leases"
+
+// Store keeps the checkpoints of running queries.
+// Every checkpoint is leased by the instance running the query. Checkpoints whose lease expired,
+// because the instance crashed or handed the query over, can be claimed by any instance.
+type Store struct {
+	cache  i.Cache
+	leases i.LeaseStore
+	owner  string
+	ttl    time.Duration
+}
+
+// NewStore creates a store for owner, keeping checkpoints in cache.
+// Leases expire if they are not renewed by saving a checkpoint within ttl.
+// It returns nil if cache does not support leases.
+func NewStore(cache i.Cache, owner string, ttl time.Duration) *Store {
+	leases, ok := cache.(i.LeaseStore)
+	if !ok {
+		return nil
+	}
+	return &Store{cache: cache, leases: leases, owner: owner, ttl: ttl}
+}
+
+// Save stores q and renews the lease on it.
+// It returns false if the query was claimed by another instance in the meantime.
+func (s *Store) Save(ctx context.Context, q *Query) (bool, error) {
+	ok, err := s.leases.Lease(ctx, leaseGroup, q.Cursor, s.owner, s.ttl)
+	if err != nil || !ok {
+		return false, err
+	}
+	// checkpoints outlive their lease, so that they can be claimed
+	if err := s.cache.Set(ctx, keyPrefix+q.Cursor, q, time.Until(q.Deadline)+time.Hour); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Release hands the query with cursor over to any instance claiming it
+func (s *Store) Release(ctx context.Context, cursor string) error {
+	return s.leases.Release(ctx, leaseGroup, cursor, s.owner)
+}
+
+// Delete removes the checkpoint of a finished query
+func (s *Store) Delete(ctx context.Context, cursor string) error {
+	if err := s.leases.Remove(ctx, leaseGroup, cursor, s.owner); err != nil {
+		return err
+	}
+	return s.cache.Delete(ctx, keyPrefix+cursor)
+}
+
+// Claim leases up to limit queries whose lease expired and returns their checkpoints.
+// Checkpoints that vanished are dropped.
+func (s *Store) Claim(ctx context.Context, limit int) ([]*Query, error) {
+	expired, err := s.leases.Expired(ctx, leaseGroup, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var claimed []*Query
+	for _, cursor := range expired {
+		ok, err := s.leases.Lease(ctx, leaseGroup, cursor, s.owner, s.ttl)
+		if err != nil {
+			return claimed, err
+		}
+		if !ok {
+			// claimed by another instance first
+			continue
+		}
+
+		q := new(Query)
+		exists, err := s.cache.Get(ctx, keyPrefix+cursor, q)
+		if err != nil || !exists {
+			slog.Warn("Dropping orphaned query without checkpoint", "cursor", cursor, "error", err)
+			if err := s.leases.Remove(ctx, leaseGroup, cursor, s.owner); err != nil {
+				return claimed, err
+			}
+			continue
+		}
+		claimed = append(claimed, q)
+	}
+	return claimed, nil
+}
@@ -0,0 +1,160 @@
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rotmanjanez/check24-gendev-7/pkg/cache"
+	i "github.com/rotmanjanez/check24-gendev-7/pkg/interfaces"
+)
+
+type testMetadata struct {
+	Page  int
+	Token string
+}
+
+func init() {
+	RegisterJSON[testMetadata]("checkpoint.testMetadata")
+}
+
+type testAdapter struct{}
+
+func (testAdapter) PrepareRequest(ctx context.Context, req i.Request) (i.ParsedResponse, error) {
+	return i.ParsedResponse{}, nil
+}
+
+func (testAdapter) ParseResponse(ctx context.Context, resp i.Response) (i.ParsedResponse, error) {
+	return i.ParsedResponse{}, nil
+}
+
+func (testAdapter) Name() string { return "test" }
+
+func lookup(name string) i.ProviderAdapter {
+	if name == "test" {
+		return testAdapter{}
+	}
+	return nil
+}
+
+func TestEncodeDecode(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/offers?page=2", strings.NewReader("street;1"))
+	req.Header.Set("Accept", "application/json")
+	prepared := i.PreparedRequest{Request: req, Callback: testAdapter{}, Metadata: testMetadata{Page: 2, Token: "abc"}}
+
+	encoded, err := Encode(prepared, 3)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	// the body can still be sent after encoding
+	if body, _ := io.ReadAll(req.Body); string(body) != "street;1" {
+		t.Errorf("expected body to be unread, got %q", body)
+	}
+
+	data, err := (&Query{Providers: []Provider{{Pending: []Request{encoded}}}}).MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	query := new(Query)
+	if err := query.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+
+	decoded, err := query.Providers[0].Pending[0].Decode(context.Background(), lookup)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	body, _ := io.ReadAll(decoded.Request.Body)
+	if decoded.Request.Method != http.MethodPost || decoded.Request.URL.String() != req.URL.String() ||
+		decoded.Request.Header.Get("Accept") != "application/json" || string(body) != "street;1" {
+		t.Errorf("expected the original request, got %s %s %v %q", decoded.Request.Method, decoded.Request.URL, decoded.Request.Header, body)
+	}
+	if decoded.Callback.Name() != "test" {
+		t.Errorf("expected callback test, got %s", decoded.Callback.Name())
+	}
+	if metadata, ok := decoded.Metadata.(testMetadata); !ok || metadata != (testMetadata{Page: 2, Token: "abc"}) {
+		t.Errorf("expected original metadata, got %#v", decoded.Metadata)
+	}
+	if query.Providers[0].Pending[0].Depth != 3 {
+		t.Errorf("expected depth 3, got %d", query.Providers[0].Pending[0].Depth)
+	}
+}
+
+func TestEncodeBuiltinMetadata(t *testing.T) {
+	for _, metadata := range []any{nil, "id", uint(7)} {
+		req, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+		encoded, err := Encode(i.PreparedRequest{Request: req, Callback: testAdapter{}, Metadata: metadata}, 0)
+		if err != nil {
+			t.Fatalf("Encode failed for %#v: %v", metadata, err)
+		}
+		decoded, err := encoded.Decode(context.Background(), lookup)
+		if err != nil {
+			t.Fatalf("Decode failed for %#v: %v", metadata, err)
+		}
+		if decoded.Metadata != metadata {
+			t.Errorf("expected metadata %#v, got %#v", metadata, decoded.Metadata)
+		}
+	}
+}
+
+func TestEncodeUnregisteredMetadata(t *testing.T) {
+	type unregistered struct{}
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	_, err := Encode(i.PreparedRequest{Request: req, Callback: testAdapter{}, Metadata: unregistered{}}, 0)
+
+	var unregisteredErr *UnregisteredMetadataError
+	if !errors.As(err, &unregisteredErr) {
+		t.Errorf("expected UnregisteredMetadataError, got %v", err)
+	}
+}
+
+func TestDecodeUnknownCallback(t *testing.T) {
+	if _, err := (Request{Method: http.MethodGet, URL: "https://example.com", Callback: "gone"}).Decode(context.Background(), lookup); err == nil {
+		t.Errorf("expected error for unknown callback")
+	}
+}
+
+func TestStoreClaim(t *testing.T) {
+	ctx := context.Background()
+	queue := cache.NewInstanceCache("test-queue")
+	running := NewStore(queue, "running", 20*time.Millisecond)
+	other := NewStore(queue, "other", time.Second)
+
+	query := &Query{Cursor: "cursor", Deadline: time.Now().Add(time.Minute)}
+	if ok, err := running.Save(ctx, query); err != nil || !ok {
+		t.Fatalf("Save failed: %v, %v", ok, err)
+	}
+	if claimed, _ := other.Claim(ctx, 10); len(claimed) != 0 {
+		t.Fatalf("expected running query not to be claimed, got %v", claimed)
+	}
+
+	// the running instance crashed and stopped renewing its lease
+	time.Sleep(30 * time.Millisecond)
+	claimed, err := other.Claim(ctx, 10)
+	if err != nil || len(claimed) != 1 || claimed[0].Cursor != "cursor" {
+		t.Fatalf("expected orphaned query to be claimed, got %v, %v", claimed, err)
+	}
+	if ok, _ := running.Save(ctx, query); ok {
+		t.Errorf("expected claimed query not to be saved by its former owner")
+	}
+
+	// released queries are claimed immediately
+	if err := other.Release(ctx, "cursor"); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if claimed, _ := running.Claim(ctx, 10); len(claimed) != 1 {
+		t.Fatalf("expected released query to be claimed, got %v", claimed)
+	}
+
+	if err := running.Delete(ctx, "cursor"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if claimed, _ := other.Claim(ctx, 10); len(claimed) != 0 {
+		t.Errorf("expected deleted query not to be claimed, got %v", claimed)
+	}
+}
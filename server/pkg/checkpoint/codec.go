@@ -0,0 +1,133 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Codec converts the metadata of prepared requests of type T to bytes and back
+type Codec[T any] interface {
+	Encode(metadata T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+// JSONCodec encodes metadata with encoding/json, only exported fields are kept
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Encode(metadata T) ([]byte, error) {
+	return json.Marshal(metadata)
+}
+
+func (JSONCodec[T]) Decode(data []byte) (T, error) {
+	var metadata T
+	err := json.Unmarshal(data, &metadata)
+	return metadata, err
+}
+
+// UnregisteredMetadataError is returned for metadata whose type has no registered codec
+type UnregisteredMetadataError struct {
+	Type string
+}
+
+func (e *UnregisteredMetadataError) Error() string {
+	return fmt.Sprintf("no codec registered for metadata of type %s", e.Type)
+}
+
+type registeredCodec struct {
+	name   string
+	encode func(metadata any) ([]byte, error)
+	decode func(data []byte) (any, error)
+}
+
+var registry = struct {
+	mutex  sync.RWMutex
+	byName map[string]*registeredCodec
+	byType map[reflect.Type]*registeredCodec
+}{
+	byName: make(map[string]*registeredCodec),
+	byType: make(map[reflect.Type]*registeredCodec),
+}
+
+func init() {
+	RegisterJSON[string]("string")
+	RegisterJSON[int]("int")
+	RegisterJSON[uint]("uint")
+	RegisterJSON[bool]("bool")
+}
+
+// Register makes metadata of type T serializable under name.
+// Adapters register the types they use as metadata in an init function.
+// It panics if name or T are already registered, like encoding/gob.
+func Register[T any](name string, codec Codec[T]) {
+	typ := reflect.TypeFor[T]()
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if _, exists := registry.byName[name]; exists {
+		panic(fmt.Sprintf("checkpoint: metadata codec %s registered twice", name))
+	}
+	if _, exists := registry.byType[typ]; exists {
+		panic(fmt.Sprintf("checkpoint: metadata type %s registered twice", typ))
+	}
+
+	registered := &registeredCodec{
+		name: name,
+		encode: func(metadata any) ([]byte, error) {
+			return codec.Encode(metadata.(T))
+		},
+		decode: func(data []byte) (any, error) {
+			return codec.Decode(data)
+		},
+	}
+	registry.byName[name] = registered
+	registry.byType[typ] = registered
+}
+
+// RegisterJSON makes metadata of type T serializable under name with a JSONCodec
+func RegisterJSON[T any](name string) {
+	Register[T](name, JSONCodec[T]{})
+}
+
+// encodeMetadata returns the name of the codec for metadata and the encoded metadata.
+// nil metadata is encoded without a codec.
+func encodeMetadata(metadata any) (string, []byte, error) {
+	if metadata == nil {
+		return "", nil, nil
+	}
+
+	typ := reflect.TypeOf(metadata)
+	registry.mutex.RLock()
+	codec, ok := registry.byType[typ]
+	registry.mutex.RUnlock()
+	if !ok {
+		return "", nil, &UnregisteredMetadataError{Type: typ.String()}
+	}
+
+	data, err := codec.encode(metadata)
+	if err != nil {
+		return "", nil, fmt.Errorf("error encoding metadata of type %s: %w", typ, err)
+	}
+	return codec.name, data, nil
+}
+
+// decodeMetadata decodes metadata encoded with the codec called name
+func decodeMetadata(name string, data []byte) (any, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	registry.mutex.RLock()
+	codec, ok := registry.byName[name]
+	registry.mutex.RUnlock()
+	if !ok {
+		return nil, &UnregisteredMetadataError{Type: name}
+	}
+
+	metadata, err := codec.decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding metadata %s: %w", name, err)
+	}
+	return metadata, nil
+}
@@ -0,0 +1,104 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	i "github.com/rotmanjanez/check24-gendev-7/pkg/interfaces"
+)
+
+// Request is the serializable form of a pending i.PreparedRequest.
+// Requests are stored unsigned, credentials are added by the instance sending them.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+
+	// Callback is the name of the adapter parsing the response
+	Callback string `json:"callback"`
+
+	// MetadataCodec is the name the type of the metadata was registered with
+	MetadataCodec string `json:"metadataCodec,omitempty"`
+	Metadata      []byte `json:"metadata,omitempty"`
+
+	// Depth is the follow-up level of the request, zero for requests returned by PrepareRequest
+	Depth int `json:"depth"`
+}
+
+// Encode converts prepared to its serializable form.
+// The body of the request is read with GetBody, so prepared can still be sent afterwards.
+func Encode(prepared i.PreparedRequest, depth int) (Request, error) {
+	req := prepared.Request
+	if req == nil {
+		return Request{}, fmt.Errorf("prepared request without http request")
+	}
+	if prepared.Callback == nil {
+		return Request{}, fmt.Errorf("prepared request without callback")
+	}
+
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return Request{}, fmt.Errorf("body of %s %s cannot be read without consuming it", req.Method, req.URL.Redacted())
+		}
+		reader, err := req.GetBody()
+		if err != nil {
+			return Request{}, fmt.Errorf("error reading request body: %w", err)
+		}
+		defer reader.Close()
+		if body, err = io.ReadAll(reader); err != nil {
+			return Request{}, fmt.Errorf("error reading request body: %w", err)
+		}
+	}
+
+	codec, metadata, err := encodeMetadata(prepared.Metadata)
+	if err != nil {
+		return Request{}, err
+	}
+
+	return Request{
+		Method:        req.Method,
+		URL:           req.URL.String(),
+		Header:        req.Header.Clone(),
+		Body:          body,
+		Callback:      prepared.Callback.Name(),
+		MetadataCodec: codec,
+		Metadata:      metadata,
+		Depth:         depth,
+	}, nil
+}
+
+// Decode rebuilds the prepared request, looking up its callback by name with adapter
+func (r Request) Decode(ctx context.Context, adapter func(name string) i.ProviderAdapter) (i.PreparedRequest, error) {
+	callback := adapter(r.Callback)
+	if callback == nil {
+		return i.PreparedRequest{}, fmt.Errorf("unknown callback adapter %s", r.Callback)
+	}
+
+	metadata, err := decodeMetadata(r.MetadataCodec, r.Metadata)
+	if err != nil {
+		return i.PreparedRequest{}, err
+	}
+
+	var body io.Reader
+	if r.Body != nil {
+		body = bytes.NewReader(r.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.Method, r.URL, body)
+	if err != nil {
+		return i.PreparedRequest{}, fmt.Errorf("error rebuilding request: %w", err)
+	}
+	if r.Header != nil {
+		req.Header = r.Header.Clone()
+	}
+
+	return i.PreparedRequest{
+		Request:  req,
+		Callback: callback,
+		Metadata: metadata,
+	}, nil
+}
@@ -16,6 +16,91 @@ type Cache interface {
 	Persist(ctx context.Context, key string) error
 }
 
+// RateLimitStore is implemented by caches that support atomic counters and token buckets.
+// Implementations backed by a shared store enforce limits across all instances.
+type RateLimitStore interface {
+	// Increment atomically increments the counter key by one and returns the new value.
+	// The ttl is set when the counter is created.
+	Increment(ctx context.Context, key string, ttl time.Duration) (int64, error)
+
+	// TakeToken atomically refills the token bucket key with rate tokens per second up to burst tokens
+	// and takes a token. It returns 0 if a token was taken, otherwise the time until the next token is available.
+	TakeToken(ctx context.Context, key string, rate float64, burst int) (time.Duration, error)
+}
+
+// LeaseStore is implemented by caches that hand out leases on the keys of a group that expire unless renewed.
+// Implementations backed by a shared store allow instances to take over the work of a crashed instance.
+type LeaseStore interface {
+	// Lease acquires or renews the lease of owner on key in group for ttl.
+	// It returns false if the key is leased by another owner whose lease has not expired.
+	Lease(ctx context.Context, group string, key string, owner string, ttl time.Duration) (bool, error)
+
+	// Release expires the lease of owner on key immediately, so that any owner can lease it.
+	Release(ctx context.Context, group string, key string, owner string) error
+
+	// Remove removes key from group if it is leased by owner.
+	Remove(ctx context.Context, group string, key string, owner string) error
+
+	// Expired returns up to limit keys of group whose lease expired.
+	Expired(ctx context.Context, group string, limit int) ([]string, error)
+}
+
+// LogStore is implemented by caches that keep append-only logs
+type LogStore interface {
+	// Append adds values to the end of the log key and refreshes its ttl.
+	// It returns the length of the log afterwards.
+	Append(ctx context.Context, key string, ttl time.Duration, values ...encoding.BinaryMarshaler) (int64, error)
+
+	// Range returns the entries of the log key from offset on, at most limit entries if limit is positive.
+	// A missing log is empty.
+	Range(ctx context.Context, key string, offset int64, limit int64) ([][]byte, error)
+}
+
+// QueueCache is the cache shared by all instances to coordinate running queries
+type QueueCache interface {
+	Cache
+	LogStore
+}
+
+// Notifier is implemented by caches that broadcast notifications to all instances.
+// Notifications are not persisted, subscribers only receive those published while they are subscribed.
+type Notifier interface {
+	// Publish notifies all subscribers of channel
+	Publish(ctx context.Context, channel string) error
+
+	// Subscribe returns a channel that receives a value whenever channel was notified until ctx is done.
+	// Notifications are coalesced, a receiver only learns that at least one notification was published.
+	// The subscription is active once Subscribe returned.
+	Subscribe(ctx context.Context, channel string) (<-chan struct{}, error)
+}
+
+// Job is a message taken from a JobQueue
+type Job struct {
+	ID      string
+	Payload []byte
+	// Deliveries is the number of times the job was taken, including this one
+	Deliveries int
+}
+
+// JobQueue is implemented by caches that provide reliable queues with visibility timeouts.
+// A job taken from a queue is hidden until it is acknowledged or its visibility timeout passed,
+// so jobs of crashed consumers are delivered again.
+type JobQueue interface {
+	// Push appends a job with a unique id to queue.
+	// Unless expiresAt is zero, the whole queue is removed at expiresAt.
+	Push(ctx context.Context, queue string, id string, payload []byte, expiresAt time.Time) error
+
+	// Pop takes the oldest visible job of queue and hides it for visibility.
+	// It returns false if no job is visible.
+	Pop(ctx context.Context, queue string, visibility time.Duration) (Job, bool, error)
+
+	// Ack removes a processed job from queue
+	Ack(ctx context.Context, queue string, id string) error
+
+	// DeleteQueue removes queue with all of its jobs
+	DeleteQueue(ctx context.Context, queue string) error
+}
+
 type CacheFactory interface {
 	Create(name string) (Cache, error)
 }
@@ -1,6 +1,9 @@
 package interfaces
 
-import "context"
+import (
+	"context"
+	"net/http"
+)
 
 type ProviderAdapter interface {
 	// Converts a Request into
@@ -27,3 +30,13 @@ type ProviderAdapter interface {
 	// Returns the name of the provider.
 	Name() string
 }
+
+// RequestRefresher is an optional interface of a ProviderAdapter whose requests have to be rebuilt before they are sent,
+// e.g. because their body embeds a timestamp or nonce.
+// If an adapter does not implement it, the runtime replays the body of a request with http.Request.GetBody on retries.
+// Configured signers are applied to the rebuilt request afterwards.
+type RequestRefresher interface {
+	// Returns the request to send for the given zero based attempt of a request returned by PrepareRequest or ParseResponse.
+	// RefreshRequest is called before every attempt, including the first one. The returned request must have an unread body.
+	RefreshRequest(ctx context.Context, request PreparedRequest, attempt int) (*http.Request, error)
+}
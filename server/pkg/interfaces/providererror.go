@@ -0,0 +1,64 @@
+package interfaces
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// ErrorPhase is the step of a provider query in which an error occurred
+type ErrorPhase string
+
+const (
+	// PhasePrepare covers PrepareRequest and checks before any request is sent
+	PhasePrepare ErrorPhase = "prepare"
+	// PhaseCircuitOpen covers providers and requests skipped because the circuit breaker is open
+	PhaseCircuitOpen ErrorPhase = "circuit_open"
+	// PhaseTransport covers errors sending a request or waiting for its response
+	PhaseTransport ErrorPhase = "transport"
+	// PhaseStatus covers responses with an unexpected HTTP status code
+	PhaseStatus ErrorPhase = "status"
+	// PhaseParse covers ParseResponse
+	PhaseParse ErrorPhase = "parse"
+	// PhaseValidation covers products that do not satisfy the model constraints
+	PhaseValidation ErrorPhase = "validation"
+	// PhaseBudget covers providers that exceeded their fan-out budget for a query
+	PhaseBudget ErrorPhase = "budget"
+)
+
+// ProviderError describes a failure while querying a single provider.
+// It wraps the underlying cause so callers can still match it with errors.Is and errors.As.
+type ProviderError struct {
+	// Name of the provider adapter
+	Provider string
+	Phase    ErrorPhase
+	// Zero based attempt of the request, 0 if no request was involved
+	Attempt int
+	// HTTP status code of the response, 0 if no response was received
+	StatusCode int
+	// Whether the failed call could succeed if it was issued again
+	Retryable bool
+	Err       error
+}
+
+func (e *ProviderError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("provider %s: %s failed (attempt %d, status %d): %v", e.Provider, e.Phase, e.Attempt, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("provider %s: %s failed (attempt %d): %v", e.Provider, e.Phase, e.Attempt, e.Err)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// LogValue groups the fields of the error for structured logging
+func (e *ProviderError) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("provider", e.Provider),
+		slog.String("phase", string(e.Phase)),
+		slog.Int("attempt", e.Attempt),
+		slog.Int("statusCode", e.StatusCode),
+		slog.Bool("retryable", e.Retryable),
+		slog.Any("cause", e.Err),
+	)
+}
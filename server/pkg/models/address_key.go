@@ -0,0 +1,35 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// CanonicalizeAddress returns a normalized form of the address that is equal for
+// different spellings of the same address, e.g. "Hauptstr. 1" and "hauptstrasse  1".
+// It is meant for comparisons and cache keys, not for sending to providers.
+func CanonicalizeAddress(obj Address) Address {
+	normalize := func(s string) string {
+		s = strings.ToLower(strings.Join(strings.Fields(s), " "))
+		return strings.ReplaceAll(s, "ß", "ss")
+	}
+
+	obj.Street = normalize(obj.Street)
+	if strings.HasSuffix(obj.Street, "str.") {
+		obj.Street = strings.TrimSuffix(obj.Street, "str.") + "strasse"
+	}
+	obj.HouseNumber = strings.ReplaceAll(normalize(obj.HouseNumber), " ", "")
+	obj.City = normalize(obj.City)
+	obj.PostalCode = strings.ReplaceAll(obj.PostalCode, " ", "")
+	obj.CountryCode = CountryCode(strings.ToUpper(strings.TrimSpace(string(obj.CountryCode))))
+	return obj
+}
+
+// AddressKey returns a stable key for the canonical form of the address
+func AddressKey(obj Address) string {
+	data, _ := json.Marshal(CanonicalizeAddress(obj))
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
@@ -31,6 +31,12 @@ type InternetProduct struct {
 	ProductInfo ProductInfo `json:"productInfo"`
 
 	Pricing Pricing `json:"pricing"`
+
+	// Whether the product arrived after its provider missed the soft deadline of the query
+	Late bool `json:"late,omitempty"`
+
+	// Cost over the contract computed from the pricing
+	Cost *ProductCost `json:"cost,omitempty"`
 }
 
 // AssertInternetProductRequired checks if the required fields are not zero-ed
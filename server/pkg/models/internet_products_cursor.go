@@ -10,6 +10,11 @@
 
 package models
 
+import (
+	"encoding"
+	"encoding/json"
+)
+
 // InternetProductsCursor - Response containing a list of internet products and version info
 type InternetProductsCursor struct {
 	Version string `json:"version,omitempty"`
@@ -27,3 +32,17 @@ func AssertInternetProductsCursorRequired(obj InternetProductsCursor) error {
 func AssertInternetProductsCursorConstraints(obj InternetProductsCursor) error {
 	return nil
 }
+
+// Implementation of the BinaryMarshaller interface
+func (obj InternetProductsCursor) MarshalBinary() ([]byte, error) {
+	return json.Marshal(obj)
+}
+
+// Implementation of the BinaryUnmarshaller interface
+func (obj *InternetProductsCursor) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, obj)
+}
+
+// Ensure that InternetProductsCursor implements the BinaryMarshaler and BinaryUnmarshaler interfaces
+var _ encoding.BinaryMarshaler = (*InternetProductsCursor)(nil)
+var _ encoding.BinaryUnmarshaler = (*InternetProductsCursor)(nil)
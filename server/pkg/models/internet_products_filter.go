@@ -0,0 +1,61 @@
+package models
+
+import (
+	"errors"
+)
+
+// InternetProductsFilter - Filter and sort order applied to a list of internet products, unset fields match all products
+type InternetProductsFilter struct {
+
+	// Minimum speed in Mbps
+	MinSpeed *int32 `json:"minSpeed,omitempty"`
+
+	// Maximum monthly cost in cent
+	MaxMonthlyCost *int32 `json:"maxMonthlyCost,omitempty"`
+
+	// Connection types of which one must be offered
+	ConnectionTypes []ConnectionType `json:"connectionTypes,omitempty"`
+
+	// Longest accepted contract commitment in months
+	MaxContractDuration *int32 `json:"maxContractDuration,omitempty"`
+
+	// Whether TV must be included or excluded
+	TvIncluded *bool `json:"tvIncluded,omitempty"`
+
+	// Whether the installation service must be included or excluded
+	InstallationIncluded *bool `json:"installationIncluded,omitempty"`
+
+	// Providers whose products are included
+	Provider []string `json:"provider,omitempty"`
+
+	Sort InternetProductsSortOrder `json:"sort,omitempty"`
+}
+
+// AssertInternetProductsFilterRequired checks if the required fields are not zero-ed
+func AssertInternetProductsFilterRequired(obj InternetProductsFilter) error {
+	return nil
+}
+
+// AssertInternetProductsFilterConstraints checks if the values respects the defined constraints
+func AssertInternetProductsFilterConstraints(obj InternetProductsFilter) error {
+	if obj.MinSpeed != nil && *obj.MinSpeed < 0 {
+		return &ParsingError{Param: "minSpeed", Err: errors.New(ErrMsgMinValueConstraint)}
+	}
+	if obj.MaxMonthlyCost != nil && *obj.MaxMonthlyCost < 0 {
+		return &ParsingError{Param: "maxMonthlyCost", Err: errors.New(ErrMsgMinValueConstraint)}
+	}
+	if obj.MaxContractDuration != nil && *obj.MaxContractDuration < 0 {
+		return &ParsingError{Param: "maxContractDuration", Err: errors.New(ErrMsgMinValueConstraint)}
+	}
+	for _, connectionType := range obj.ConnectionTypes {
+		if _, err := NewConnectionTypeFromValue(string(connectionType)); err != nil {
+			return &ParsingError{Param: "connectionTypes", Err: err}
+		}
+	}
+	if obj.Sort != "" {
+		if _, err := NewInternetProductsSortOrderFromValue(string(obj.Sort)); err != nil {
+			return &ParsingError{Param: "sort", Err: err}
+		}
+	}
+	return nil
+}
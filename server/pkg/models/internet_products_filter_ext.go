@@ -0,0 +1,13 @@
+package models
+
+// IsZero returns true if the filter matches all products and keeps their order
+func (obj InternetProductsFilter) IsZero() bool {
+	return obj.MinSpeed == nil &&
+		obj.MaxMonthlyCost == nil &&
+		len(obj.ConnectionTypes) == 0 &&
+		obj.MaxContractDuration == nil &&
+		obj.TvIncluded == nil &&
+		obj.InstallationIncluded == nil &&
+		len(obj.Provider) == 0 &&
+		obj.Sort == ""
+}
@@ -0,0 +1,35 @@
+package models
+
+// InternetProductsQueryStatus - Progress of all providers of a query
+type InternetProductsQueryStatus struct {
+	Providers []ProviderStatus `json:"providers"`
+
+	// True once all providers reached a terminal state
+	Complete bool `json:"complete"`
+
+	// True once all providers finished or missed their soft deadline
+	Partial bool `json:"partial"`
+
+	// True if the query was cancelled or abandoned before all providers finished
+	Cancelled bool `json:"cancelled,omitempty"`
+}
+
+// AssertInternetProductsQueryStatusRequired checks if the required fields are not zero-ed
+func AssertInternetProductsQueryStatusRequired(obj InternetProductsQueryStatus) error {
+	for _, el := range obj.Providers {
+		if err := AssertProviderStatusRequired(el); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AssertInternetProductsQueryStatusConstraints checks if the values respects the defined constraints
+func AssertInternetProductsQueryStatusConstraints(obj InternetProductsQueryStatus) error {
+	for _, el := range obj.Providers {
+		if err := AssertProviderStatusConstraints(el); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"encoding"
+	"encoding/json"
+)
+
+// Implementation of the BinaryMarshaller interface
+func (obj InternetProductsQueryStatus) MarshalBinary() ([]byte, error) {
+	return json.Marshal(obj)
+}
+
+// Implementation of the BinaryUnmarshaller interface
+func (obj *InternetProductsQueryStatus) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, obj)
+}
+
+// Ensure that InternetProductsQueryStatus implements the BinaryMarshaler and BinaryUnmarshaler interfaces
+var _ encoding.BinaryMarshaler = (*InternetProductsQueryStatus)(nil)
+var _ encoding.BinaryUnmarshaler = (*InternetProductsQueryStatus)(nil)
@@ -23,6 +23,15 @@ type InternetProductsResponse struct {
 
 	// Cursor to retrieve the next batch of products, or null if finished
 	NextCursor string `json:"nextCursor,omitempty"`
+
+	// True once all providers finished or missed their soft deadline, following products are late
+	Partial bool `json:"partial,omitempty"`
+
+	// True once the query finished, no further products follow
+	Complete bool `json:"complete,omitempty"`
+
+	// True if the query was cancelled or abandoned, no further products follow
+	Cancelled bool `json:"cancelled,omitempty"`
 }
 
 // AssertInternetProductsResponseRequired checks if the required fields are not zero-ed
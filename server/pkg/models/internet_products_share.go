@@ -0,0 +1,19 @@
+package models
+
+// InternetProductsShare - Link to a shared view of internet products
+type InternetProductsShare struct {
+	Version string `json:"version,omitempty"`
+
+	// Id to retrieve the shared products with their filter
+	ShareId string `json:"shareId,omitempty"`
+}
+
+// AssertInternetProductsShareRequired checks if the required fields are not zero-ed
+func AssertInternetProductsShareRequired(obj InternetProductsShare) error {
+	return nil
+}
+
+// AssertInternetProductsShareConstraints checks if the values respects the defined constraints
+func AssertInternetProductsShareConstraints(obj InternetProductsShare) error {
+	return nil
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"encoding"
+	"encoding/json"
+)
+
+// Implementation of the BinaryMarshaller interface
+func (obj InternetProductsShare) MarshalBinary() ([]byte, error) {
+	return json.Marshal(obj)
+}
+
+// Implementation of the BinaryUnmarshaller interface
+func (obj *InternetProductsShare) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, obj)
+}
+
+// Ensure that InternetProductsShare implements the BinaryMarshaler and BinaryUnmarshaler interfaces
+var _ encoding.BinaryMarshaler = (*InternetProductsShare)(nil)
+var _ encoding.BinaryUnmarshaler = (*InternetProductsShare)(nil)
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"fmt"
+)
+
+// InternetProductsSortOrder : Field and direction internet products are sorted by
+type InternetProductsSortOrder string
+
+// List of InternetProductsSortOrder
+const (
+	PRICE_ASC           InternetProductsSortOrder = "price-asc"
+	PRICE_DESC          InternetProductsSortOrder = "price-desc"
+	SPEED_ASC           InternetProductsSortOrder = "speed-asc"
+	SPEED_DESC          InternetProductsSortOrder = "speed-desc"
+	EFFECTIVE_COST_ASC  InternetProductsSortOrder = "effective-cost-asc"
+	EFFECTIVE_COST_DESC InternetProductsSortOrder = "effective-cost-desc"
+)
+
+// AllowedInternetProductsSortOrderEnumValues is all the allowed values of InternetProductsSortOrder enum
+var AllowedInternetProductsSortOrderEnumValues = []InternetProductsSortOrder{
+	"price-asc",
+	"price-desc",
+	"speed-asc",
+	"speed-desc",
+	"effective-cost-asc",
+	"effective-cost-desc",
+}
+
+// validInternetProductsSortOrderEnumValue provides a map of InternetProductsSortOrders for fast verification of use input
+var validInternetProductsSortOrderEnumValues = map[InternetProductsSortOrder]struct{}{
+	"price-asc":           {},
+	"price-desc":          {},
+	"speed-asc":           {},
+	"speed-desc":          {},
+	"effective-cost-asc":  {},
+	"effective-cost-desc": {},
+}
+
+// IsValid return true if the value is valid for the enum, false otherwise
+func (v InternetProductsSortOrder) IsValid() bool {
+	_, ok := validInternetProductsSortOrderEnumValues[v]
+	return ok
+}
+
+// NewInternetProductsSortOrderFromValue returns a pointer to a valid InternetProductsSortOrder
+// for the value passed as argument, or an error if the value passed is not allowed by the enum
+func NewInternetProductsSortOrderFromValue(v string) (InternetProductsSortOrder, error) {
+	ev := InternetProductsSortOrder(v)
+	if ev.IsValid() {
+		return ev, nil
+	}
+
+	return "", fmt.Errorf("invalid value '%v' for InternetProductsSortOrder: valid values are %v", v, AllowedInternetProductsSortOrderEnumValues)
+}
+
+// AssertInternetProductsSortOrderRequired checks if the required fields are not zero-ed
+func AssertInternetProductsSortOrderRequired(obj InternetProductsSortOrder) error {
+	return nil
+}
+
+// AssertInternetProductsSortOrderConstraints checks if the values respects the defined constraints
+func AssertInternetProductsSortOrderConstraints(obj InternetProductsSortOrder) error {
+	return nil
+}
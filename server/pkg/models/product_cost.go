@@ -0,0 +1,27 @@
+package models
+
+// ProductCost - Cost of a product over its contract, computed from its pricing
+type ProductCost struct {
+
+	// Total cost over the contract in cent, including subsequent costs and discounts
+	TotalCostInCent int64 `json:"totalCostInCent"`
+
+	// Average monthly cost over the contract in cent
+	EffectiveMonthlyCostInCent int32 `json:"effectiveMonthlyCostInCent"`
+
+	// Number of months the cost is computed for
+	DurationInMonths int32 `json:"durationInMonths"`
+
+	// Cost of every month of the contract in cent, starting with the first month
+	MonthlyCostsInCent []int32 `json:"monthlyCostsInCent"`
+}
+
+// AssertProductCostRequired checks if the required fields are not zero-ed
+func AssertProductCostRequired(obj ProductCost) error {
+	return nil
+}
+
+// AssertProductCostConstraints checks if the values respects the defined constraints
+func AssertProductCostConstraints(obj ProductCost) error {
+	return nil
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"fmt"
+)
+
+// ProviderState : State of a provider within a running query
+type ProviderState string
+
+// List of ProviderState
+const (
+	PENDING              ProviderState = "pending"
+	RUNNING              ProviderState = "running"
+	COMPLETED            ProviderState = "completed"
+	FAILED               ProviderState = "failed"
+	SKIPPED_UNSUPPORTED  ProviderState = "skipped-unsupported"
+	SKIPPED_CIRCUIT_OPEN ProviderState = "skipped-circuit-open"
+	QUOTA_EXHAUSTED      ProviderState = "quota-exhausted"
+	CANCELLED            ProviderState = "cancelled"
+)
+
+// AllowedProviderStateEnumValues is all the allowed values of ProviderState enum
+var AllowedProviderStateEnumValues = []ProviderState{
+	"pending",
+	"running",
+	"completed",
+	"failed",
+	"skipped-unsupported",
+	"skipped-circuit-open",
+	"quota-exhausted",
+	"cancelled",
+}
+
+// validProviderStateEnumValue provides a map of ProviderStates for fast verification of use input
+var validProviderStateEnumValues = map[ProviderState]struct{}{
+	"pending":              {},
+	"running":              {},
+	"completed":            {},
+	"failed":               {},
+	"skipped-unsupported":  {},
+	"skipped-circuit-open": {},
+	"quota-exhausted":      {},
+	"cancelled":            {},
+}
+
+// IsValid return true if the value is valid for the enum, false otherwise
+func (v ProviderState) IsValid() bool {
+	_, ok := validProviderStateEnumValues[v]
+	return ok
+}
+
+// NewProviderStateFromValue returns a pointer to a valid ProviderState
+// for the value passed as argument, or an error if the value passed is not allowed by the enum
+func NewProviderStateFromValue(v string) (ProviderState, error) {
+	ev := ProviderState(v)
+	if ev.IsValid() {
+		return ev, nil
+	}
+
+	return "", fmt.Errorf("invalid value '%v' for ProviderState: valid values are %v", v, AllowedProviderStateEnumValues)
+}
+
+// AssertProviderStateRequired checks if the required fields are not zero-ed
+func AssertProviderStateRequired(obj ProviderState) error {
+	return nil
+}
+
+// AssertProviderStateConstraints checks if the values respects the defined constraints
+func AssertProviderStateConstraints(obj ProviderState) error {
+	return nil
+}
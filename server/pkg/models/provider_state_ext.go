@@ -0,0 +1,6 @@
+package models
+
+// IsTerminal returns true if the provider will not make any further progress
+func (v ProviderState) IsTerminal() bool {
+	return v != PENDING && v != RUNNING
+}
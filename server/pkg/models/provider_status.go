@@ -0,0 +1,43 @@
+package models
+
+// ProviderStatus - Progress of a single provider within a query
+type ProviderStatus struct {
+	Provider string `json:"provider"`
+
+	State ProviderState `json:"state"`
+
+	// Number of HTTP requests sent to the provider, including retries
+	RequestsSent int32 `json:"requestsSent"`
+
+	// Number of retried HTTP requests
+	Retries int32 `json:"retries"`
+
+	// Number of valid products received from the provider
+	ProductCount int32 `json:"productCount"`
+
+	// Phase of the last error that occurred for this provider, see interfaces.ErrorPhase
+	LastErrorCategory string `json:"lastErrorCategory,omitempty"`
+
+	// Whether the provider was still running at its soft deadline
+	PastDeadline bool `json:"pastDeadline,omitempty"`
+}
+
+// AssertProviderStatusRequired checks if the required fields are not zero-ed
+func AssertProviderStatusRequired(obj ProviderStatus) error {
+	elements := map[string]interface{}{
+		"provider": obj.Provider,
+		"state":    obj.State,
+	}
+	for name, el := range elements {
+		if isZero := IsZeroValue(el); isZero {
+			return &RequiredError{Field: name}
+		}
+	}
+
+	return nil
+}
+
+// AssertProviderStatusConstraints checks if the values respects the defined constraints
+func AssertProviderStatusConstraints(obj ProviderStatus) error {
+	return nil
+}
@@ -0,0 +1,30 @@
+package models
+
+// ServerLoad - Utilization of the workers running internet product queries
+type ServerLoad struct {
+
+	// Number of workers running queries
+	Workers int32 `json:"workers"`
+
+	// Number of workers currently running a query
+	Busy int32 `json:"busy"`
+
+	// Number of queries waiting for a worker
+	Queued int32 `json:"queued"`
+
+	// Maximum number of queries waiting for a worker
+	QueueCapacity int32 `json:"queueCapacity"`
+
+	// Number of queries rejected because the queue was full since the server started
+	Rejected int64 `json:"rejected"`
+}
+
+// AssertServerLoadRequired checks if the required fields are not zero-ed
+func AssertServerLoadRequired(obj ServerLoad) error {
+	return nil
+}
+
+// AssertServerLoadConstraints checks if the values respects the defined constraints
+func AssertServerLoadConstraints(obj ServerLoad) error {
+	return nil
+}
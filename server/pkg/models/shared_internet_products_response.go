@@ -22,6 +22,12 @@ type SharedInternetProductsResponse struct {
 	Version string `json:"version,omitempty"`
 
 	Address Address `json:"Address,omitempty"`
+
+	// True if the query was cancelled or abandoned before all providers finished, the products are incomplete
+	Cancelled bool `json:"cancelled,omitempty"`
+
+	// Filter and sort order of the view that was shared, the products match it
+	Filter *InternetProductsFilter `json:"filter,omitempty"`
 }
 
 // AssertSharedInternetProductsResponseRequired checks if the required fields are not zero-ed
@@ -47,6 +53,11 @@ func AssertSharedInternetProductsResponseConstraints(obj SharedInternetProductsR
 	if err := AssertAddressConstraints(obj.Address); err != nil {
 		return err
 	}
+	if obj.Filter != nil {
+		if err := AssertInternetProductsFilterConstraints(*obj.Filter); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
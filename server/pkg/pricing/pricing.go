@@ -0,0 +1,102 @@
+// Package pricing computes what a product costs over its contract, so that offers with
+// different contract durations, subsequent costs and discounts can be compared.
+package pricing
+
+import (
+	"errors"
+	"fmt"
+
+	m "github.com/rotmanjanez/check24-gendev-7/pkg/models"
+)
+
+// DefaultDurationInMonths is the duration the cost of products without fixed contract duration is computed for
+const DefaultDurationInMonths = 24
+
+// MaxDurationInMonths is the longest duration costs are computed for
+const MaxDurationInMonths = 120
+
+// ErrDurationTooLong is returned for products whose contract is longer than MaxDurationInMonths
+var ErrDurationTooLong = errors.New("contract duration too long")
+
+// Duration returns the number of months the cost of a product with pricing is computed for.
+// This is the contract duration if it is set, otherwise the minimum contract duration
+// but at least DefaultDurationInMonths.
+func Duration(pricing m.Pricing) int32 {
+	if pricing.ContractDurationInMonths != nil && *pricing.ContractDurationInMonths > 0 {
+		return *pricing.ContractDurationInMonths
+	}
+	if pricing.MinContractDurationInMonths != nil {
+		return max(*pricing.MinContractDurationInMonths, DefaultDurationInMonths)
+	}
+	return DefaultDurationInMonths
+}
+
+// Calculate returns the cost of a product with pricing over its contract.
+//
+// The monthly cost is charged until the subsequent costs start in their StartMonth, counted from 1.
+// The percentage discount reduces every month of its duration, or of the whole contract without duration,
+// until its maximum discount is reached. The absolute discount is granted once if the order value,
+// the cost over the contract before discounts, reaches its minimum order value. It is credited to the
+// first months, no month costs less than zero.
+// Contracts longer than MaxDurationInMonths are rejected with ErrDurationTooLong.
+func Calculate(pricing m.Pricing) (m.ProductCost, error) {
+	duration := Duration(pricing)
+	if duration > MaxDurationInMonths {
+		return m.ProductCost{}, fmt.Errorf("%w: %d months, at most %d are supported", ErrDurationTooLong, duration, MaxDurationInMonths)
+	}
+	months := make([]int32, duration)
+	var orderValue int64
+	for idx := range months {
+		months[idx] = pricing.MonthlyCostInCent
+		if costs := pricing.SubsequentCosts; costs != nil && int32(idx)+1 >= costs.StartMonth {
+			months[idx] = costs.MonthlyCostInCent
+		}
+		orderValue += int64(months[idx])
+	}
+
+	if discount := pricing.PercentageDiscount; discount != nil {
+		discountMonths := duration
+		if discount.DurationInMonths != nil {
+			discountMonths = min(*discount.DurationInMonths, duration)
+		}
+		remaining := int64(-1) // no maximum
+		if discount.MaxDiscountInCent != nil {
+			remaining = int64(*discount.MaxDiscountInCent)
+		}
+		for idx := range discountMonths {
+			amount := int64(months[idx]) * int64(discount.Percentage) / 100
+			if remaining >= 0 {
+				amount = min(amount, remaining)
+				remaining -= amount
+			}
+			months[idx] -= int32(amount)
+		}
+	}
+
+	if discount := pricing.AbsoluteDiscount; discount != nil {
+		minOrderValue := int64(0)
+		if discount.MinOrderValueInCent != nil {
+			minOrderValue = int64(*discount.MinOrderValueInCent)
+		}
+		if orderValue >= minOrderValue {
+			remaining := discount.ValueInCent
+			for idx := 0; idx < len(months) && remaining > 0; idx++ {
+				amount := min(months[idx], remaining)
+				months[idx] -= amount
+				remaining -= amount
+			}
+		}
+	}
+
+	var total int64
+	for _, cost := range months {
+		total += int64(cost)
+	}
+	return m.ProductCost{
+		TotalCostInCent: total,
+		// rounded to the nearest cent
+		EffectiveMonthlyCostInCent: int32((total + int64(duration)/2) / int64(duration)),
+		DurationInMonths:           duration,
+		MonthlyCostsInCent:         months,
+	}, nil
+}
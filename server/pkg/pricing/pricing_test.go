@@ -0,0 +1,240 @@
+package pricing
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	m "github.com/rotmanjanez/check24-gendev-7/pkg/models"
+)
+
+func ptr(v int32) *int32 { return &v }
+
+// repeat returns a schedule of n months costing cost each
+func repeat(cost int32, n int) []int32 {
+	return slices.Repeat([]int32{cost}, n)
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		pricing  m.Pricing
+		expected int32
+	}{
+		{"contract duration", m.Pricing{ContractDurationInMonths: ptr(12)}, 12},
+		{"contract duration over min duration", m.Pricing{ContractDurationInMonths: ptr(12), MinContractDurationInMonths: ptr(24)}, 12},
+		{"zero contract duration", m.Pricing{ContractDurationInMonths: ptr(0)}, DefaultDurationInMonths},
+		{"short min duration", m.Pricing{MinContractDurationInMonths: ptr(1)}, DefaultDurationInMonths},
+		{"long min duration", m.Pricing{MinContractDurationInMonths: ptr(36)}, 36},
+		{"no duration", m.Pricing{}, DefaultDurationInMonths},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Duration(tt.pricing); got != tt.expected {
+				t.Errorf("expected %d months, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name      string
+		pricing   m.Pricing
+		schedule  []int32
+		effective int32
+	}{
+		{
+			name:      "monthly cost only",
+			pricing:   m.Pricing{MonthlyCostInCent: 3000, ContractDurationInMonths: ptr(12)},
+			schedule:  repeat(3000, 12),
+			effective: 3000,
+		},
+		{
+			name: "subsequent costs start in their start month",
+			pricing: m.Pricing{
+				MonthlyCostInCent:        2000,
+				ContractDurationInMonths: ptr(6),
+				SubsequentCosts:          &m.SubsequentCost{MonthlyCostInCent: 4000, StartMonth: 4},
+			},
+			schedule:  []int32{2000, 2000, 2000, 4000, 4000, 4000},
+			effective: 3000,
+		},
+		{
+			name: "subsequent costs after the contract",
+			pricing: m.Pricing{
+				MonthlyCostInCent:        2000,
+				ContractDurationInMonths: ptr(3),
+				SubsequentCosts:          &m.SubsequentCost{MonthlyCostInCent: 4000, StartMonth: 25},
+			},
+			schedule:  repeat(2000, 3),
+			effective: 2000,
+		},
+		{
+			name: "subsequent costs from the first month",
+			pricing: m.Pricing{
+				MonthlyCostInCent:        2000,
+				ContractDurationInMonths: ptr(2),
+				SubsequentCosts:          &m.SubsequentCost{MonthlyCostInCent: 4000, StartMonth: 0},
+			},
+			schedule:  repeat(4000, 2),
+			effective: 4000,
+		},
+		{
+			name: "percentage discount over the whole contract",
+			pricing: m.Pricing{
+				MonthlyCostInCent:        3000,
+				ContractDurationInMonths: ptr(4),
+				PercentageDiscount:       &m.PercentageDiscount{Percentage: 10},
+			},
+			schedule:  repeat(2700, 4),
+			effective: 2700,
+		},
+		{
+			name: "percentage discount for some months",
+			pricing: m.Pricing{
+				MonthlyCostInCent:        3000,
+				ContractDurationInMonths: ptr(4),
+				PercentageDiscount:       &m.PercentageDiscount{Percentage: 50, DurationInMonths: ptr(2)},
+			},
+			schedule:  []int32{1500, 1500, 3000, 3000},
+			effective: 2250,
+		},
+		{
+			name: "percentage discount longer than the contract",
+			pricing: m.Pricing{
+				MonthlyCostInCent:        3000,
+				ContractDurationInMonths: ptr(2),
+				PercentageDiscount:       &m.PercentageDiscount{Percentage: 50, DurationInMonths: ptr(12)},
+			},
+			schedule:  repeat(1500, 2),
+			effective: 1500,
+		},
+		{
+			name: "percentage discount capped by its maximum",
+			pricing: m.Pricing{
+				MonthlyCostInCent:        3000,
+				ContractDurationInMonths: ptr(4),
+				PercentageDiscount:       &m.PercentageDiscount{Percentage: 50, MaxDiscountInCent: ptr(2000)},
+			},
+			schedule:  []int32{1500, 2500, 3000, 3000},
+			effective: 2500,
+		},
+		{
+			name: "percentage discount of the subsequent costs",
+			pricing: m.Pricing{
+				MonthlyCostInCent:        2000,
+				ContractDurationInMonths: ptr(2),
+				SubsequentCosts:          &m.SubsequentCost{MonthlyCostInCent: 4000, StartMonth: 2},
+				PercentageDiscount:       &m.PercentageDiscount{Percentage: 25},
+			},
+			schedule:  []int32{1500, 3000},
+			effective: 2250,
+		},
+		{
+			name: "absolute discount credited to the first month",
+			pricing: m.Pricing{
+				MonthlyCostInCent:        3000,
+				ContractDurationInMonths: ptr(3),
+				AbsoluteDiscount:         &m.AbsoluteDiscount{ValueInCent: 1000},
+			},
+			schedule:  []int32{2000, 3000, 3000},
+			effective: 2667,
+		},
+		{
+			name: "absolute discount larger than a month",
+			pricing: m.Pricing{
+				MonthlyCostInCent:        3000,
+				ContractDurationInMonths: ptr(3),
+				AbsoluteDiscount:         &m.AbsoluteDiscount{ValueInCent: 4000},
+			},
+			schedule:  []int32{0, 2000, 3000},
+			effective: 1667,
+		},
+		{
+			name: "absolute discount larger than the contract",
+			pricing: m.Pricing{
+				MonthlyCostInCent:        3000,
+				ContractDurationInMonths: ptr(2),
+				AbsoluteDiscount:         &m.AbsoluteDiscount{ValueInCent: 10000},
+			},
+			schedule:  repeat(0, 2),
+			effective: 0,
+		},
+		{
+			name: "absolute discount reaching the minimum order value",
+			pricing: m.Pricing{
+				MonthlyCostInCent:        3000,
+				ContractDurationInMonths: ptr(2),
+				AbsoluteDiscount:         &m.AbsoluteDiscount{ValueInCent: 1000, MinOrderValueInCent: ptr(6000)},
+			},
+			schedule:  []int32{2000, 3000},
+			effective: 2500,
+		},
+		{
+			name: "absolute discount below the minimum order value",
+			pricing: m.Pricing{
+				MonthlyCostInCent:        3000,
+				ContractDurationInMonths: ptr(2),
+				AbsoluteDiscount:         &m.AbsoluteDiscount{ValueInCent: 1000, MinOrderValueInCent: ptr(6001)},
+			},
+			schedule:  repeat(3000, 2),
+			effective: 3000,
+		},
+		{
+			name: "minimum order value before the percentage discount",
+			pricing: m.Pricing{
+				MonthlyCostInCent:        3000,
+				ContractDurationInMonths: ptr(2),
+				PercentageDiscount:       &m.PercentageDiscount{Percentage: 50},
+				AbsoluteDiscount:         &m.AbsoluteDiscount{ValueInCent: 1000, MinOrderValueInCent: ptr(6000)},
+			},
+			schedule:  []int32{500, 1500},
+			effective: 1000,
+		},
+		{
+			name:      "no contract duration",
+			pricing:   m.Pricing{MonthlyCostInCent: 1000},
+			schedule:  repeat(1000, DefaultDurationInMonths),
+			effective: 1000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cost, err := Calculate(tt.pricing)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(cost.MonthlyCostsInCent, tt.schedule) {
+				t.Errorf("expected schedule %v, got %v", tt.schedule, cost.MonthlyCostsInCent)
+			}
+			var total int64
+			for _, month := range tt.schedule {
+				total += int64(month)
+			}
+			if cost.TotalCostInCent != total {
+				t.Errorf("expected total %d, got %d", total, cost.TotalCostInCent)
+			}
+			if cost.DurationInMonths != int32(len(tt.schedule)) {
+				t.Errorf("expected duration %d, got %d", len(tt.schedule), cost.DurationInMonths)
+			}
+			if cost.EffectiveMonthlyCostInCent != tt.effective {
+				t.Errorf("expected effective monthly cost %d, got %d", tt.effective, cost.EffectiveMonthlyCostInCent)
+			}
+		})
+	}
+}
+
+func TestCalculate_DurationTooLong(t *testing.T) {
+	if _, err := Calculate(m.Pricing{MonthlyCostInCent: 1000, ContractDurationInMonths: ptr(MaxDurationInMonths)}); err != nil {
+		t.Errorf("expected the maximum duration to be accepted, got %v", err)
+	}
+	for _, pricing := range []m.Pricing{
+		{MonthlyCostInCent: 1000, ContractDurationInMonths: ptr(MaxDurationInMonths + 1)},
+		{MonthlyCostInCent: 1000, MinContractDurationInMonths: ptr(1 << 30)},
+	} {
+		if _, err := Calculate(pricing); !errors.Is(err, ErrDurationTooLong) {
+			t.Errorf("expected ErrDurationTooLong, got %v", err)
+		}
+	}
+}
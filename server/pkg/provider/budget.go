@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/rotmanjanez/check24-gendev-7/config"
+)
+
+// BudgetLimit names a limit of a fan-out budget
+type BudgetLimit string
+
+const (
+	// LimitDepth limits the levels of follow-up requests
+	LimitDepth BudgetLimit = "depth"
+	// LimitRequests limits the requests sent per query
+	LimitRequests BudgetLimit = "requests"
+	// LimitProducts limits the products emitted per query
+	LimitProducts BudgetLimit = "products"
+)
+
+// BudgetExceededError is reported for a provider that exceeded its fan-out budget for a query
+type BudgetExceededError struct {
+	Limit BudgetLimit
+	Max   int
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("fan-out budget exceeded: more than %d %s", e.Max, e.Limit)
+}
+
+// FanOutBudget tracks the requests and products of a provider for a single query.
+// It is safe for concurrent use. A nil *FanOutBudget allows everything.
+type FanOutBudget struct {
+	cfg      config.FanOutConfig
+	requests atomic.Int64
+	products atomic.Int64
+}
+
+// NewFanOutBudget creates a budget for one query, it returns nil if no limit is configured
+func NewFanOutBudget(cfg config.FanOutConfig) *FanOutBudget {
+	if cfg.MaxDepth <= 0 && cfg.MaxRequests <= 0 && cfg.MaxProducts <= 0 {
+		return nil
+	}
+	return &FanOutBudget{cfg: cfg}
+}
+
+// Request reserves a request at depth, zero for requests returned by PrepareRequest.
+// It returns a *BudgetExceededError if the request exceeds the budget.
+func (b *FanOutBudget) Request(depth int) error {
+	if b == nil {
+		return nil
+	}
+	if b.cfg.MaxDepth > 0 && depth > b.cfg.MaxDepth {
+		return &BudgetExceededError{Limit: LimitDepth, Max: b.cfg.MaxDepth}
+	}
+	if b.cfg.MaxRequests > 0 && b.requests.Add(1) > int64(b.cfg.MaxRequests) {
+		return &BudgetExceededError{Limit: LimitRequests, Max: b.cfg.MaxRequests}
+	}
+	return nil
+}
+
+// Product reserves an emitted product.
+// It returns a *BudgetExceededError if the product exceeds the budget.
+func (b *FanOutBudget) Product() error {
+	if b == nil {
+		return nil
+	}
+	if b.cfg.MaxProducts > 0 && b.products.Add(1) > int64(b.cfg.MaxProducts) {
+		return &BudgetExceededError{Limit: LimitProducts, Max: b.cfg.MaxProducts}
+	}
+	return nil
+}
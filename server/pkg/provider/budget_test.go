@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rotmanjanez/check24-gendev-7/config"
+)
+
+func TestFanOutBudget(t *testing.T) {
+	b := NewFanOutBudget(config.FanOutConfig{MaxDepth: 1, MaxRequests: 2, MaxProducts: 1})
+
+	var budgetErr *BudgetExceededError
+	if err := b.Request(2); !errors.As(err, &budgetErr) || budgetErr.Limit != LimitDepth {
+		t.Errorf("expected depth limit, got %v", err)
+	}
+	for depth := range 2 {
+		if err := b.Request(depth); err != nil {
+			t.Errorf("unexpected error for request %d: %v", depth, err)
+		}
+	}
+	if err := b.Request(0); !errors.As(err, &budgetErr) || budgetErr.Limit != LimitRequests {
+		t.Errorf("expected request limit, got %v", err)
+	}
+
+	if err := b.Product(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := b.Product(); !errors.As(err, &budgetErr) || budgetErr.Limit != LimitProducts {
+		t.Errorf("expected product limit, got %v", err)
+	}
+}
+
+func TestFanOutBudget_Unlimited(t *testing.T) {
+	b := NewFanOutBudget(config.FanOutConfig{})
+	if b != nil {
+		t.Fatalf("expected nil budget")
+	}
+	if err := b.Request(100); err != nil {
+		t.Errorf("expected nil budget to allow requests, got %v", err)
+	}
+	if err := b.Product(); err != nil {
+		t.Errorf("expected nil budget to allow products, got %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/rotmanjanez/check24-gendev-7/config"
+	i "github.com/rotmanjanez/check24-gendev-7/pkg/interfaces"
+)
+
+// ErrCircuitOpen is returned for calls that are rejected by an open circuit breaker
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// breakerSnapshot is the state of a breaker as it is shared through the cache
+type breakerSnapshot struct {
+	State          BreakerState `json:"state"`
+	WindowStart    time.Time    `json:"windowStart"`
+	Requests       int          `json:"requests"`
+	Failures       int          `json:"failures"`
+	SlowCalls      int          `json:"slowCalls"`
+	OpenedAt       time.Time    `json:"openedAt"`
+	Probes         int          `json:"probes"`
+	ProbeSuccesses int          `json:"probeSuccesses"`
+}
+
+func (s breakerSnapshot) MarshalBinary() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+func (s *breakerSnapshot) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, s)
+}
+
+// CircuitBreaker tracks failure rate and latency of a provider and
+// rejects calls while the provider is considered unhealthy.
+//
+// The state is stored in the cache so that all instances share the same view of a provider.
+// Updates are last-writer-wins, which is good enough to keep a flapping provider out of rotation.
+// A nil *CircuitBreaker allows every call.
+type CircuitBreaker struct {
+	name   string
+	cfg    config.CircuitBreakerConfig
+	cache  i.Cache
+	local  breakerSnapshot // last known state, used if the cache is unavailable
+	mutex  sync.Mutex
+	now    func() time.Time
+	logger *slog.Logger
+}
+
+// NewCircuitBreaker creates a breaker for the provider name, filling unset thresholds with defaults
+func NewCircuitBreaker(name string, cfg config.CircuitBreakerConfig, cache i.Cache) *CircuitBreaker {
+	if cfg.FailureRateThreshold <= 0 {
+		cfg.FailureRateThreshold = 0.5
+	}
+	if cfg.SlowCallRateThreshold <= 0 {
+		cfg.SlowCallRateThreshold = 0.8
+	}
+	if cfg.MinimumRequests <= 0 {
+		cfg.MinimumRequests = 10
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 30 * time.Second
+	}
+	if cfg.OpenDuration <= 0 {
+		cfg.OpenDuration = 30 * time.Second
+	}
+	if cfg.HalfOpenRequests <= 0 {
+		cfg.HalfOpenRequests = 1
+	}
+	return &CircuitBreaker{
+		name:   name,
+		cfg:    cfg,
+		cache:  cache,
+		local:  breakerSnapshot{State: BreakerClosed},
+		now:    time.Now,
+		logger: slog.With("provider", name, "component", "circuit-breaker"),
+	}
+}
+
+// State returns the current state without counting as a call
+func (b *CircuitBreaker) State(ctx context.Context) BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	s := b.load(ctx)
+	b.advance(&s)
+	return s.State
+}
+
+// Allow reports whether a call may be issued now.
+// In half-open state only a limited number of probe calls are let through.
+func (b *CircuitBreaker) Allow(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	s := b.load(ctx)
+	b.advance(&s)
+
+	switch s.State {
+	case BreakerOpen:
+		return ErrCircuitOpen
+	case BreakerHalfOpen:
+		if s.Probes >= b.cfg.HalfOpenRequests {
+			return ErrCircuitOpen
+		}
+		s.Probes++
+		b.store(ctx, s)
+	}
+	return nil
+}
+
+// Record reports the outcome of a call that was allowed by Allow
+func (b *CircuitBreaker) Record(ctx context.Context, success bool, latency time.Duration) {
+	if b == nil {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	s := b.load(ctx)
+	b.advance(&s)
+
+	slow := b.cfg.SlowCallThreshold > 0 && latency > b.cfg.SlowCallThreshold
+
+	switch s.State {
+	case BreakerOpen:
+		// late result of a call issued before the breaker opened
+		return
+	case BreakerHalfOpen:
+		if !success || slow {
+			b.open(&s)
+			break
+		}
+		s.ProbeSuccesses++
+		if s.ProbeSuccesses >= b.cfg.HalfOpenRequests {
+			b.logger.Info("Closing circuit breaker")
+			s = breakerSnapshot{State: BreakerClosed, WindowStart: b.now()}
+		}
+	case BreakerClosed:
+		s.Requests++
+		if !success {
+			s.Failures++
+		}
+		if slow {
+			s.SlowCalls++
+		}
+		if s.Requests >= b.cfg.MinimumRequests {
+			failureRate := float64(s.Failures) / float64(s.Requests)
+			slowRate := float64(s.SlowCalls) / float64(s.Requests)
+			if failureRate >= b.cfg.FailureRateThreshold || slowRate >= b.cfg.SlowCallRateThreshold {
+				b.open(&s)
+			}
+		}
+	}
+	b.store(ctx, s)
+}
+
+// Release returns the probe of a call that was allowed by Allow but never issued or abandoned, so its outcome is unknown.
+// Every call allowed by Allow must either be recorded or released.
+func (b *CircuitBreaker) Release(ctx context.Context) {
+	if b == nil {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	s := b.load(ctx)
+	b.advance(&s)
+	if s.State != BreakerHalfOpen || s.Probes == 0 {
+		return
+	}
+	s.Probes--
+	b.store(ctx, s)
+}
+
+// advance applies the time based transitions: window rollover and open -> half-open
+func (b *CircuitBreaker) advance(s *breakerSnapshot) {
+	now := b.now()
+	switch s.State {
+	case BreakerOpen:
+		if now.Sub(s.OpenedAt) >= b.cfg.OpenDuration {
+			s.State = BreakerHalfOpen
+			s.Probes = 0
+			s.ProbeSuccesses = 0
+		}
+	case BreakerClosed, "":
+		s.State = BreakerClosed
+		if now.Sub(s.WindowStart) >= b.cfg.Window {
+			*s = breakerSnapshot{State: BreakerClosed, WindowStart: now}
+		}
+	}
+}
+
+func (b *CircuitBreaker) open(s *breakerSnapshot) {
+	b.logger.Warn("Opening circuit breaker", "requests", s.Requests, "failures", s.Failures, "slowCalls", s.SlowCalls)
+	*s = breakerSnapshot{State: BreakerOpen, OpenedAt: b.now()}
+}
+
+func (b *CircuitBreaker) load(ctx context.Context) breakerSnapshot {
+	if b.cache == nil {
+		return b.local
+	}
+	s := breakerSnapshot{}
+	found, err := b.cache.Get(ctx, b.name, &s)
+	if err != nil {
+		b.logger.Error("Error loading circuit breaker state, using local state", "error", err)
+		return b.local
+	}
+	if !found {
+		return breakerSnapshot{State: BreakerClosed, WindowStart: b.now()}
+	}
+	return s
+}
+
+func (b *CircuitBreaker) store(ctx context.Context, s breakerSnapshot) {
+	b.local = s
+	if b.cache == nil {
+		return
+	}
+	ttl := 2 * max(b.cfg.Window, b.cfg.OpenDuration)
+	if err := b.cache.Set(ctx, b.name, s, ttl); err != nil {
+		b.logger.Error("Error storing circuit breaker state", "error", err)
+	}
+}
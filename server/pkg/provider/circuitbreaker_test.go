@@ -0,0 +1,128 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rotmanjanez/check24-gendev-7/config"
+	"github.com/rotmanjanez/check24-gendev-7/pkg/cache"
+)
+
+// newTestBreaker creates a breaker with a controllable clock
+func newTestBreaker(cfg config.CircuitBreakerConfig, now *time.Time) *CircuitBreaker {
+	b := NewCircuitBreaker("test", cfg, cache.NewInstanceCache("test-breaker"))
+	b.now = func() time.Time { return *now }
+	return b
+}
+
+func TestCircuitBreaker_OpensOnFailureRate(t *testing.T) {
+	now := time.Now()
+	b := newTestBreaker(config.CircuitBreakerConfig{MinimumRequests: 4, FailureRateThreshold: 0.5}, &now)
+	ctx := context.Background()
+
+	for _, success := range []bool{true, false, true} {
+		if err := b.Allow(ctx); err != nil {
+			t.Fatalf("expected call to be allowed, got %v", err)
+		}
+		b.Record(ctx, success, time.Millisecond)
+	}
+	if state := b.State(ctx); state != BreakerClosed {
+		t.Fatalf("expected closed below minimum requests, got %s", state)
+	}
+
+	b.Record(ctx, false, time.Millisecond)
+	if state := b.State(ctx); state != BreakerOpen {
+		t.Fatalf("expected open after 50%% failures, got %s", state)
+	}
+	if err := b.Allow(ctx); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	}
+}
+
+func TestCircuitBreaker_OpensOnSlowCalls(t *testing.T) {
+	now := time.Now()
+	b := newTestBreaker(config.CircuitBreakerConfig{
+		MinimumRequests:       2,
+		SlowCallThreshold:     100 * time.Millisecond,
+		SlowCallRateThreshold: 1,
+	}, &now)
+	ctx := context.Background()
+
+	b.Record(ctx, true, time.Second)
+	b.Record(ctx, true, time.Second)
+	if state := b.State(ctx); state != BreakerOpen {
+		t.Fatalf("expected open after slow calls, got %s", state)
+	}
+}
+
+func TestCircuitBreaker_HalfOpenProbe(t *testing.T) {
+	now := time.Now()
+	b := newTestBreaker(config.CircuitBreakerConfig{MinimumRequests: 1, OpenDuration: time.Second}, &now)
+	ctx := context.Background()
+
+	b.Record(ctx, false, time.Millisecond)
+	if state := b.State(ctx); state != BreakerOpen {
+		t.Fatalf("expected open, got %s", state)
+	}
+
+	now = now.Add(2 * time.Second)
+	if state := b.State(ctx); state != BreakerHalfOpen {
+		t.Fatalf("expected half-open after open duration, got %s", state)
+	}
+	if err := b.Allow(ctx); err != nil {
+		t.Fatalf("expected probe to be allowed, got %v", err)
+	}
+	if err := b.Allow(ctx); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected second probe to be rejected, got %v", err)
+	}
+	// a released probe, e.g. of an abandoned call, frees its slot
+	b.Release(ctx)
+	if err := b.Allow(ctx); err != nil {
+		t.Fatalf("expected probe to be allowed after release, got %v", err)
+	}
+
+	// failed probe opens the breaker again
+	b.Record(ctx, false, time.Millisecond)
+	if state := b.State(ctx); state != BreakerOpen {
+		t.Fatalf("expected open after failed probe, got %s", state)
+	}
+
+	now = now.Add(2 * time.Second)
+	if err := b.Allow(ctx); err != nil {
+		t.Fatalf("expected probe to be allowed, got %v", err)
+	}
+	b.Record(ctx, true, time.Millisecond)
+	if state := b.State(ctx); state != BreakerClosed {
+		t.Errorf("expected closed after successful probe, got %s", state)
+	}
+}
+
+func TestCircuitBreaker_SharedThroughCache(t *testing.T) {
+	now := time.Now()
+	shared := cache.NewInstanceCache("test-breaker-shared")
+	cfg := config.CircuitBreakerConfig{MinimumRequests: 1}
+	a := NewCircuitBreaker("shared", cfg, shared)
+	b := NewCircuitBreaker("shared", cfg, shared)
+	a.now = func() time.Time { return now }
+	b.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	a.Record(ctx, false, time.Millisecond)
+	if err := b.Allow(ctx); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected breaker state to be shared, got %v", err)
+	}
+}
+
+func TestCircuitBreaker_NilAllowsAll(t *testing.T) {
+	var b *CircuitBreaker
+	ctx := context.Background()
+	if err := b.Allow(ctx); err != nil {
+		t.Errorf("expected nil breaker to allow calls, got %v", err)
+	}
+	b.Record(ctx, false, time.Second)
+	if state := b.State(ctx); state != BreakerClosed {
+		t.Errorf("expected nil breaker to be closed, got %s", state)
+	}
+}
@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rotmanjanez/check24-gendev-7/config"
+)
+
+// hedgeWindow is the number of recent latencies the hedge delay is computed from
+const hedgeWindow = 100
+
+// Hedger decides when a slow call to a provider is hedged with a second request.
+// The delay is a percentile of recent latencies, and hedges are limited to a share of all requests.
+// It is safe for concurrent use. A nil *Hedger never hedges.
+type Hedger struct {
+	cfg       config.HedgeConfig
+	mutex     sync.Mutex
+	latencies []time.Duration // ring buffer of recent latencies
+	next      int
+	requests  int64
+	hedges    int64
+	logger    *slog.Logger
+}
+
+// NewHedger creates a hedger for the provider name, filling unset values with defaults.
+// It returns nil if hedging is disabled.
+func NewHedger(name string, cfg config.HedgeConfig) *Hedger {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Percentile <= 0 || cfg.Percentile >= 1 {
+		cfg.Percentile = 0.9
+	}
+	if cfg.Budget <= 0 {
+		cfg.Budget = 0.1
+	}
+	if cfg.MinSamples <= 0 {
+		cfg.MinSamples = 20
+	}
+	cfg.MinSamples = min(cfg.MinSamples, hedgeWindow)
+	return &Hedger{
+		cfg:       cfg,
+		latencies: make([]time.Duration, 0, hedgeWindow),
+		logger:    slog.With("provider", name, "component", "hedger"),
+	}
+}
+
+// Delay counts a request and returns how long to wait for its response before hedging it.
+// It returns false if the request must not be hedged because too few latencies were observed.
+func (h *Hedger) Delay() (time.Duration, bool) {
+	if h == nil {
+		return 0, false
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.requests++
+	if len(h.latencies) < h.cfg.MinSamples {
+		return 0, false
+	}
+	sorted := slices.Clone(h.latencies)
+	slices.Sort(sorted)
+	idx := int(math.Ceil(h.cfg.Percentile*float64(len(sorted)))) - 1
+	return sorted[max(idx, 0)], true
+}
+
+// TryHedge reports whether a hedge fits into the budget and counts it if so
+func (h *Hedger) TryHedge() bool {
+	if h == nil {
+		return false
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if float64(h.hedges+1) > h.cfg.Budget*float64(h.requests) {
+		h.logger.Debug("Not hedging request, budget exhausted", "requests", h.requests, "hedges", h.hedges)
+		return false
+	}
+	h.hedges++
+	return true
+}
+
+// Observe records the latency of a successful call
+func (h *Hedger) Observe(latency time.Duration) {
+	if h == nil {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if len(h.latencies) < hedgeWindow {
+		h.latencies = append(h.latencies, latency)
+		return
+	}
+	h.latencies[h.next] = latency
+	h.next = (h.next + 1) % hedgeWindow
+}
@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rotmanjanez/check24-gendev-7/config"
+)
+
+func TestHedger_Delay(t *testing.T) {
+	h := NewHedger("test", config.HedgeConfig{Enabled: true, MinSamples: 10})
+
+	if _, ok := h.Delay(); ok {
+		t.Errorf("expected no hedging without observed latencies")
+	}
+	for ms := 1; ms <= 10; ms++ {
+		h.Observe(time.Duration(ms) * time.Millisecond)
+	}
+	if delay, ok := h.Delay(); !ok || delay != 9*time.Millisecond {
+		t.Errorf("expected p90 delay of 9ms, got %v, %v", delay, ok)
+	}
+
+	// old latencies are replaced once the window is full
+	for range hedgeWindow {
+		h.Observe(time.Second)
+	}
+	if delay, _ := h.Delay(); delay != time.Second {
+		t.Errorf("expected delay from recent latencies, got %v", delay)
+	}
+}
+
+func TestHedger_Budget(t *testing.T) {
+	h := NewHedger("test", config.HedgeConfig{Enabled: true, Budget: 0.1})
+
+	for range 9 {
+		h.Delay()
+	}
+	if h.TryHedge() {
+		t.Errorf("expected no hedge before 10 requests")
+	}
+	h.Delay()
+	if !h.TryHedge() {
+		t.Errorf("expected hedge after 10 requests")
+	}
+	if h.TryHedge() {
+		t.Errorf("expected budget to be exhausted")
+	}
+}
+
+func TestHedger_Disabled(t *testing.T) {
+	h := NewHedger("test", config.HedgeConfig{})
+	if h != nil {
+		t.Fatalf("expected nil hedger")
+	}
+	h.Observe(time.Millisecond)
+	if _, ok := h.Delay(); ok || h.TryHedge() {
+		t.Errorf("expected nil hedger to never hedge")
+	}
+}
@@ -0,0 +1,207 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/rotmanjanez/check24-gendev-7/config"
+)
+
+// CallOutcome classifies a finished provider call for a ConcurrencyLimiter
+type CallOutcome int
+
+const (
+	// OutcomeSuccess is a call that the provider answered in time
+	OutcomeSuccess CallOutcome = iota
+	// OutcomeOverload is a call that indicates an overloaded provider, i.e. 429 or a timeout
+	OutcomeOverload
+	// OutcomeFailure is any other failed call
+	OutcomeFailure
+)
+
+// ConcurrencyLimiter limits the number of concurrent calls to a provider
+type ConcurrencyLimiter interface {
+	// Acquire blocks until a call may be issued or ctx is done
+	Acquire(ctx context.Context) error
+	// TryAcquire takes a slot without blocking and reports whether it got one
+	TryAcquire() bool
+	// Release frees the slot taken by Acquire and reports the outcome of the call
+	Release(outcome CallOutcome, latency time.Duration)
+	// Limit returns the current number of allowed concurrent calls
+	Limit() int
+}
+
+// NewConcurrencyLimiter creates the limiter selected by cfg.Strategy.
+// maxConcurrent is the limit of the static strategy and the default upper bound of adaptive strategies.
+func NewConcurrencyLimiter(name string, maxConcurrent int, cfg config.ConcurrencyLimitConfig) (ConcurrencyLimiter, error) {
+	switch cfg.Strategy {
+	case "", config.StaticConcurrencyLimit:
+		return NewStaticLimiter(maxConcurrent), nil
+	case config.AIMDConcurrencyLimit:
+		if cfg.MaxLimit <= 0 {
+			cfg.MaxLimit = maxConcurrent
+		}
+		return NewAIMDLimiter(name, cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown concurrency limit strategy: %s", cfg.Strategy)
+	}
+}
+
+// StaticLimiter allows a fixed number of concurrent calls
+type StaticLimiter struct {
+	semaphore chan struct{}
+}
+
+// NewStaticLimiter creates a limiter allowing limit concurrent calls, at least one
+func NewStaticLimiter(limit int) *StaticLimiter {
+	if limit <= 0 {
+		limit = 1
+	}
+	return &StaticLimiter{semaphore: make(chan struct{}, limit)}
+}
+
+func (l *StaticLimiter) Acquire(ctx context.Context) error {
+	select {
+	case l.semaphore <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *StaticLimiter) TryAcquire() bool {
+	select {
+	case l.semaphore <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *StaticLimiter) Release(outcome CallOutcome, latency time.Duration) {
+	<-l.semaphore
+}
+
+func (l *StaticLimiter) Limit() int {
+	return cap(l.semaphore)
+}
+
+// AIMDLimiter adapts the concurrency limit with additive increase and multiplicative decrease.
+// Every successful call raises the limit by 1/limit, so the limit grows by one per round of calls.
+// Overloaded calls and latency spikes multiply the limit by the decrease factor.
+// The limit always stays within the configured bounds.
+type AIMDLimiter struct {
+	cfg      config.ConcurrencyLimitConfig
+	mutex    sync.Mutex
+	limit    float64
+	inflight int
+	latency  time.Duration // moving average of successful calls
+	released chan struct{} // closed and replaced whenever a slot is freed
+	logger   *slog.Logger
+}
+
+// NewAIMDLimiter creates an adaptive limiter, filling unset values with defaults
+func NewAIMDLimiter(name string, cfg config.ConcurrencyLimitConfig) *AIMDLimiter {
+	if cfg.MinLimit <= 0 {
+		cfg.MinLimit = 1
+	}
+	if cfg.MaxLimit < cfg.MinLimit {
+		cfg.MaxLimit = cfg.MinLimit
+	}
+	if cfg.InitialLimit <= 0 {
+		cfg.InitialLimit = cfg.MinLimit
+	}
+	if cfg.DecreaseFactor <= 0 || cfg.DecreaseFactor >= 1 {
+		cfg.DecreaseFactor = 0.5
+	}
+	if cfg.LatencySpikeFactor <= 1 {
+		cfg.LatencySpikeFactor = 2
+	}
+	return &AIMDLimiter{
+		cfg:      cfg,
+		limit:    min(max(float64(cfg.InitialLimit), float64(cfg.MinLimit)), float64(cfg.MaxLimit)),
+		released: make(chan struct{}),
+		logger:   slog.With("provider", name, "component", "concurrency-limiter"),
+	}
+}
+
+func (l *AIMDLimiter) Acquire(ctx context.Context) error {
+	for {
+		l.mutex.Lock()
+		if l.inflight < int(l.limit) {
+			l.inflight++
+			l.mutex.Unlock()
+			return nil
+		}
+		released := l.released
+		l.mutex.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (l *AIMDLimiter) TryAcquire() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.inflight < int(l.limit) {
+		l.inflight++
+		return true
+	}
+	return false
+}
+
+func (l *AIMDLimiter) Release(outcome CallOutcome, latency time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.inflight--
+	previous := int(l.limit)
+
+	switch {
+	case outcome == OutcomeOverload || (outcome == OutcomeSuccess && l.isLatencySpike(latency)):
+		l.limit = max(l.limit*l.cfg.DecreaseFactor, float64(l.cfg.MinLimit))
+	case outcome == OutcomeSuccess:
+		l.limit = min(l.limit+1/l.limit, float64(l.cfg.MaxLimit))
+	}
+	if outcome == OutcomeSuccess {
+		l.observe(latency)
+	}
+
+	if current := int(l.limit); current != previous {
+		l.logger.Debug("Adjusted concurrency limit", "from", previous, "to", current, "outcome", outcome, "latency", latency)
+	}
+
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+func (l *AIMDLimiter) Limit() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return int(l.limit)
+}
+
+// isLatencySpike compares latency against the configured threshold,
+// or against the moving average if no threshold is configured
+func (l *AIMDLimiter) isLatencySpike(latency time.Duration) bool {
+	if l.cfg.LatencyThreshold > 0 {
+		return latency > l.cfg.LatencyThreshold
+	}
+	return l.latency > 0 && float64(latency) > l.cfg.LatencySpikeFactor*float64(l.latency)
+}
+
+// observe updates the moving average latency
+func (l *AIMDLimiter) observe(latency time.Duration) {
+	if l.latency == 0 {
+		l.latency = latency
+		return
+	}
+	l.latency = time.Duration(0.9*float64(l.latency) + 0.1*float64(latency))
+}
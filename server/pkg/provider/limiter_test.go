@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rotmanjanez/check24-gendev-7/config"
+)
+
+func TestStaticLimiter(t *testing.T) {
+	l := NewStaticLimiter(1)
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected acquire to block until the deadline, got %v", err)
+	}
+	if l.TryAcquire() {
+		t.Errorf("expected no free slot")
+	}
+
+	l.Release(OutcomeOverload, time.Second)
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Errorf("expected slot to be free after release, got %v", err)
+	}
+	if l.Limit() != 1 {
+		t.Errorf("expected static limit to stay 1, got %d", l.Limit())
+	}
+}
+
+func TestAIMDLimiter_IncreasesAndDecreases(t *testing.T) {
+	l := NewAIMDLimiter("test", config.ConcurrencyLimitConfig{MinLimit: 1, MaxLimit: 4, LatencyThreshold: time.Second})
+	ctx := context.Background()
+
+	for range 20 {
+		if err := l.Acquire(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		l.Release(OutcomeSuccess, time.Millisecond)
+	}
+	if l.Limit() != 4 {
+		t.Fatalf("expected limit to grow to the maximum, got %d", l.Limit())
+	}
+
+	_ = l.Acquire(ctx)
+	l.Release(OutcomeOverload, time.Millisecond)
+	if l.Limit() != 2 {
+		t.Errorf("expected limit to be halved on overload, got %d", l.Limit())
+	}
+
+	_ = l.Acquire(ctx)
+	l.Release(OutcomeSuccess, 2*time.Second)
+	if l.Limit() != 1 {
+		t.Errorf("expected limit to be halved on latency spike, got %d", l.Limit())
+	}
+
+	_ = l.Acquire(ctx)
+	l.Release(OutcomeOverload, time.Millisecond)
+	if l.Limit() != 1 {
+		t.Errorf("expected limit to stay at the minimum, got %d", l.Limit())
+	}
+
+	_ = l.Acquire(ctx)
+	l.Release(OutcomeFailure, time.Millisecond)
+	if l.Limit() != 1 {
+		t.Errorf("expected other failures to keep the limit, got %d", l.Limit())
+	}
+}
+
+func TestAIMDLimiter_BlocksAtLimit(t *testing.T) {
+	l := NewAIMDLimiter("test", config.ConcurrencyLimitConfig{MinLimit: 1, MaxLimit: 1})
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	acquired := make(chan error)
+	go func() {
+		acquired <- l.Acquire(context.Background())
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("expected acquire to block while the limit is reached")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	l.Release(OutcomeSuccess, time.Millisecond)
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected waiting acquire to proceed after release")
+	}
+}
+
+func TestNewConcurrencyLimiter(t *testing.T) {
+	static, err := NewConcurrencyLimiter("test", 3, config.ConcurrencyLimitConfig{})
+	if err != nil || static.Limit() != 3 {
+		t.Errorf("expected static limiter by default, got %v, %v", static, err)
+	}
+
+	aimd, err := NewConcurrencyLimiter("test", 3, config.ConcurrencyLimitConfig{Strategy: "aimd", InitialLimit: 10})
+	if err != nil || aimd.Limit() != 3 {
+		t.Errorf("expected aimd limiter bounded by maxConcurrent, got %v, %v", aimd, err)
+	}
+
+	if _, err := NewConcurrencyLimiter("test", 3, config.ConcurrencyLimitConfig{Strategy: "unknown"}); err == nil {
+		t.Errorf("expected error for unknown strategy")
+	}
+}
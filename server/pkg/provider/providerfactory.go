@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/rotmanjanez/check24-gendev-7/config"
+	"github.com/rotmanjanez/check24-gendev-7/pkg/auth"
 	i "github.com/rotmanjanez/check24-gendev-7/pkg/interfaces"
 )
 
@@ -27,9 +28,16 @@ type ProviderConfig struct {
 	Client          *http.Client
 	RetryCount      int
 	Timeout         time.Duration
-	ConcurrentLimit int           // max parallel HTTP requests
-	Semaphore       chan struct{} // throttles concurrent calls
-	BackoffInterval time.Duration // base wait for retries/throttling
+	ConcurrentLimit int                 // max parallel HTTP requests of the static limiter
+	Limiter         ConcurrencyLimiter  // throttles concurrent calls
+	RetryPolicy     *RetryPolicy        // decides which calls are retried and the wait in between
+	Breaker         *CircuitBreaker     // optional, nil disables the circuit breaker
+	ResultCache     *ResultCache        // optional, nil disables caching of results
+	RateLimiter     *RateLimiter        // optional, nil disables rate limiting
+	Signer          auth.Signer         // optional, authenticates every attempt of a request
+	FanOut          config.FanOutConfig // limits requests and products per query
+	SoftDeadline    time.Duration       // optional, time after which the query no longer waits for the provider
+	Hedger          *Hedger             // optional, nil disables hedged requests
 }
 
 // NewProviderConfig constructs a ProviderConfig with concurrency control
@@ -51,15 +59,31 @@ func NewProviderConfig(adapter i.ProviderAdapter, retries int, timeout time.Dura
 		RetryCount:      retries,
 		Timeout:         timeout,
 		ConcurrentLimit: maxConcurrent,
-		Semaphore:       make(chan struct{}, maxConcurrent),
-		BackoffInterval: backoff,
+		Limiter:         NewStaticLimiter(maxConcurrent),
+		RetryPolicy:     DefaultRetryPolicy(backoff),
 	}
 }
 
 // Create backends from config
-func CreateProviders(cacheFactory i.CacheFactory, cfg *config.Config) ([]*ProviderConfig, error) {
+// localRateLimits enforces rate limits per instance if the rate limit cache does not support them or is unavailable.
+func CreateProviders(cacheFactory i.CacheFactory, cfg *config.Config, localRateLimits i.RateLimitStore) ([]*ProviderConfig, error) {
 	var providers []*ProviderConfig
 
+	breakerCache, err := cacheFactory.Create("circuit-breaker")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create circuit breaker cache: %w", err)
+	}
+	resultCache, err := cacheFactory.Create("result-cache")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create result cache: %w", err)
+	}
+	rateLimitCache, err := cacheFactory.Create("rate-limit")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rate limit cache: %w", err)
+	}
+	// caches without atomic counters fall back to limits per instance
+	rateLimitStore, _ := rateLimitCache.(i.RateLimitStore)
+
 	for name, backendCfg := range cfg.Backends {
 		if !backendCfg.Enabled {
 			continue
@@ -74,13 +98,35 @@ func CreateProviders(cacheFactory i.CacheFactory, cfg *config.Config) ([]*Provid
 		if err != nil {
 			return nil, fmt.Errorf("failed to create provider %s: %w", name, err)
 		}
-		providers = append(providers, NewProviderConfig(
+		providerConfig := NewProviderConfig(
 			provider,
 			backendCfg.Retries,
 			backendCfg.Timeout,
 			backendCfg.MaxConcurrent,
 			backendCfg.Backoff,
-		))
+		)
+		providerConfig.RetryPolicy, err = NewRetryPolicy(backendCfg.Backoff, backendCfg.RetryPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create retry policy for provider %s: %w", name, err)
+		}
+		if backendCfg.CircuitBreaker.Enabled {
+			providerConfig.Breaker = NewCircuitBreaker(name, backendCfg.CircuitBreaker, breakerCache)
+		}
+		providerConfig.Limiter, err = NewConcurrencyLimiter(name, backendCfg.MaxConcurrent, backendCfg.ConcurrencyLimit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create concurrency limiter for provider %s: %w", name, err)
+		}
+		providerConfig.ResultCache = NewResultCache(name, backendCfg.ResultCache, resultCache)
+		providerConfig.RateLimiter = NewRateLimiter(name, backendCfg.RateLimit, rateLimitStore, localRateLimits)
+		providerConfig.FanOut = backendCfg.FanOut
+		providerConfig.SoftDeadline = backendCfg.SoftDeadline
+		providerConfig.Hedger = NewHedger(name, backendCfg.Hedge)
+		providerConfig.Signer, err = auth.New(backendCfg.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create signer for provider %s: %w", name, err)
+		}
+		slog.Debug("Created provider", "provider", name, "signer", providerConfig.Signer)
+		providers = append(providers, providerConfig)
 	}
 
 	return providers, nil
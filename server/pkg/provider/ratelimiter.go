@@ -0,0 +1,126 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/rotmanjanez/check24-gendev-7/config"
+	i "github.com/rotmanjanez/check24-gendev-7/pkg/interfaces"
+)
+
+// ErrQuotaExhausted is returned for calls exceeding the daily quota of a provider
+var ErrQuotaExhausted = errors.New("daily quota exhausted")
+
+// ErrRateLimited is returned by Allow if the rate limit does not allow another call right now
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RateLimiter enforces the request rate and daily quota of a provider.
+// Limits are shared by all instances if the store is shared, e.g. Redis.
+// If the store is unavailable, the limits are enforced per instance.
+// A nil *RateLimiter allows every call.
+type RateLimiter struct {
+	name   string
+	cfg    config.RateLimitConfig
+	store  i.RateLimitStore
+	local  i.RateLimitStore // used if the store is unavailable
+	now    func() time.Time
+	logger *slog.Logger
+}
+
+// NewRateLimiter creates a rate limiter for the provider name, filling unset values with defaults.
+// It returns nil if neither a rate nor a quota is configured. local enforces the limits per instance
+// if store is nil or unavailable, it may be shared by the rate limiters of all providers.
+func NewRateLimiter(name string, cfg config.RateLimitConfig, store i.RateLimitStore, local i.RateLimitStore) *RateLimiter {
+	if cfg.RequestsPerSecond <= 0 && cfg.DailyQuota <= 0 {
+		return nil
+	}
+	if cfg.Burst <= 0 {
+		cfg.Burst = max(1, int(math.Ceil(cfg.RequestsPerSecond)))
+	}
+
+	if store == nil {
+		store = local
+	}
+	return &RateLimiter{
+		name:   name,
+		cfg:    cfg,
+		store:  store,
+		local:  local,
+		now:    time.Now,
+		logger: slog.With("provider", name, "component", "rate-limiter"),
+	}
+}
+
+// Wait blocks until the rate limit allows another call and counts the call against the daily quota.
+// It returns ErrQuotaExhausted if the daily quota is used up and the context error if ctx is done first.
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+
+	if r.cfg.RequestsPerSecond > 0 {
+		for {
+			wait := r.takeToken(ctx)
+			if wait <= 0 {
+				break
+			}
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+	return r.countQuota(ctx)
+}
+
+// Allow is like Wait, but returns ErrRateLimited instead of waiting if no call is allowed right now.
+// It is meant for optional calls, such as hedged requests.
+func (r *RateLimiter) Allow(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+
+	if r.cfg.RequestsPerSecond > 0 && r.takeToken(ctx) > 0 {
+		return ErrRateLimited
+	}
+	return r.countQuota(ctx)
+}
+
+// takeToken takes a token from the bucket and returns how long to wait if none is left
+func (r *RateLimiter) takeToken(ctx context.Context) time.Duration {
+	wait, err := r.store.TakeToken(ctx, r.name+":bucket", r.cfg.RequestsPerSecond, r.cfg.Burst)
+	if err != nil {
+		r.logger.Error("Error taking token, limiting per instance", "error", err)
+		wait, _ = r.local.TakeToken(ctx, r.name+":bucket", r.cfg.RequestsPerSecond, r.cfg.Burst)
+	}
+	return wait
+}
+
+// countQuota counts a call against the daily quota, returning ErrQuotaExhausted if it is used up
+func (r *RateLimiter) countQuota(ctx context.Context) error {
+	if r.cfg.DailyQuota <= 0 {
+		return nil
+	}
+
+	// quotas reset at midnight UTC, keep the counter a little longer than a day
+	key := r.name + ":quota:" + r.now().UTC().Format(time.DateOnly)
+	used, err := r.store.Increment(ctx, key, 25*time.Hour)
+	if err != nil {
+		r.logger.Error("Error counting call against quota, counting per instance", "error", err)
+		used, _ = r.local.Increment(ctx, key, 25*time.Hour)
+	}
+	if used > int64(r.cfg.DailyQuota) {
+		if used == int64(r.cfg.DailyQuota)+1 {
+			r.logger.Warn("Daily quota exhausted", "quota", r.cfg.DailyQuota)
+		}
+		return ErrQuotaExhausted
+	}
+	return nil
+}
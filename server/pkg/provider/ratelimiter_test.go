@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rotmanjanez/check24-gendev-7/config"
+	"github.com/rotmanjanez/check24-gendev-7/pkg/cache"
+)
+
+func TestRateLimiter_BurstThenWait(t *testing.T) {
+	r := NewRateLimiter("test", config.RateLimitConfig{RequestsPerSecond: 20, Burst: 2}, cache.NewInstanceCache("test-rate"), cache.NewInstanceCache("test-rate-local"))
+	ctx := context.Background()
+
+	start := time.Now()
+	for range 2 {
+		if err := r.Wait(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 25*time.Millisecond {
+		t.Errorf("expected burst to pass immediately, took %v", elapsed)
+	}
+
+	start = time.Now()
+	if err := r.Wait(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("expected call after burst to wait for a token, took %v", elapsed)
+	}
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	r := NewRateLimiter("test", config.RateLimitConfig{RequestsPerSecond: 0.1, Burst: 1}, nil, cache.NewInstanceCache("test-rate-local"))
+	if err := r.Allow(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.Allow(context.Background()); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited without waiting, got %v", err)
+	}
+}
+
+func TestRateLimiter_ContextCancelled(t *testing.T) {
+	r := NewRateLimiter("test", config.RateLimitConfig{RequestsPerSecond: 0.1, Burst: 1}, nil, cache.NewInstanceCache("test-rate-local"))
+	if err := r.Wait(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := r.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestRateLimiter_DailyQuota(t *testing.T) {
+	now := time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+	r := NewRateLimiter("test", config.RateLimitConfig{DailyQuota: 2}, cache.NewInstanceCache("test-quota"), cache.NewInstanceCache("test-quota-local"))
+	r.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for range 2 {
+		if err := r.Wait(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := r.Wait(ctx); !errors.Is(err, ErrQuotaExhausted) {
+		t.Errorf("expected quota to be exhausted, got %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if err := r.Wait(ctx); err != nil {
+		t.Errorf("expected quota to reset on the next day, got %v", err)
+	}
+}
+
+func TestRateLimiter_DisabledWithoutLimits(t *testing.T) {
+	r := NewRateLimiter("test", config.RateLimitConfig{}, nil, nil)
+	if r != nil {
+		t.Fatalf("expected nil rate limiter")
+	}
+	if err := r.Wait(context.Background()); err != nil {
+		t.Errorf("expected nil rate limiter to allow calls, got %v", err)
+	}
+}
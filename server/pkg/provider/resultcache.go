@@ -0,0 +1,120 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/rotmanjanez/check24-gendev-7/config"
+	i "github.com/rotmanjanez/check24-gendev-7/pkg/interfaces"
+	m "github.com/rotmanjanez/check24-gendev-7/pkg/models"
+)
+
+// resultCacheEntry holds all products a provider returned for an address
+type resultCacheEntry struct {
+	Products []m.InternetProduct `json:"products"`
+	StoredAt time.Time           `json:"storedAt"`
+}
+
+func (e resultCacheEntry) MarshalBinary() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+func (e *resultCacheEntry) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, e)
+}
+
+// refreshLock marks an address whose products are being refreshed
+type refreshLock struct{}
+
+func (refreshLock) MarshalBinary() ([]byte, error) {
+	return []byte{}, nil
+}
+
+// ResultCache stores the products of a provider per address.
+// Entries younger than TTL are fresh, entries younger than TTL + StaleTTL are stale
+// and should be served while they are refreshed in the background.
+// A nil *ResultCache caches nothing.
+type ResultCache struct {
+	name   string
+	cfg    config.ResultCacheConfig
+	cache  i.Cache
+	now    func() time.Time
+	logger *slog.Logger
+}
+
+// NewResultCache creates a result cache for the provider name.
+// It returns nil if caching is disabled by a non-positive TTL.
+func NewResultCache(name string, cfg config.ResultCacheConfig, cache i.Cache) *ResultCache {
+	if cfg.TTL <= 0 {
+		return nil
+	}
+	if cfg.StaleTTL < 0 {
+		cfg.StaleTTL = 0
+	}
+	return &ResultCache{
+		name:   name,
+		cfg:    cfg,
+		cache:  cache,
+		now:    time.Now,
+		logger: slog.With("provider", name, "component", "result-cache"),
+	}
+}
+
+// Get returns the cached products for address and whether they are still fresh
+func (c *ResultCache) Get(ctx context.Context, address m.Address) (products []m.InternetProduct, fresh bool, found bool) {
+	if c == nil {
+		return nil, false, false
+	}
+	entry := new(resultCacheEntry)
+	found, err := c.cache.Get(ctx, c.key(address), entry)
+	if err != nil {
+		c.logger.Error("Error getting cached products", "error", err)
+		return nil, false, false
+	}
+	if !found {
+		return nil, false, false
+	}
+	return entry.Products, c.now().Sub(entry.StoredAt) < c.cfg.TTL, true
+}
+
+// Set stores the products for address. DateOffered of the products is kept,
+// so clients can tell how old cached products are.
+func (c *ResultCache) Set(ctx context.Context, address m.Address, products []m.InternetProduct) {
+	if c == nil {
+		return
+	}
+	entry := resultCacheEntry{Products: products, StoredAt: c.now()}
+	if err := c.cache.Set(ctx, c.key(address), entry, c.cfg.TTL+c.cfg.StaleTTL); err != nil {
+		c.logger.Error("Error caching products", "error", err)
+	}
+}
+
+// LockRefresh reports whether the caller may refresh the products for address.
+// Only one refresh per address runs at a time across all instances, the lock expires after ttl.
+func (c *ResultCache) LockRefresh(ctx context.Context, address m.Address, ttl time.Duration) bool {
+	if c == nil {
+		return false
+	}
+	ok, err := c.cache.SetIfNotExists(ctx, "refresh:"+c.key(address), refreshLock{}, ttl)
+	if err != nil {
+		c.logger.Error("Error locking cached products for refresh", "error", err)
+		return false
+	}
+	return ok
+}
+
+// UnlockRefresh releases the refresh lock for address
+func (c *ResultCache) UnlockRefresh(ctx context.Context, address m.Address) {
+	if c == nil {
+		return
+	}
+	if err := c.cache.Delete(ctx, "refresh:"+c.key(address)); err != nil {
+		c.logger.Error("Error unlocking cached products", "error", err)
+	}
+}
+
+func (c *ResultCache) key(address m.Address) string {
+	return c.name + ":" + m.AddressKey(address)
+}
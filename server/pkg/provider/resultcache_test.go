@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rotmanjanez/check24-gendev-7/config"
+	"github.com/rotmanjanez/check24-gendev-7/pkg/cache"
+	m "github.com/rotmanjanez/check24-gendev-7/pkg/models"
+)
+
+func TestResultCache_FreshAndStale(t *testing.T) {
+	now := time.Now()
+	c := NewResultCache("test", config.ResultCacheConfig{TTL: time.Minute, StaleTTL: time.Hour}, cache.NewInstanceCache("test-results"))
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+	address := m.Address{Street: "Hauptstr.", HouseNumber: "1", City: "Berlin", PostalCode: "10115", CountryCode: m.DE}
+	offered := now.Add(-time.Hour)
+
+	if _, _, found := c.Get(ctx, address); found {
+		t.Fatalf("expected empty cache")
+	}
+
+	c.Set(ctx, address, []m.InternetProduct{{Id: "1", DateOffered: offered}})
+
+	sameAddress := address
+	sameAddress.Street = " hauptstrasse"
+	products, fresh, found := c.Get(ctx, sameAddress)
+	if !found || !fresh || len(products) != 1 {
+		t.Fatalf("expected fresh products for the same address, got %v, %v, %v", products, fresh, found)
+	}
+	if !products[0].DateOffered.Equal(offered) {
+		t.Errorf("expected DateOffered to be kept, got %v", products[0].DateOffered)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, fresh, found := c.Get(ctx, address); !found || fresh {
+		t.Errorf("expected stale products after TTL, got fresh=%v found=%v", fresh, found)
+	}
+}
+
+func TestResultCache_RefreshLock(t *testing.T) {
+	c := NewResultCache("test", config.ResultCacheConfig{TTL: time.Minute}, cache.NewInstanceCache("test-results-lock"))
+	ctx := context.Background()
+	address := m.Address{Street: "Teststrasse", City: "Berlin", PostalCode: "10115", CountryCode: m.DE}
+
+	if !c.LockRefresh(ctx, address, time.Minute) {
+		t.Fatalf("expected first refresh to get the lock")
+	}
+	if c.LockRefresh(ctx, address, time.Minute) {
+		t.Errorf("expected concurrent refresh to be rejected")
+	}
+	c.UnlockRefresh(ctx, address)
+	if !c.LockRefresh(ctx, address, time.Minute) {
+		t.Errorf("expected refresh to get the lock after unlock")
+	}
+}
+
+func TestResultCache_DisabledWithoutTTL(t *testing.T) {
+	c := NewResultCache("test", config.ResultCacheConfig{}, cache.NewInstanceCache("test-results-disabled"))
+	if c != nil {
+		t.Fatalf("expected nil result cache without TTL")
+	}
+	ctx := context.Background()
+	c.Set(ctx, m.Address{}, []m.InternetProduct{{Id: "1"}})
+	if _, _, found := c.Get(ctx, m.Address{}); found {
+		t.Errorf("expected nil result cache to cache nothing")
+	}
+}
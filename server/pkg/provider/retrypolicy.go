@@ -0,0 +1,129 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rotmanjanez/check24-gendev-7/config"
+)
+
+var defaultRetryOn = []string{"408", "429", "5xx"}
+
+// RetryPolicy decides which failed provider calls are retried and how long to wait in between.
+// Delays grow exponentially with full jitter and are capped at MaxDelay.
+// A Retry-After header sent by the provider takes precedence over the computed delay.
+type RetryPolicy struct {
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	Multiplier float64
+
+	statusCodes   map[int]bool
+	statusClasses map[int]bool // e.g. 5 for 5xx
+	random        func() float64
+}
+
+// NewRetryPolicy creates a policy starting at base delay, filling unset values with defaults
+func NewRetryPolicy(base time.Duration, cfg config.RetryPolicyConfig) (*RetryPolicy, error) {
+	policy := &RetryPolicy{
+		BaseDelay:     base,
+		MaxDelay:      cfg.MaxBackoff,
+		Multiplier:    cfg.Multiplier,
+		statusCodes:   make(map[int]bool),
+		statusClasses: make(map[int]bool),
+		random:        rand.Float64,
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = 30 * time.Second
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = 2
+	}
+
+	retryOn := cfg.RetryOn
+	if len(retryOn) == 0 {
+		retryOn = defaultRetryOn
+	}
+	for _, code := range retryOn {
+		code = strings.ToLower(strings.TrimSpace(code))
+		if len(code) == 3 && strings.HasSuffix(code, "xx") {
+			class, err := strconv.Atoi(code[:1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid status class in retry policy: %s", code)
+			}
+			policy.statusClasses[class] = true
+			continue
+		}
+		status, err := strconv.Atoi(code)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status code in retry policy: %s", code)
+		}
+		policy.statusCodes[status] = true
+	}
+
+	return policy, nil
+}
+
+// DefaultRetryPolicy returns the default policy starting at base delay
+func DefaultRetryPolicy(base time.Duration) *RetryPolicy {
+	policy, _ := NewRetryPolicy(base, config.RetryPolicyConfig{})
+	return policy
+}
+
+// Retryable reports whether a response with the given status code should be retried
+func (r *RetryPolicy) Retryable(statusCode int) bool {
+	return r.statusCodes[statusCode] || r.statusClasses[statusCode/100]
+}
+
+// Delay returns the wait before the attempt following the given (zero based) attempt.
+// resp may be nil for transport errors.
+func (r *RetryPolicy) Delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return min(retryAfter, r.MaxDelay)
+		}
+	}
+
+	backoff := float64(r.BaseDelay) * math.Pow(r.Multiplier, float64(attempt))
+	backoff = min(backoff, float64(r.MaxDelay))
+	return time.Duration(r.random() * backoff)
+}
+
+// Wait blocks for d or until ctx is done, in which case the context error is returned
+func (r *RetryPolicy) Wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// parseRetryAfter parses both forms of the Retry-After header: delay in seconds and HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rotmanjanez/check24-gendev-7/config"
+)
+
+func responseWithHeader(key, value string) *http.Response {
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set(key, value)
+	return resp
+}
+
+func TestRetryPolicy_Retryable(t *testing.T) {
+	policy := DefaultRetryPolicy(time.Second)
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{http.StatusRequestTimeout, true},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusServiceUnavailable, true},
+		{http.StatusBadRequest, false},
+		{http.StatusNotFound, false},
+	}
+	for _, tt := range tests {
+		if got := policy.Retryable(tt.status); got != tt.want {
+			t.Errorf("Retryable(%d) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicy_CustomStatusCodes(t *testing.T) {
+	policy, err := NewRetryPolicy(time.Second, config.RetryPolicyConfig{RetryOn: []string{"429", "503"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !policy.Retryable(http.StatusServiceUnavailable) || policy.Retryable(http.StatusInternalServerError) {
+		t.Errorf("expected only 429 and 503 to be retryable")
+	}
+
+	if _, err := NewRetryPolicy(time.Second, config.RetryPolicyConfig{RetryOn: []string{"abc"}}); err == nil {
+		t.Errorf("expected error for invalid status code")
+	}
+}
+
+func TestRetryPolicy_ExponentialDelayWithCap(t *testing.T) {
+	policy, _ := NewRetryPolicy(100*time.Millisecond, config.RetryPolicyConfig{MaxBackoff: time.Second, Multiplier: 2})
+	policy.random = func() float64 { return 1 } // upper bound of the jitter
+
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for attempt, expected := range want {
+		if got := policy.Delay(attempt, nil); got != expected {
+			t.Errorf("Delay(%d) = %v, want %v", attempt, got, expected)
+		}
+	}
+
+	policy.random = func() float64 { return 0.5 }
+	if got := policy.Delay(1, nil); got != 100*time.Millisecond {
+		t.Errorf("expected full jitter to scale the delay, got %v", got)
+	}
+}
+
+func TestRetryPolicy_RetryAfter(t *testing.T) {
+	policy, _ := NewRetryPolicy(100*time.Millisecond, config.RetryPolicyConfig{MaxBackoff: 10 * time.Second})
+
+	if got := policy.Delay(0, responseWithHeader("Retry-After", "3")); got != 3*time.Second {
+		t.Errorf("expected Retry-After seconds to be used, got %v", got)
+	}
+	if got := policy.Delay(0, responseWithHeader("Retry-After", "120")); got != 10*time.Second {
+		t.Errorf("expected Retry-After to be capped, got %v", got)
+	}
+
+	date := time.Now().Add(5 * time.Second).UTC().Format(http.TimeFormat)
+	got := policy.Delay(0, responseWithHeader("Retry-After", date))
+	if got <= 3*time.Second || got > 5*time.Second {
+		t.Errorf("expected Retry-After date to be used, got %v", got)
+	}
+
+	policy.random = func() float64 { return 1 }
+	if got := policy.Delay(0, responseWithHeader("Retry-After", "soon")); got != 100*time.Millisecond {
+		t.Errorf("expected invalid Retry-After to be ignored, got %v", got)
+	}
+}
+
+func TestRetryPolicy_WaitCancelled(t *testing.T) {
+	policy := DefaultRetryPolicy(time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	err := policy.Wait(ctx, time.Minute)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("expected wait to return on cancellation")
+	}
+}
@@ -11,7 +11,6 @@ import (
 	"github.com/google/go-querystring/query"
 
 	"github.com/rotmanjanez/check24-gendev-7/internal/units"
-	"github.com/rotmanjanez/check24-gendev-7/internal/utils"
 	i "github.com/rotmanjanez/check24-gendev-7/pkg/interfaces"
 	m "github.com/rotmanjanez/check24-gendev-7/pkg/models"
 	p "github.com/rotmanjanez/check24-gendev-7/pkg/provider"
@@ -27,7 +26,6 @@ func init() {
 }
 
 type ByteMeAdapter struct {
-	apiKey string
 	url    string
 	logger *slog.Logger
 }
@@ -48,12 +46,11 @@ func ByteMeFactory(options map[string]interface{}, cache i.Cache, logger *slog.L
 		}
 	}
 
-	return NewByteMeAdapter(url, utils.RequireEnv("BYTEME_API_KEY"), logger), nil
+	return NewByteMeAdapter(url, logger), nil
 }
 
-func NewByteMeAdapter(url string, apiKey string, logger *slog.Logger) *ByteMeAdapter {
+func NewByteMeAdapter(url string, logger *slog.Logger) *ByteMeAdapter {
 	return &ByteMeAdapter{
-		apiKey: apiKey,
 		url:    url,
 		logger: logger,
 	}
@@ -88,8 +85,6 @@ func (b *ByteMeAdapter) PrepareRequest(ctx context.Context, request i.Request) (
 		return i.ParsedResponse{}, err
 	}
 
-	req.Header.Set("X-Api-Key", b.apiKey)
-
 	b.logger.Debug("Request", "method", req.Method, "url", req.URL, "queryparams", queryParams)
 	return i.ParsedResponse{
 		Requests: []i.PreparedRequest{{Request: req}}}, nil
@@ -13,7 +13,6 @@ import (
 func CreateTestProvider(baseURL string, logger *slog.Logger) (i.ProviderAdapter, error) {
 	return NewByteMeAdapter(
 		baseURL,
-		"test-api-key",
 		logger,
 	), nil
 }
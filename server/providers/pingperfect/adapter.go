@@ -3,21 +3,15 @@ package pingperfect
 import (
 	"bytes"
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"hash"
 	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/rotmanjanez/check24-gendev-7/internal/units"
-	utils "github.com/rotmanjanez/check24-gendev-7/internal/utils"
 	i "github.com/rotmanjanez/check24-gendev-7/pkg/interfaces"
 	m "github.com/rotmanjanez/check24-gendev-7/pkg/models"
 	p "github.com/rotmanjanez/check24-gendev-7/pkg/provider"
@@ -30,20 +24,14 @@ func init() {
 }
 
 type PingPerfectAdapter struct {
-	signatureSecret string
-	clientId        string
-	hasher          hash.Hash
-	url             string
-	logger          *slog.Logger
+	url    string
+	logger *slog.Logger
 }
 
-func NewPingPerfectAdapter(url string, clientId string, signatureSecret string, logger *slog.Logger) *PingPerfectAdapter {
+func NewPingPerfectAdapter(url string, logger *slog.Logger) *PingPerfectAdapter {
 	return &PingPerfectAdapter{
-		signatureSecret: signatureSecret,
-		clientId:        clientId,
-		hasher:          hmac.New(sha256.New, []byte(signatureSecret)),
-		url:             url,
-		logger:          logger,
+		url:    url,
+		logger: logger,
 	}
 }
 
@@ -63,42 +51,13 @@ func PingPerfectFactory(options map[string]interface{}, cache i.Cache, logger *s
 		}
 	}
 
-	signatureSecret := utils.RequireEnv("PING_PERFECT_SIGNATURE_SECRET")
-	clientId := utils.RequireEnv("PING_PERFECT_CLIENT_ID")
-
-	return NewPingPerfectAdapter(url, clientId, signatureSecret, logger), nil
+	return NewPingPerfectAdapter(url, logger), nil
 }
 
 func (p *PingPerfectAdapter) Name() string {
 	return providerName
 }
 
-type Signature struct {
-	Signature string
-	Timestamp int64
-}
-
-func (p *PingPerfectAdapter) getSignature(body []byte) (Signature, error) {
-	defer p.hasher.Reset()
-
-	timeStamp := time.Now().Unix()
-
-	str := fmt.Sprintf("%d:%s", timeStamp, string(body))
-
-	_, err := p.hasher.Write([]byte(str))
-	if err != nil {
-		p.logger.Error("Error writing to hasher", "error", err)
-		return Signature{}, err
-	}
-
-	signature := p.hasher.Sum(nil)
-
-	return Signature{
-		Signature: hex.EncodeToString(signature),
-		Timestamp: timeStamp,
-	}, nil
-}
-
 // Converts an address and wantsFiber flag into a request in the provider's format
 func (p *PingPerfectAdapter) getRequestBody(address m.Address, wantsFiber bool) ([]byte, error) {
 	if address.HouseNumber == "" {
@@ -127,14 +86,6 @@ func (p *PingPerfectAdapter) getRequestBody(address m.Address, wantsFiber bool)
 	return body, nil
 }
 
-// Adds required headers to the request
-func (p *PingPerfectAdapter) addHeaders(req *http.Request, signature Signature) {
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Client-ID", p.clientId)
-	req.Header.Set("X-Signature", signature.Signature)
-	req.Header.Set("X-Timestamp", fmt.Sprintf("%d", signature.Timestamp))
-}
-
 func (p *PingPerfectAdapter) prepareRequest(address i.Request, wantsFiber bool) (*i.PreparedRequest, error) {
 	body, err := p.getRequestBody(address.Address, wantsFiber)
 
@@ -147,20 +98,14 @@ func (p *PingPerfectAdapter) prepareRequest(address i.Request, wantsFiber bool)
 		return nil, nil
 	}
 
-	signature, err := p.getSignature(body)
-	if err != nil {
-		p.logger.Error("Error getting signature", "error", err)
-		return nil, err
-	}
-
-	req, err := http.NewRequest("POST", p.url, io.NopCloser(bytes.NewReader(body)))
+	req, err := http.NewRequest("POST", p.url, bytes.NewReader(body))
 	if err != nil {
 		p.logger.Error("Error creating request", "error", err)
 		return nil, err
 	}
 
-	// Add headers to the request
-	p.addHeaders(req, signature)
+	// the client id and signature are added by the signers configured for the backend
+	req.Header.Set("Content-Type", "application/json")
 	return &i.PreparedRequest{
 		Request: req,
 	}, nil
@@ -14,8 +14,6 @@ func CreateTestProvider(baseURL string, logger *slog.Logger) (i.ProviderAdapter,
 	// Use test credentials for testing
 	return NewPingPerfectAdapter(
 		baseURL,
-		"test-client-id",
-		"test-signature-secret",
 		logger,
 	), nil
 }
@@ -12,7 +12,6 @@ import (
 	"time"
 
 	"github.com/rotmanjanez/check24-gendev-7/internal/units"
-	"github.com/rotmanjanez/check24-gendev-7/internal/utils"
 	i "github.com/rotmanjanez/check24-gendev-7/pkg/interfaces"
 	m "github.com/rotmanjanez/check24-gendev-7/pkg/models"
 	p "github.com/rotmanjanez/check24-gendev-7/pkg/provider"
@@ -43,8 +42,6 @@ func ServusSpeedFactory(options map[string]interface{}, cache i.Cache, logger *s
 		}
 	}
 	return NewServusSpeedAdapter(
-		utils.RequireEnv("SERVUS_SPEED_USERNAME"),
-		utils.RequireEnv("SERVUS_SPEED_PASSWORD"),
 		url,
 		cache,
 		time.Duration(cacheDuration)*time.Minute,
@@ -53,18 +50,14 @@ func ServusSpeedFactory(options map[string]interface{}, cache i.Cache, logger *s
 }
 
 type ServusSpeedAdapter struct {
-	username      string
-	password      string
 	url           string
 	cacheDuration time.Duration
 	cache         i.Cache
 	logger        *slog.Logger
 }
 
-func NewServusSpeedAdapter(username string, password string, url string, cache i.Cache, cacheDuration time.Duration, logger *slog.Logger) *ServusSpeedAdapter {
+func NewServusSpeedAdapter(url string, cache i.Cache, cacheDuration time.Duration, logger *slog.Logger) *ServusSpeedAdapter {
 	return &ServusSpeedAdapter{
-		username:      username,
-		password:      password,
 		url:           url,
 		cache:         cache,
 		cacheDuration: cacheDuration,
@@ -82,9 +75,6 @@ func (s *ServusSpeedAdapter) newAPIRequest(method, endpoint string, body []byte)
 		s.logger.Error("Error creating new request", "error", err)
 		return nil, err
 	}
-	// add basic auth
-	req.SetBasicAuth(s.username, s.password)
-
 	// set headers
 	req.Header.Set("Content-Type", "application/json")
 
@@ -17,8 +17,6 @@ import (
 func CreateTestProvider(baseURL string, logger *slog.Logger) (i.ProviderAdapter, error) {
 	// Use in-memory instance cache for tests
 	return NewServusSpeedAdapter(
-		"test-username",
-		"test-password",
 		baseURL,
 		cache.NewInstanceCache("test-servusspeed"),
 		5*time.Minute,
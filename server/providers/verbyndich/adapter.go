@@ -11,7 +11,6 @@ import (
 	"strings"
 
 	"github.com/rotmanjanez/check24-gendev-7/internal/units"
-	utils "github.com/rotmanjanez/check24-gendev-7/internal/utils"
 	i "github.com/rotmanjanez/check24-gendev-7/pkg/interfaces"
 	m "github.com/rotmanjanez/check24-gendev-7/pkg/models"
 	p "github.com/rotmanjanez/check24-gendev-7/pkg/provider"
@@ -21,7 +20,6 @@ const providerName = "VerbynDich"
 
 type VerbynDichAdapter struct {
 	url               string
-	apiKey            string
 	descriptionParser *DescriptionParser
 	blockSize         uint
 	logger            *slog.Logger
@@ -46,13 +44,12 @@ func VerbynDichFactory(options map[string]interface{}, cache i.Cache, logger *sl
 			logger.Warn("VerbynDich provider ignoring unknown option", "key", k, "value", v)
 		}
 	}
-	return NewVerbynDichAdapter(url, utils.RequireEnv("VERBYNDICH_API_KEY"), uint(blockSize), logger), nil
+	return NewVerbynDichAdapter(url, uint(blockSize), logger), nil
 }
 
-func NewVerbynDichAdapter(url string, apiKey string, blockSize uint, logger *slog.Logger) *VerbynDichAdapter {
+func NewVerbynDichAdapter(url string, blockSize uint, logger *slog.Logger) *VerbynDichAdapter {
 	return &VerbynDichAdapter{
 		url:               url,
-		apiKey:            apiKey,
 		descriptionParser: NewDescriptionParser(logger),
 		blockSize:         blockSize,
 		logger:            logger,
@@ -64,7 +61,7 @@ func (v *VerbynDichAdapter) Name() string {
 }
 
 func (v *VerbynDichAdapter) newAPIRequest(address m.Address, page uint) (i.PreparedRequest, error) {
-	url := fmt.Sprintf("%s/check24/data?apiKey=%s&page=%d", v.url, v.apiKey, page)
+	url := fmt.Sprintf("%s/check24/data?page=%d", v.url, page)
 	body := fmt.Sprintf(`%s;%s;%s;%s`, address.Street, address.HouseNumber, address.City, address.PostalCode)
 
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(body)))
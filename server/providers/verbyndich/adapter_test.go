@@ -14,7 +14,6 @@ import (
 func CreateTestProvider(baseURL string, logger *slog.Logger) (i.ProviderAdapter, error) {
 	return NewVerbynDichAdapter(
 		baseURL,
-		"test-api-key",
 		1, // blockSize
 		logger,
 	), nil
@@ -9,7 +9,7 @@ import (
 	"log/slog"
 	"net/http"
 
-	"github.com/rotmanjanez/check24-gendev-7/internal/utils"
+	"github.com/rotmanjanez/check24-gendev-7/pkg/checkpoint"
 	i "github.com/rotmanjanez/check24-gendev-7/pkg/interfaces"
 	m "github.com/rotmanjanez/check24-gendev-7/pkg/models"
 	p "github.com/rotmanjanez/check24-gendev-7/pkg/provider"
@@ -20,7 +20,6 @@ const providerName = "WebWunder"
 var connectionTypes = []string{"DSL", "CABLE", "FIBER", "MOBILE"}
 
 type WebWunderAdapter struct {
-	apiKey       string
 	logger       *slog.Logger
 	soapEndpoint string
 	soapAction   string
@@ -34,8 +33,6 @@ func init() {
 
 // WebWunderFactory creates a new instance of the WebWunderAdapter
 func WebWunderFactory(options map[string]interface{}, cache i.Cache, logger *slog.Logger) (i.ProviderAdapter, error) {
-	apiKey := utils.RequireEnv("WEBWUNDER_API_KEY")
-
 	soapEndpoint, ok := options["soapEndpoint"].(string)
 	if !ok || soapEndpoint == "" {
 		return nil, fmt.Errorf("WebWunder provider requires soapEndpoint in options")
@@ -62,12 +59,11 @@ func WebWunderFactory(options map[string]interface{}, cache i.Cache, logger *slo
 		}
 	}
 
-	return NewWebWunderAdapter(apiKey, soapEndpoint, soapAction, soapGs, soapEnv, logger), nil
+	return NewWebWunderAdapter(soapEndpoint, soapAction, soapGs, soapEnv, logger), nil
 }
 
-func NewWebWunderAdapter(apiKey string, soapEndpoint string, soapAction string, soapGs string, soapEnv string, logger *slog.Logger) *WebWunderAdapter {
+func NewWebWunderAdapter(soapEndpoint string, soapAction string, soapGs string, soapEnv string, logger *slog.Logger) *WebWunderAdapter {
 	return &WebWunderAdapter{
-		apiKey:       apiKey,
 		soapEndpoint: soapEndpoint,
 		soapAction:   soapAction,
 		soapGs:       soapGs,
@@ -84,6 +80,11 @@ type metadata struct {
 	Installation bool
 }
 
+func init() {
+	// requests are checkpointed with their metadata, so that queries can be resumed on another instance
+	checkpoint.RegisterJSON[metadata]("webwunder.metadata")
+}
+
 // PrepareRequest converts a general request into SOAP-specific HTTP requests
 func (w *WebWunderAdapter) PrepareRequest(ctx context.Context, request i.Request) (i.ParsedResponse, error) {
 	switch request.Address.CountryCode {
@@ -284,7 +285,6 @@ func (w *WebWunderAdapter) createHTTPRequestFromRequest(request i.Request, conne
 	req.Header.Set("Content-Type", "text/xml;charset=UTF-8")
 	req.Header.Set("SOAPAction", w.soapAction)
 	req.Header.Set("Accept", "text/xml")
-	req.Header.Set("X-Api-Key", w.apiKey)
 
 	return req, nil
 }
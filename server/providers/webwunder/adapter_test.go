@@ -121,7 +121,6 @@ func createProductWithPercentageDiscount(product m.InternetProduct, percentage i
 // CreateTestProvider creates a WebWunder provider instance for testing
 func CreateTestProvider(baseUrl string, logger *slog.Logger) (i.ProviderAdapter, error) {
 	return NewWebWunderAdapter(
-		"test-api-key",
 		baseUrl+"/endpunkte/soap/ws",
 		"http://spring.io/guides/gs-producing-web-service/legacyGetInternetOffers",
 		"http://webwunder.gendev7.check24.fun/offerservice",
@@ -16,6 +16,7 @@ import (
 
 	"github.com/rotmanjanez/check24-gendev-7/config"
 	"github.com/rotmanjanez/check24-gendev-7/internal/api"
+	"github.com/rotmanjanez/check24-gendev-7/internal/workerpool"
 	"github.com/rotmanjanez/check24-gendev-7/pkg/cache"
 	m "github.com/rotmanjanez/check24-gendev-7/pkg/models"
 	p "github.com/rotmanjanez/check24-gendev-7/pkg/provider"
@@ -59,8 +60,9 @@ func setupE2ETest(t *testing.T) *E2ETestSuite {
 	providers := createTestProviders(t, logger)
 
 	// Create API services
-	internetService := api.NewInternetProductsAPIService(cfg, mainCache, queueCache, providers)
-	systemService := api.NewSystemAPIService(cfg)
+	pool := workerpool.New(int(cfg.MaxConcurrentRequests), int(cfg.RequestBufferSize))
+	internetService := api.NewInternetProductsAPIService(cfg, mainCache, queueCache, providers, pool)
+	systemService := api.NewSystemAPIService(cfg, pool)
 	healthService := api.NewHealthAPIService()
 
 	// Create controllers
@@ -112,12 +114,11 @@ func createTestProviders(t *testing.T, logger *slog.Logger) []*p.ProviderConfig
 
 	return []*p.ProviderConfig{
 		p.NewProviderConfig(
-			byteme.NewByteMeAdapter(byteMeServer.URL, "test-api-key", logger),
+			byteme.NewByteMeAdapter(byteMeServer.URL, logger),
 			3, 5*time.Second, 1, 500*time.Millisecond,
 		),
 		p.NewProviderConfig(
 			webwunder.NewWebWunderAdapter(
-				"test-api-key",
 				webWunderServer.URL+"/endpunkte/soap/ws",
 				"http://spring.io/guides/gs-producing-web-service/legacyGetInternetOffers",
 				"http://webwunder.gendev7.check24.fun/offerservice",
@@ -127,15 +128,15 @@ func createTestProviders(t *testing.T, logger *slog.Logger) []*p.ProviderConfig
 			3, 5*time.Second, 1, 500*time.Millisecond,
 		),
 		p.NewProviderConfig(
-			verbyndich.NewVerbynDichAdapter(verbynDichServer.URL+"/check24/data", "dummy-api-key", 1, logger),
+			verbyndich.NewVerbynDichAdapter(verbynDichServer.URL+"/check24/data", 1, logger),
 			3, 5*time.Second, 1, 500*time.Millisecond,
 		),
 		p.NewProviderConfig(
-			servusspeed.NewServusSpeedAdapter("test-username", "test-password", servusSpeedServer.URL+"/api/external/product-details", cache.NewInstanceCache("servusspeed-e2e"), 5*time.Minute, logger),
+			servusspeed.NewServusSpeedAdapter(servusSpeedServer.URL+"/api/external/product-details", cache.NewInstanceCache("servusspeed-e2e"), 5*time.Minute, logger),
 			3, 5*time.Second, 1, 500*time.Millisecond,
 		),
 		p.NewProviderConfig(
-			pingperfect.NewPingPerfectAdapter(pingPerfectServer.URL, "test-client-id", "test-signature-secret", logger),
+			pingperfect.NewPingPerfectAdapter(pingPerfectServer.URL, logger),
 			3, 5*time.Second, 1, 500*time.Millisecond,
 		),
 	}
@@ -44,28 +44,28 @@ func TestE2E_ProviderIntegration(t *testing.T) {
 	// Create provider configs using the actual NewProviderConfig constructor
 	providers := []*p.ProviderConfig{
 		p.NewProviderConfig(
-			byteme.NewByteMeAdapter(byteMeServer.URL, "test-api-key", logger),
+			byteme.NewByteMeAdapter(byteMeServer.URL, logger),
 			3,                    // retries
 			5*time.Second,        // timeout
 			1,                    // maxConcurrent
 			500*time.Millisecond, // backoff
 		),
 		p.NewProviderConfig(
-			verbyndich.NewVerbynDichAdapter(verbynDichServer.URL+"/check24/data", "test-api-key", 1, logger),
+			verbyndich.NewVerbynDichAdapter(verbynDichServer.URL+"/check24/data", 1, logger),
 			3,                    // retries
 			5*time.Second,        // timeout
 			1,                    // maxConcurrent
 			500*time.Millisecond, // backoff
 		),
 		p.NewProviderConfig(
-			servusspeed.NewServusSpeedAdapter("test-username", "test-password", servusSpeedServer.URL, testCache, 5*time.Minute, logger),
+			servusspeed.NewServusSpeedAdapter(servusSpeedServer.URL, testCache, 5*time.Minute, logger),
 			3,                    // retries
 			5*time.Second,        // timeout
 			1,                    // maxConcurrent
 			500*time.Millisecond, // backoff
 		),
 		p.NewProviderConfig(
-			pingperfect.NewPingPerfectAdapter(pingPerfectServer.URL, "test-client-id", "test-signature-secret", logger),
+			pingperfect.NewPingPerfectAdapter(pingPerfectServer.URL, logger),
 			3,                    // retries
 			5*time.Second,        // timeout
 			1,                    // maxConcurrent
@@ -91,7 +91,7 @@ func TestE2E_ProviderIntegration(t *testing.T) {
 
 	request := i.Request{Address: address}
 	// Execute coordination
-	responsesChan, errorsChan := coordinator.Run(ctx, request, 100, 100)
+	responsesChan, errorsChan, _ := coordinator.Run(ctx, request, 100, 100)
 
 	// Collect results
 	var products []m.InternetProduct
@@ -136,14 +136,14 @@ func TestE2E_ProviderErrorHandling(t *testing.T) {
 	// Create provider configs with one working and one failing provider
 	providers := []*p.ProviderConfig{
 		p.NewProviderConfig(
-			byteme.NewByteMeAdapter(workingServer.URL, "test-api-key", logger),
+			byteme.NewByteMeAdapter(workingServer.URL, logger),
 			3,                    // retries
 			5*time.Second,        // timeout
 			1,                    // maxConcurrent
 			500*time.Millisecond, // backoff
 		),
 		p.NewProviderConfig(
-			byteme.NewByteMeAdapter(failingServer.URL, "test-api-key", logger),
+			byteme.NewByteMeAdapter(failingServer.URL, logger),
 			3,                    // retries
 			5*time.Second,        // timeout
 			1,                    // maxConcurrent
@@ -169,7 +169,7 @@ func TestE2E_ProviderErrorHandling(t *testing.T) {
 
 	request := i.Request{Address: address}
 	// Execute coordination
-	responsesChan, errorsChan := coordinator.Run(ctx, request, 100, 100)
+	responsesChan, errorsChan, _ := coordinator.Run(ctx, request, 100, 100)
 
 	// Collect results
 	var products []m.InternetProduct
@@ -207,7 +207,7 @@ func TestE2E_ProviderTimeouts(t *testing.T) {
 	// Create provider config with very short timeout
 	providers := []*p.ProviderConfig{
 		p.NewProviderConfig(
-			byteme.NewByteMeAdapter(slowServer.URL, "test-api-key", logger),
+			byteme.NewByteMeAdapter(slowServer.URL, logger),
 			1,                    // retries
 			1*time.Second,        // short timeout
 			1,                    // maxConcurrent
@@ -233,7 +233,7 @@ func TestE2E_ProviderTimeouts(t *testing.T) {
 
 	request := i.Request{Address: address}
 	// Execute coordination
-	responsesChan, errorsChan := coordinator.Run(ctx, request, 100, 100)
+	responsesChan, errorsChan, _ := coordinator.Run(ctx, request, 100, 100)
 
 	// Collect results
 	var products []m.InternetProduct